@@ -2,14 +2,22 @@ package main
 
 import (
 	"context"
+	"flag"
 	"log"
+	"os"
 
+	"capm-exam-system/internal/config"
 	"capm-exam-system/internal/database"
 )
 
 func main() {
+	cfg, err := config.Load(flag.CommandLine, os.Args[1:])
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+
 	// Connect to database
-	db, err := database.New()
+	db, err := database.New(cfg.Database)
 	if err != nil {
 		log.Fatalf("Failed to connect to database: %v", err)
 	}
@@ -4,18 +4,23 @@ import (
 	"context"
 	"flag"
 	"log"
+	"os"
 	"strings"
 
+	"capm-exam-system/internal/config"
 	"capm-exam-system/internal/database"
 	"capm-exam-system/internal/repository"
 )
 
 func main() {
 	exam := flag.String("exam", "capm", "Exam to seed: capm or pmp")
-	flag.Parse()
+	cfg, err := config.Load(flag.CommandLine, os.Args[1:])
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
 
 	// Connect to database
-	db, err := database.New()
+	db, err := database.New(cfg.Database)
 	if err != nil {
 		log.Fatalf("Failed to connect to database: %v", err)
 	}
@@ -49,14 +54,14 @@ func main() {
 
 	for i, q := range questions {
 		// Create question
-		question, err := repo.CreateQuestion(ctx, q.prompt, q.domain, q.explanation, q.popularityScore, q.isMultiSelect)
+		question, err := repo.CreateQuestion(ctx, q.prompt, q.domain, q.explanation, q.popularityScore, q.isMultiSelect, q.shuffleChoices(), q.family)
 		if err != nil {
 			log.Fatalf("Failed to create question %d: %v", i+1, err)
 		}
 
 		// Create choices
-		for _, c := range q.choices {
-			_, err := repo.CreateChoice(ctx, question.ID, c.text, c.label, c.isCorrect)
+		for j, c := range q.choices {
+			_, err := repo.CreateChoice(ctx, question.ID, c.text, c.label, c.isCorrect, q.rationale(j))
 			if err != nil {
 				log.Fatalf("Failed to create choice for question %d: %v", i+1, err)
 			}
@@ -66,13 +71,13 @@ func main() {
 	}
 
 	for i, q := range additional {
-		question, err := repo.CreateQuestion(ctx, q.prompt, q.domain, q.explanation, q.popularityScore, q.isMultiSelect)
+		question, err := repo.CreateQuestion(ctx, q.prompt, q.domain, q.explanation, q.popularityScore, q.isMultiSelect, q.shuffleChoices(), q.family)
 		if err != nil {
 			log.Fatalf("Failed to create additional question %d: %v", i+1, err)
 		}
 
-		for _, c := range q.choices {
-			_, err := repo.CreateChoice(ctx, question.ID, c.text, c.label, c.isCorrect)
+		for j, c := range q.choices {
+			_, err := repo.CreateChoice(ctx, question.ID, c.text, c.label, c.isCorrect, q.rationale(j))
 			if err != nil {
 				log.Fatalf("Failed to create choice for additional question %d: %v", i+1, err)
 			}
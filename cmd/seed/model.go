@@ -1,5 +1,7 @@
 package main
 
+import "regexp"
+
 // QuestionData represents the structure for question data
 type QuestionData struct {
 	prompt          string
@@ -7,7 +9,16 @@ type QuestionData struct {
 	explanation     string
 	popularityScore float64
 	isMultiSelect   bool
-	choices         []ChoiceData
+	// family groups questions rendered from the same template so a draw
+	// does not serve near-duplicates together.
+	family string
+	// fixedChoiceOrder keeps the authored choice order in every attempt.
+	// Choices that name another choice by label are detected automatically.
+	fixedChoiceOrder bool
+	choices          []ChoiceData
+	// rationales, when set, explain each choice in the same order as
+	// choices. They are shown in tutor mode and on the results page.
+	rationales []string
 }
 
 type ChoiceData struct {
@@ -17,3 +28,29 @@ type ChoiceData struct {
 }
 
 // QuestionData and ChoiceData are shared between seed data files.
+
+// positionalChoice matches choice text that only makes sense in the authored
+// order, such as "All of the above" or "Both A and B".
+var positionalChoice = regexp.MustCompile(`(?i:of the above)|\b[A-E] (?:and|or) [A-E]\b`)
+
+// shuffleChoices reports whether the question's choices may be shuffled per
+// attempt.
+func (q QuestionData) shuffleChoices() bool {
+	if q.fixedChoiceOrder {
+		return false
+	}
+	for _, c := range q.choices {
+		if positionalChoice.MatchString(c.text) {
+			return false
+		}
+	}
+	return true
+}
+
+// rationale returns the rationale of the i-th choice, if any.
+func (q QuestionData) rationale(i int) string {
+	if i < len(q.rationales) {
+		return q.rationales[i]
+	}
+	return ""
+}
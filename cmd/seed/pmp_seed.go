@@ -1002,6 +1002,7 @@ func renderTemplate(tmpl pmpTemplate, ctx scenarioContext, idx int) QuestionData
 	baseChoices := tmpl.choices(ctx)
 	labels := []string{"A", "B", "C", "D", "E"}
 	choices := make([]ChoiceData, 0, len(baseChoices))
+	rationales := make([]string, 0, len(baseChoices))
 	correct := make([]templateChoice, 0)
 	for i, choice := range baseChoices {
 		label := labels[i%len(labels)]
@@ -1010,6 +1011,7 @@ func renderTemplate(tmpl pmpTemplate, ctx scenarioContext, idx int) QuestionData
 			label:     label,
 			isCorrect: choice.Correct,
 		})
+		rationales = append(rationales, choice.Rationale)
 		if choice.Correct {
 			correct = append(correct, choice)
 		}
@@ -1030,7 +1032,9 @@ func renderTemplate(tmpl pmpTemplate, ctx scenarioContext, idx int) QuestionData
 		explanation:     explanation,
 		popularityScore: pop,
 		isMultiSelect:   tmpl.isMulti,
+		family:          "pmp/" + tmpl.name,
 		choices:         choices,
+		rationales:      rationales,
 	}
 }
 
@@ -1,40 +1,156 @@
 package main
 
 import (
-	"log"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
 	"net/http"
 	"os"
+	"os/signal"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
 
+	"capm-exam-system/internal/config"
 	"capm-exam-system/internal/database"
 	"capm-exam-system/internal/handlers"
+	"capm-exam-system/internal/logging"
+	"capm-exam-system/internal/metrics"
 	"capm-exam-system/internal/pdf"
 	"capm-exam-system/internal/repository"
 	"capm-exam-system/internal/service"
+	"capm-exam-system/internal/tracing"
 )
 
+// groupSessionInterval is how often the server looks for group sessions
+// whose time is up.
+const groupSessionInterval = 5 * time.Second
+
 func main() {
+	cfg, err := config.Load(flag.CommandLine, os.Args[1:])
+	if err != nil {
+		slog.Error("failed to load configuration", "error", err)
+		os.Exit(1)
+	}
+
+	slog.SetDefault(logging.New(os.Stdout, cfg.Log.Format, cfg.Log.Level))
+
+	if err := run(cfg); err != nil {
+		slog.Error("server stopped", "error", err)
+		os.Exit(1)
+	}
+}
+
+// run serves until SIGINT or SIGTERM, then shuts down in dependency order:
+// the HTTP server drains in-flight requests and background workers stop,
+// then the database pool closes and pending traces are flushed.
+func run(cfg *config.Config) error {
+	// Traces: OTEL_TRACES_EXPORTER=otlp|stdout|none
+	shutdownTracing, err := tracing.Setup(context.Background())
+	if err != nil {
+		return fmt.Errorf("failed to set up tracing: %v", err)
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdownTracing(ctx); err != nil {
+			slog.Error("failed to flush traces", "error", err)
+		}
+	}()
+
 	// Connect to database
-	db, err := database.New()
+	db, err := database.New(cfg.Database)
 	if err != nil {
-		log.Fatalf("Failed to connect to database: %v", err)
+		return fmt.Errorf("failed to connect to database: %v", err)
 	}
 	defer db.Close()
 
 	// Initialize layers
 	repo := repository.New(db)
-	svc := service.New(repo)
-	pdfSvc := pdf.New()
-	handlers := handlers.New(svc, pdfSvc)
+	svc := service.New(repo, cfg.Exam)
+	pdfSvc := pdf.New(cfg.Exam)
+	handlers := handlers.New(svc, pdfSvc, cfg)
+
+	metrics.RegisterPool(db.Pool)
+	metrics.RegisterAttemptsInProgress(svc.CountAttemptsInProgress)
+
+	// Background workers run until shutdown begins
+	workerCtx, stopWorkers := context.WithCancel(context.Background())
+	defer stopWorkers()
+	var workers sync.WaitGroup
 
-	// Setup routes
-	router := handlers.SetupRoutes()
+	// Apply answer key corrections to submitted attempts in the background
+	if cfg.Features.Regrader {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			svc.RunRegrader(workerCtx, cfg.Regrader.Interval)
+		}()
+	}
+
+	// Submit what is left of group sessions whose time is up
+	if cfg.Features.GroupSessions {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			svc.RunGroupSessions(workerCtx, groupSessionInterval)
+		}()
+	}
+
+	server := &http.Server{
+		Addr:              ":" + strconv.Itoa(cfg.Server.Port),
+		Handler:           handlers.SetupRoutes(),
+		ReadHeaderTimeout: cfg.Server.ReadHeaderTimeout,
+		ReadTimeout:       cfg.Server.ReadTimeout,
+		WriteTimeout:      cfg.Server.WriteTimeout,
+		IdleTimeout:       cfg.Server.IdleTimeout,
+	}
+	server.RegisterOnShutdown(handlers.CloseStreams)
+
+	signals, stopSignals := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stopSignals()
+
+	serverErr := make(chan error, 1)
+	go func() {
+		slog.Info("server starting", "port", cfg.Server.Port, "env", cfg.Env)
+		serverErr <- server.ListenAndServe()
+	}()
 
-	// Get port from environment
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
+	select {
+	case err := <-serverErr:
+		return err
+	case <-signals.Done():
 	}
+	// A second signal kills the process without waiting for the drain
+	stopSignals()
+	slog.Info("shutting down", "timeout", cfg.Server.ShutdownTimeout.String())
 
-	log.Printf("Server starting on port %s", port)
-	log.Fatal(http.ListenAndServe(":"+port, router))
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
+	defer cancel()
+
+	stopWorkers()
+	if err := server.Shutdown(ctx); err != nil {
+		slog.Error("requests still running at shutdown deadline", "error", err)
+		server.Close()
+	}
+
+	workersDone := make(chan struct{})
+	go func() {
+		workers.Wait()
+		close(workersDone)
+	}()
+	select {
+	case <-workersDone:
+	case <-ctx.Done():
+		slog.Error("background workers still running at shutdown deadline")
+	}
+
+	if err := <-serverErr; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	slog.Info("server stopped cleanly")
+	return nil
 }
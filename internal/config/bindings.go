@@ -0,0 +1,101 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// binding ties a setting to its environment variable and, for the settings
+// that are commonly overridden per run, a command-line flag.
+type binding struct {
+	env    string
+	flag   string
+	usage  string
+	target interface{}
+}
+
+func (c *Config) bindings() []binding {
+	return []binding{
+		{"APP_ENV", "env", "development or production", &c.Env},
+
+		{"PORT", "port", "HTTP listen port", &c.Server.Port},
+		{"HTTP_READ_HEADER_TIMEOUT", "", "", &c.Server.ReadHeaderTimeout},
+		{"HTTP_READ_TIMEOUT", "", "", &c.Server.ReadTimeout},
+		{"HTTP_WRITE_TIMEOUT", "", "", &c.Server.WriteTimeout},
+		{"HTTP_IDLE_TIMEOUT", "", "", &c.Server.IdleTimeout},
+		{"SHUTDOWN_TIMEOUT", "", "", &c.Server.ShutdownTimeout},
+
+		{"PG_URL", "pg-url", "PostgreSQL connection URL", &c.Database.URL},
+		{"DB_MAX_CONNS", "", "", &c.Database.MaxConns},
+		{"DB_MIN_CONNS", "", "", &c.Database.MinConns},
+		{"DB_MAX_CONN_LIFETIME", "", "", &c.Database.MaxConnLifetime},
+		{"DB_MAX_CONN_IDLE_TIME", "", "", &c.Database.MaxConnIdleTime},
+		{"DB_CONNECT_TIMEOUT", "", "", &c.Database.ConnectTimeout},
+
+		{"LOG_FORMAT", "log-format", "json or text", &c.Log.Format},
+		{"LOG_LEVEL", "log-level", "debug, info, warn or error", &c.Log.Level},
+
+		{"PASS_MARK", "", "", &c.Exam.PassMark},
+		{"HARD_DRILL_LENGTH", "", "", &c.Exam.HardDrillLength},
+		{"EXPOSURE_RECENT_ATTEMPTS", "", "", &c.Exam.Exposure.RecentAttempts},
+		{"EXPOSURE_RECENT_WINDOW", "", "", &c.Exam.Exposure.RecentWindow},
+		{"EXPOSURE_RECENT_WEIGHT", "", "", &c.Exam.Exposure.RecentWeight},
+		{"EXPOSURE_MAX_RATE", "", "", &c.Exam.Exposure.MaxRate},
+		{"EXPOSURE_RATE_WINDOW", "", "", &c.Exam.Exposure.RateWindow},
+		{"EXPOSURE_RATE_MIN_ATTEMPTS", "", "", &c.Exam.Exposure.RateMinAttempts},
+		{"CHALLENGE_SECRET", "", "", &c.Exam.ChallengeSecret},
+
+		{"ADMIN_TOKEN", "", "", &c.Admin.Token},
+		{"REGRADE_INTERVAL", "", "", &c.Regrader.Interval},
+
+		{"FEATURE_REGRADER", "", "", &c.Features.Regrader},
+		{"FEATURE_COHORTS", "", "", &c.Features.Cohorts},
+		{"FEATURE_QUESTION_REPORTS", "", "", &c.Features.QuestionReports},
+		{"FEATURE_PDF_REPORTS", "", "", &c.Features.PDFReports},
+		{"FEATURE_METRICS", "", "", &c.Features.Metrics},
+		{"FEATURE_GROUP_SESSIONS", "", "", &c.Features.GroupSessions},
+	}
+}
+
+// setValue parses value into the setting target points at. Durations use
+// Go syntax such as "30s" or "1h30m".
+func setValue(target interface{}, value string) error {
+	switch t := target.(type) {
+	case *string:
+		*t = value
+	case *int:
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("%q is not an integer", value)
+		}
+		*t = n
+	case *int32:
+		n, err := strconv.ParseInt(value, 10, 32)
+		if err != nil {
+			return fmt.Errorf("%q is not an integer", value)
+		}
+		*t = int32(n)
+	case *float64:
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return fmt.Errorf("%q is not a number", value)
+		}
+		*t = f
+	case *bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("%q is not a boolean", value)
+		}
+		*t = b
+	case *time.Duration:
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("%q is not a duration such as 30s", value)
+		}
+		*t = d
+	default:
+		return fmt.Errorf("unsupported setting type %T", target)
+	}
+	return nil
+}
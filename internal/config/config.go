@@ -0,0 +1,348 @@
+// Package config loads the application settings from defaults, an optional
+// YAML file, the environment and command-line flags, in increasing order of
+// precedence, and validates the result before anything starts.
+package config
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	EnvDevelopment = "development"
+	EnvProduction  = "production"
+
+	// defaultDatabasePassword is the password of the development database in
+	// docker-compose.yml; production refuses to run with it.
+	defaultDatabasePassword = "postgres"
+
+	// defaultChallengeSecret signs challenge links in development;
+	// production refuses to run with it.
+	defaultChallengeSecret = "development-challenge-secret"
+)
+
+type Config struct {
+	// Env is "development" or "production".
+	Env      string         `yaml:"env"`
+	Server   ServerConfig   `yaml:"server"`
+	Database DatabaseConfig `yaml:"database"`
+	Log      LogConfig      `yaml:"log"`
+	Exam     ExamConfig     `yaml:"exam"`
+	Admin    AdminConfig    `yaml:"admin"`
+	Regrader RegraderConfig `yaml:"regrader"`
+	Features FeatureConfig  `yaml:"features"`
+}
+
+type ServerConfig struct {
+	Port              int           `yaml:"port"`
+	ReadHeaderTimeout time.Duration `yaml:"read_header_timeout"`
+	ReadTimeout       time.Duration `yaml:"read_timeout"`
+	// WriteTimeout bounds the slowest handler, including PDF rendering.
+	WriteTimeout    time.Duration `yaml:"write_timeout"`
+	IdleTimeout     time.Duration `yaml:"idle_timeout"`
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout"`
+}
+
+type DatabaseConfig struct {
+	URL             string        `yaml:"url"`
+	MaxConns        int32         `yaml:"max_conns"`
+	MinConns        int32         `yaml:"min_conns"`
+	MaxConnLifetime time.Duration `yaml:"max_conn_lifetime"`
+	MaxConnIdleTime time.Duration `yaml:"max_conn_idle_time"`
+	ConnectTimeout  time.Duration `yaml:"connect_timeout"`
+}
+
+type LogConfig struct {
+	// Format is "json" or "text".
+	Format string `yaml:"format"`
+	// Level is debug, info, warn or error.
+	Level string `yaml:"level"`
+}
+
+type ExamConfig struct {
+	// PassMark is the share of correct answers treated as a pass. PMI does
+	// not publish the CAPM cut score; 70% is the usual target.
+	PassMark        float64        `yaml:"pass_mark"`
+	HardDrillLength int            `yaml:"hard_drill_length"`
+	Drills          DrillsConfig   `yaml:"drills"`
+	Exposure        ExposureConfig `yaml:"exposure"`
+	// ChallengeSecret signs challenge links; changing it invalidates the
+	// links already shared.
+	ChallengeSecret string `yaml:"challenge_secret"`
+}
+
+// ExposureConfig keeps questions from repeating too often, for one learner
+// and across everyone. Both controls lower draw weights rather than exclude
+// questions, so a small bank still fills every exam.
+type ExposureConfig struct {
+	// A learner's recent questions are those served in their last
+	// RecentAttempts attempts or within RecentWindow.
+	RecentAttempts int           `yaml:"recent_attempts"`
+	RecentWindow   time.Duration `yaml:"recent_window"`
+	// RecentWeight scales the draw weight of a recent question. Zero serves
+	// recent questions only when the domain has nothing else left.
+	RecentWeight float64 `yaml:"recent_weight"`
+	// MaxRate is the share of an exam's attempts over RateWindow a question
+	// should appear in. Questions above it are down-weighted in proportion
+	// once the exam has RateMinAttempts attempts in the window.
+	MaxRate         float64       `yaml:"max_rate"`
+	RateWindow      time.Duration `yaml:"rate_window"`
+	RateMinAttempts int           `yaml:"rate_min_attempts"`
+}
+
+// DrillsConfig holds the question counts of the practice drills.
+type DrillsConfig struct {
+	EarnedValue         DrillLimit `yaml:"earned_value"`
+	Pert                DrillLimit `yaml:"pert"`
+	StakeholderSalience DrillLimit `yaml:"stakeholder_salience"`
+	ProjectOperations   DrillLimit `yaml:"project_operations"`
+	TeamMotivation      DrillLimit `yaml:"team_motivation"`
+}
+
+// DrillLimit is the number of questions served when the client does not ask
+// for a count, and the most it may ask for.
+type DrillLimit struct {
+	Default int `yaml:"default"`
+	Max     int `yaml:"max"`
+}
+
+// Count resolves a requested drill length: zero or less means the default,
+// anything above the maximum is capped.
+func (l DrillLimit) Count(requested int) int {
+	if requested <= 0 {
+		requested = l.Default
+	}
+	if requested > l.Max {
+		requested = l.Max
+	}
+	return requested
+}
+
+type AdminConfig struct {
+	// Token guards /api/admin; the admin API is disabled when it is empty.
+	Token string `yaml:"token"`
+}
+
+type RegraderConfig struct {
+	// Interval is how often the worker checks an empty queue.
+	Interval time.Duration `yaml:"interval"`
+}
+
+// FeatureConfig switches optional parts of the application off. Disabled
+// routes are not registered and answer 404.
+type FeatureConfig struct {
+	Regrader        bool `yaml:"regrader"`
+	Cohorts         bool `yaml:"cohorts"`
+	QuestionReports bool `yaml:"question_reports"`
+	PDFReports      bool `yaml:"pdf_reports"`
+	Metrics         bool `yaml:"metrics"`
+	GroupSessions   bool `yaml:"group_sessions"`
+}
+
+// Default returns the development configuration used when nothing is set.
+func Default() *Config {
+	return &Config{
+		Env: EnvDevelopment,
+		Server: ServerConfig{
+			Port:              8080,
+			ReadHeaderTimeout: 5 * time.Second,
+			ReadTimeout:       15 * time.Second,
+			WriteTimeout:      60 * time.Second,
+			IdleTimeout:       120 * time.Second,
+			ShutdownTimeout:   30 * time.Second,
+		},
+		Database: DatabaseConfig{
+			URL:             "postgres://postgres:" + defaultDatabasePassword + "@localhost:5432/capm?sslmode=disable",
+			MaxConns:        10,
+			MinConns:        0,
+			MaxConnLifetime: time.Hour,
+			MaxConnIdleTime: 30 * time.Minute,
+			ConnectTimeout:  5 * time.Second,
+		},
+		Log: LogConfig{
+			Format: "json",
+			Level:  "info",
+		},
+		Exam: ExamConfig{
+			PassMark:        0.70,
+			HardDrillLength: 20,
+			Drills: DrillsConfig{
+				EarnedValue:         DrillLimit{Default: 10, Max: 50},
+				Pert:                DrillLimit{Default: 10, Max: 50},
+				StakeholderSalience: DrillLimit{Default: 10, Max: 50},
+				ProjectOperations:   DrillLimit{Default: 15, Max: 20},
+				TeamMotivation:      DrillLimit{Default: 20, Max: 20},
+			},
+			Exposure: ExposureConfig{
+				RecentAttempts:  3,
+				RecentWindow:    30 * 24 * time.Hour,
+				RecentWeight:    0.05,
+				MaxRate:         0.5,
+				RateWindow:      30 * 24 * time.Hour,
+				RateMinAttempts: 20,
+			},
+			ChallengeSecret: defaultChallengeSecret,
+		},
+		Regrader: RegraderConfig{
+			Interval: 30 * time.Second,
+		},
+		Features: FeatureConfig{
+			Regrader:        true,
+			Cohorts:         true,
+			QuestionReports: true,
+			PDFReports:      true,
+			Metrics:         true,
+			GroupSessions:   true,
+		},
+	}
+}
+
+// Load builds the configuration. The YAML file is named by -config or
+// CONFIG_FILE; environment variables override it and flags registered on fs
+// override both. args are parsed by fs, so callers may register their own
+// flags on it first.
+func Load(fs *flag.FlagSet, args []string) (*Config, error) {
+	cfg := Default()
+	bindings := cfg.bindings()
+
+	path := fs.String("config", os.Getenv("CONFIG_FILE"), "path to a YAML config file")
+	flagValues := make(map[string]string)
+	for _, b := range bindings {
+		if b.flag == "" {
+			continue
+		}
+		name := b.flag
+		fs.Func(name, b.usage+" (env "+b.env+")", func(value string) error {
+			flagValues[name] = value
+			return nil
+		})
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	if *path != "" {
+		if err := cfg.loadFile(*path); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, b := range bindings {
+		if value := os.Getenv(b.env); value != "" {
+			if err := setValue(b.target, value); err != nil {
+				return nil, fmt.Errorf("invalid %s: %v", b.env, err)
+			}
+		}
+	}
+	for _, b := range bindings {
+		if value, ok := flagValues[b.flag]; ok && b.flag != "" {
+			if err := setValue(b.target, value); err != nil {
+				return nil, fmt.Errorf("invalid -%s: %v", b.flag, err)
+			}
+		}
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// loadFile overlays the YAML file on cfg. Unknown keys are rejected so a
+// misspelt setting does not silently fall back to its default.
+func (c *Config) loadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %v", err)
+	}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(c); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("failed to parse config file %s: %v", path, err)
+	}
+	return nil
+}
+
+// Validate reports every invalid setting at once.
+func (c *Config) Validate() error {
+	var problems []string
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			problems = append(problems, fmt.Sprintf(format, args...))
+		}
+	}
+
+	check(c.Env == EnvDevelopment || c.Env == EnvProduction, "env must be development or production, got %q", c.Env)
+
+	check(c.Server.Port > 0 && c.Server.Port < 65536, "server.port must be between 1 and 65535")
+	for name, timeout := range map[string]time.Duration{
+		"server.read_header_timeout": c.Server.ReadHeaderTimeout,
+		"server.read_timeout":        c.Server.ReadTimeout,
+		"server.write_timeout":       c.Server.WriteTimeout,
+		"server.idle_timeout":        c.Server.IdleTimeout,
+		"server.shutdown_timeout":    c.Server.ShutdownTimeout,
+		"database.connect_timeout":   c.Database.ConnectTimeout,
+		"regrader.interval":          c.Regrader.Interval,
+		"exam.exposure.rate_window":  c.Exam.Exposure.RateWindow,
+	} {
+		check(timeout > 0, "%s must be positive", name)
+	}
+
+	check(c.Database.MaxConns > 0, "database.max_conns must be positive")
+	check(c.Database.MinConns >= 0 && c.Database.MinConns <= c.Database.MaxConns, "database.min_conns must be between 0 and database.max_conns")
+	check(c.Database.MaxConnLifetime >= 0 && c.Database.MaxConnIdleTime >= 0, "database connection lifetimes cannot be negative")
+	if dbConfig, err := pgconn.ParseConfig(c.Database.URL); err != nil {
+		check(false, "database.url is invalid: %v", err)
+	} else if c.Env == EnvProduction {
+		check(dbConfig.Password != "" && dbConfig.Password != defaultDatabasePassword,
+			"database.url uses the default development password; set a real one in production")
+	}
+
+	check(strings.EqualFold(c.Log.Format, "json") || strings.EqualFold(c.Log.Format, "text"), "log.format must be json or text, got %q", c.Log.Format)
+	switch strings.ToLower(c.Log.Level) {
+	case "debug", "info", "warn", "warning", "error":
+	default:
+		check(false, "log.level must be debug, info, warn or error, got %q", c.Log.Level)
+	}
+
+	check(c.Exam.PassMark > 0 && c.Exam.PassMark <= 1, "exam.pass_mark must be a share between 0 and 1, e.g. 0.7")
+	check(c.Exam.HardDrillLength > 0, "exam.hard_drill_length must be positive")
+	for name, limit := range map[string]DrillLimit{
+		"earned_value":         c.Exam.Drills.EarnedValue,
+		"pert":                 c.Exam.Drills.Pert,
+		"stakeholder_salience": c.Exam.Drills.StakeholderSalience,
+		"project_operations":   c.Exam.Drills.ProjectOperations,
+		"team_motivation":      c.Exam.Drills.TeamMotivation,
+	} {
+		check(limit.Default > 0 && limit.Default <= limit.Max, "exam.drills.%s needs 0 < default <= max", name)
+	}
+
+	exposure := c.Exam.Exposure
+	check(exposure.RecentAttempts >= 0 && exposure.RecentWindow >= 0, "exam.exposure.recent_attempts and recent_window cannot be negative")
+	check(exposure.RecentWeight >= 0 && exposure.RecentWeight <= 1, "exam.exposure.recent_weight must be between 0 and 1")
+	check(exposure.MaxRate > 0 && exposure.MaxRate <= 1, "exam.exposure.max_rate must be a share between 0 and 1")
+	check(exposure.RateMinAttempts >= 0, "exam.exposure.rate_min_attempts cannot be negative")
+
+	check(c.Exam.ChallengeSecret != "", "exam.challenge_secret is required")
+	if c.Env == EnvProduction {
+		check(c.Exam.ChallengeSecret != defaultChallengeSecret,
+			"exam.challenge_secret uses the development default; set a real one in production")
+	}
+
+	if len(problems) == 0 {
+		return nil
+	}
+	sort.Strings(problems)
+	return fmt.Errorf("invalid configuration: %s", strings.Join(problems, "; "))
+}
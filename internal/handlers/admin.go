@@ -0,0 +1,460 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"capm-exam-system/internal/models"
+	"capm-exam-system/internal/pdf"
+	"capm-exam-system/internal/service"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// requireAdmin guards the admin API with the configured admin token, sent
+// as a bearer token or in X-Admin-Token. The admin API is disabled when no
+// token is configured.
+func requireAdmin(token string) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if token == "" {
+				writeError(w, r, http.StatusForbidden, "Admin API is disabled")
+				return
+			}
+
+			provided := r.Header.Get("X-Admin-Token")
+			if provided == "" {
+				provided = strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+			}
+			if subtle.ConstantTimeCompare([]byte(provided), []byte(token)) != 1 {
+				writeError(w, r, http.StatusUnauthorized, "Invalid admin token")
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(service.WithAdminActor(r.Context())))
+		})
+	}
+}
+
+func (h *Handlers) setupAdminRoutes(api *mux.Router) {
+	admin := api.PathPrefix("/admin").Subrouter()
+	admin.Use(requireAdmin(h.adminToken))
+	admin.HandleFunc("/item-analysis", h.GetItemAnalysis).Methods("GET")
+	admin.HandleFunc("/item-analysis.csv", h.DownloadItemAnalysisCSV).Methods("GET")
+	if h.features.PDFReports {
+		admin.HandleFunc("/item-analysis.pdf", h.DownloadItemAnalysisPDF).Methods("GET")
+	}
+	admin.HandleFunc("/reports", h.ListQuestionReports).Methods("GET")
+	admin.HandleFunc("/reports/{reportId}", h.GetQuestionReport).Methods("GET")
+	admin.HandleFunc("/reports/{reportId}", h.UpdateQuestionReport).Methods("PATCH")
+	admin.HandleFunc("/reports/{reportId}/comments", h.AddQuestionReportComment).Methods("POST")
+	admin.HandleFunc("/regrade", h.QueueRegrade).Methods("POST")
+	admin.HandleFunc("/regrade", h.ListRegradeJobs).Methods("GET")
+	admin.HandleFunc("/regrade/{jobId}", h.GetRegradeJob).Methods("GET")
+	admin.HandleFunc("/attempts/{attemptId}/score-adjustments", h.GetScoreAdjustments).Methods("GET")
+	admin.HandleFunc("/audit", h.ListAuditEntries).Methods("GET")
+	admin.HandleFunc("/questions/{questionId}/family", h.SetQuestionFamily).Methods("PUT")
+	admin.HandleFunc("/enemies", h.ListEnemyPairs).Methods("GET")
+	admin.HandleFunc("/enemies", h.CreateEnemyPair).Methods("POST")
+	admin.HandleFunc("/enemies/{questionId}/{enemyId}", h.DeleteEnemyPair).Methods("DELETE")
+	admin.HandleFunc("/exposure", h.GetExposureReport).Methods("GET")
+}
+
+func itemAnalysisFilter(r *http.Request) service.ItemAnalysisFilter {
+	flagged, _ := strconv.ParseBool(r.URL.Query().Get("flagged"))
+	return service.ItemAnalysisFilter{
+		Domain:      r.URL.Query().Get("domain"),
+		FlaggedOnly: flagged,
+	}
+}
+
+func (h *Handlers) GetItemAnalysis(w http.ResponseWriter, r *http.Request) {
+	report, err := h.service.GetItemAnalysis(r.Context(), itemAnalysisFilter(r))
+	if err != nil {
+		writeServiceError(w, r, err, "Failed to build item analysis")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
+
+func (h *Handlers) DownloadItemAnalysisCSV(w http.ResponseWriter, r *http.Request) {
+	report, err := h.service.GetItemAnalysis(r.Context(), itemAnalysisFilter(r))
+	if err != nil {
+		writeServiceError(w, r, err, "Failed to build item analysis")
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv")
+	w.Header().Set("Content-Disposition", "attachment; filename=item-analysis.csv")
+
+	writer := csv.NewWriter(w)
+	writer.Write([]string{
+		"question_id", "domain", "served", "answered", "correct", "percent_correct",
+		"average_seconds", "median_seconds", "discrimination", "options", "flags", "prompt",
+	})
+	for _, item := range report.Items {
+		writer.Write([]string{
+			strconv.Itoa(item.QuestionID),
+			csvText(item.Domain),
+			strconv.Itoa(item.Served),
+			strconv.Itoa(item.Answered),
+			strconv.Itoa(item.Correct),
+			fmt.Sprintf("%.1f", item.PercentCorrect),
+			csvFloat(item.AverageSeconds, "%.1f"),
+			csvFloat(item.MedianSeconds, "%.1f"),
+			csvFloat(item.Discrimination, "%.3f"),
+			pdf.OptionSummary(item.Options),
+			strings.Join(item.Flags, ";"),
+			csvText(item.Prompt),
+		})
+	}
+	writer.Flush()
+}
+
+func (h *Handlers) DownloadItemAnalysisPDF(w http.ResponseWriter, r *http.Request) {
+	report, err := h.service.GetItemAnalysis(r.Context(), itemAnalysisFilter(r))
+	if err != nil {
+		writeServiceError(w, r, err, "Failed to build item analysis")
+		return
+	}
+
+	pdfBuffer, err := h.pdfService.GenerateItemAnalysisReport(report)
+	if err != nil {
+		writeServiceError(w, r, err, "Failed to generate PDF")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/pdf")
+	w.Header().Set("Content-Disposition", "attachment; filename=item-analysis.pdf")
+	w.Header().Set("Content-Length", strconv.Itoa(pdfBuffer.Len()))
+	w.Write(pdfBuffer.Bytes())
+}
+
+func (h *Handlers) ListQuestionReports(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	questionID := 0
+	if raw := query.Get("question_id"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil {
+			writeError(w, r, http.StatusBadRequest, "Invalid question ID")
+			return
+		}
+		questionID = parsed
+	}
+
+	reports, err := h.service.ListQuestionReports(r.Context(), query.Get("status"), query.Get("category"), questionID)
+	if err != nil {
+		writeServiceError(w, r, err, "Failed to load reports")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(reports)
+}
+
+func (h *Handlers) GetQuestionReport(w http.ResponseWriter, r *http.Request) {
+	reportID, err := uuid.Parse(mux.Vars(r)["reportId"])
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, "Invalid report ID")
+		return
+	}
+
+	report, err := h.service.GetQuestionReport(r.Context(), reportID)
+	if err != nil {
+		writeServiceError(w, r, err, "Failed to load report")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
+
+func (h *Handlers) UpdateQuestionReport(w http.ResponseWriter, r *http.Request) {
+	reportID, err := uuid.Parse(mux.Vars(r)["reportId"])
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, "Invalid report ID")
+		return
+	}
+
+	var update models.QuestionReportUpdate
+	if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+		writeError(w, r, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	report, err := h.service.UpdateQuestionReport(r.Context(), reportID, update)
+	if err != nil {
+		writeServiceError(w, r, err, "Failed to update report")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
+
+func (h *Handlers) AddQuestionReportComment(w http.ResponseWriter, r *http.Request) {
+	reportID, err := uuid.Parse(mux.Vars(r)["reportId"])
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, "Invalid report ID")
+		return
+	}
+
+	var req struct {
+		Author string `json:"author"`
+		Body   string `json:"body"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, r, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	comment, err := h.service.AddQuestionReportComment(r.Context(), reportID, req.Author, req.Body)
+	if err != nil {
+		writeServiceError(w, r, err, "Failed to add comment")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(comment)
+}
+
+func (h *Handlers) QueueRegrade(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		QuestionID *int   `json:"question_id"`
+		Reason     string `json:"reason"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, r, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	job, err := h.service.QueueRegrade(r.Context(), req.QuestionID, strings.TrimSpace(req.Reason))
+	if err != nil {
+		writeServiceError(w, r, err, "Failed to queue regrade")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(job)
+}
+
+func (h *Handlers) ListRegradeJobs(w http.ResponseWriter, r *http.Request) {
+	jobs, err := h.service.ListRegradeJobs(r.Context())
+	if err != nil {
+		writeServiceError(w, r, err, "Failed to load regrade jobs")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(jobs)
+}
+
+func (h *Handlers) GetRegradeJob(w http.ResponseWriter, r *http.Request) {
+	jobID, err := uuid.Parse(mux.Vars(r)["jobId"])
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, "Invalid job ID")
+		return
+	}
+
+	job, err := h.service.GetRegradeJob(r.Context(), jobID)
+	if err != nil {
+		writeServiceError(w, r, err, "Failed to load regrade job")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(job)
+}
+
+func (h *Handlers) GetScoreAdjustments(w http.ResponseWriter, r *http.Request) {
+	attemptID, err := uuid.Parse(mux.Vars(r)["attemptId"])
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, "Invalid attempt ID")
+		return
+	}
+
+	adjustments, err := h.service.GetScoreAdjustments(r.Context(), attemptID)
+	if err != nil {
+		writeServiceError(w, r, err, "Failed to load score adjustments")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(adjustments)
+}
+
+// ListAuditEntries serves the audit log newest first. Filters: user_id,
+// entity_type, entity_id, action, since and until (RFC 3339) and limit.
+func (h *Handlers) ListAuditEntries(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	filter := models.AuditFilter{
+		EntityType: query.Get("entity_type"),
+		EntityID:   query.Get("entity_id"),
+		Action:     query.Get("action"),
+	}
+
+	if raw := query.Get("user_id"); raw != "" {
+		userID, err := uuid.Parse(raw)
+		if err != nil {
+			writeError(w, r, http.StatusBadRequest, "Invalid user ID")
+			return
+		}
+		filter.UserID = &userID
+	}
+	for name, target := range map[string]**time.Time{"since": &filter.Since, "until": &filter.Until} {
+		raw := query.Get(name)
+		if raw == "" {
+			continue
+		}
+		parsed, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			writeError(w, r, http.StatusBadRequest, fmt.Sprintf("Invalid %s time, expected RFC 3339", name))
+			return
+		}
+		*target = &parsed
+	}
+	if raw := query.Get("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit < 1 {
+			writeError(w, r, http.StatusBadRequest, "Invalid limit")
+			return
+		}
+		filter.Limit = limit
+	}
+
+	entries, err := h.service.ListAuditEntries(r.Context(), filter)
+	if err != nil {
+		writeServiceError(w, r, err, "Failed to load audit log")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(entries)
+}
+
+// csvText quotes free text for a CSV cell: text a spreadsheet would read as
+// a formula is prefixed with an apostrophe, which shows it as typed.
+func csvText(s string) string {
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
+
+func csvFloat(v *float64, format string) string {
+	if v == nil {
+		return ""
+	}
+	return fmt.Sprintf(format, *v)
+}
+
+func (h *Handlers) SetQuestionFamily(w http.ResponseWriter, r *http.Request) {
+	questionID, err := strconv.Atoi(mux.Vars(r)["questionId"])
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, "Invalid question ID")
+		return
+	}
+
+	var req struct {
+		Family string `json:"family"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, r, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	family, err := h.service.SetQuestionFamily(r.Context(), questionID, req.Family)
+	if err != nil {
+		writeServiceError(w, r, err, "Failed to set question family")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(family)
+}
+
+func (h *Handlers) ListEnemyPairs(w http.ResponseWriter, r *http.Request) {
+	questionID := 0
+	if raw := r.URL.Query().Get("question_id"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil {
+			writeError(w, r, http.StatusBadRequest, "Invalid question ID")
+			return
+		}
+		questionID = parsed
+	}
+
+	pairs, err := h.service.ListEnemyPairs(r.Context(), questionID)
+	if err != nil {
+		writeServiceError(w, r, err, "Failed to load enemy pairs")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(pairs)
+}
+
+func (h *Handlers) CreateEnemyPair(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		QuestionID int    `json:"question_id"`
+		EnemyID    int    `json:"enemy_id"`
+		Reason     string `json:"reason"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, r, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	pair, err := h.service.CreateEnemyPair(r.Context(), req.QuestionID, req.EnemyID, req.Reason)
+	if err != nil {
+		writeServiceError(w, r, err, "Failed to create enemy pair")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(pair)
+}
+
+func (h *Handlers) DeleteEnemyPair(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	questionID, err := strconv.Atoi(vars["questionId"])
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, "Invalid question ID")
+		return
+	}
+	enemyID, err := strconv.Atoi(vars["enemyId"])
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, "Invalid enemy question ID")
+		return
+	}
+
+	if err := h.service.DeleteEnemyPair(r.Context(), questionID, enemyID); err != nil {
+		writeServiceError(w, r, err, "Failed to delete enemy pair")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handlers) GetExposureReport(w http.ResponseWriter, r *http.Request) {
+	report, err := h.service.GetExposureReport(r.Context())
+	if err != nil {
+		writeServiceError(w, r, err, "Failed to build exposure report")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
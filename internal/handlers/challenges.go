@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// CreateChallenge shares one of the user's attempts behind a signed link.
+func (h *Handlers) CreateChallenge(w http.ResponseWriter, r *http.Request) {
+	attemptID, err := uuid.Parse(mux.Vars(r)["attemptId"])
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, "Invalid attempt ID")
+		return
+	}
+
+	var req struct {
+		UserID string `json:"user_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, r, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	userID, err := uuid.Parse(req.UserID)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	link, err := h.service.CreateChallenge(r.Context(), userID, attemptID)
+	if err != nil {
+		writeServiceError(w, r, err, "Failed to create challenge")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(link)
+}
+
+func (h *Handlers) GetChallenge(w http.ResponseWriter, r *http.Request) {
+	summary, err := h.service.GetChallenge(r.Context(), mux.Vars(r)["token"])
+	if err != nil {
+		writeServiceError(w, r, err, "Failed to load challenge")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(summary)
+}
+
+// StartChallenge starts the challenge's frozen attempt for the named user.
+func (h *Handlers) StartChallenge(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Email string `json:"email"`
+		Name  string `json:"name"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, r, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if req.Email == "" || req.Name == "" {
+		writeError(w, r, http.StatusBadRequest, "Email and name are required")
+		return
+	}
+
+	user, err := h.service.GetOrCreateUser(r.Context(), req.Email, req.Name)
+	if err != nil {
+		writeServiceError(w, r, err, "Failed to create user")
+		return
+	}
+
+	attempt, err := h.service.StartChallenge(r.Context(), user.ID, mux.Vars(r)["token"])
+	if err != nil {
+		writeServiceError(w, r, err, "Failed to start challenge")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"attempt_id":         attempt.ID,
+		"user_id":            user.ID,
+		"started_at":         attempt.StartedAt,
+		"question_count":     attempt.MaxScore,
+		"time_limit_seconds": attempt.TimeLimitSeconds,
+		"feedback":           attempt.Feedback,
+	})
+}
+
+func (h *Handlers) ChallengePage(w http.ResponseWriter, r *http.Request) {
+	http.ServeFile(w, r, "./web/templates/challenge.html")
+}
@@ -0,0 +1,184 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+func (h *Handlers) CreateCohort(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Email       string `json:"email"`
+		Name        string `json:"name"`
+		CohortName  string `json:"cohort_name"`
+		Description string `json:"description"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, r, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if req.Email == "" || req.Name == "" {
+		writeError(w, r, http.StatusBadRequest, "Email and name are required")
+		return
+	}
+
+	instructor, err := h.service.GetOrCreateUser(r.Context(), req.Email, req.Name)
+	if err != nil {
+		writeServiceError(w, r, err, "Failed to create user")
+		return
+	}
+
+	cohort, err := h.service.CreateCohort(r.Context(), instructor.ID, req.CohortName, req.Description)
+	if err != nil {
+		writeServiceError(w, r, err, "Failed to create cohort")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(cohort)
+}
+
+func (h *Handlers) JoinCohort(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Email      string `json:"email"`
+		Name       string `json:"name"`
+		InviteCode string `json:"invite_code"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, r, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if req.Email == "" || req.Name == "" || req.InviteCode == "" {
+		writeError(w, r, http.StatusBadRequest, "Email, name and invite code are required")
+		return
+	}
+
+	user, err := h.service.GetOrCreateUser(r.Context(), req.Email, req.Name)
+	if err != nil {
+		writeServiceError(w, r, err, "Failed to create user")
+		return
+	}
+
+	cohort, err := h.service.JoinCohort(r.Context(), user.ID, req.InviteCode)
+	if err != nil {
+		writeServiceError(w, r, err, "Failed to join cohort")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"user_id": user.ID,
+		"cohort":  cohort,
+	})
+}
+
+func (h *Handlers) GetUserCohorts(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	userID, err := uuid.Parse(vars["userId"])
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	cohorts, err := h.service.GetUserCohorts(r.Context(), userID)
+	if err != nil {
+		writeServiceError(w, r, err, "Failed to load cohorts")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(cohorts)
+}
+
+func (h *Handlers) CreateCohortAssignment(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	cohortID, err := uuid.Parse(vars["cohortId"])
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, "Invalid cohort ID")
+		return
+	}
+
+	var req struct {
+		InstructorID string     `json:"instructor_id"`
+		Kind         string     `json:"kind"`
+		Title        string     `json:"title"`
+		DueAt        *time.Time `json:"due_at"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, r, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	instructorID, err := uuid.Parse(req.InstructorID)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, "Invalid instructor ID")
+		return
+	}
+
+	assignment, err := h.service.CreateCohortAssignment(r.Context(), cohortID, instructorID, req.Kind, req.Title, req.DueAt)
+	if err != nil {
+		writeServiceError(w, r, err, "Failed to create assignment")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(assignment)
+}
+
+func (h *Handlers) GetCohortAssignments(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	cohortID, err := uuid.Parse(vars["cohortId"])
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, "Invalid cohort ID")
+		return
+	}
+
+	userID, err := uuid.Parse(r.URL.Query().Get("user_id"))
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	assignments, err := h.service.GetCohortAssignments(r.Context(), cohortID, userID)
+	if err != nil {
+		writeServiceError(w, r, err, "Failed to load assignments")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(assignments)
+}
+
+func (h *Handlers) GetCohortReport(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	cohortID, err := uuid.Parse(vars["cohortId"])
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, "Invalid cohort ID")
+		return
+	}
+
+	instructorID, err := uuid.Parse(r.URL.Query().Get("instructor_id"))
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, "Invalid instructor ID")
+		return
+	}
+
+	report, err := h.service.GetCohortReport(r.Context(), cohortID, instructorID)
+	if err != nil {
+		writeServiceError(w, r, err, "Failed to build cohort report")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"capm-exam-system/internal/logging"
+	"capm-exam-system/internal/service"
+)
+
+// errorEnvelope is the body of every API error response.
+type errorEnvelope struct {
+	Error errorDetail `json:"error"`
+}
+
+type errorDetail struct {
+	Code      string `json:"code"`
+	Message   string `json:"message"`
+	RequestID string `json:"request_id,omitempty"`
+}
+
+type apiError struct {
+	status  int
+	code    string
+	message string
+}
+
+// serviceErrors maps service sentinel errors to what the client sees.
+var serviceErrors = []struct {
+	err error
+	apiError
+}{
+	{service.ErrAttemptNotFound, apiError{http.StatusNotFound, "attempt_not_found", "Attempt not found"}},
+	{service.ErrAttemptAlreadyClosed, apiError{http.StatusConflict, "attempt_closed", "Attempt already completed"}},
+	{service.ErrAttemptForbidden, apiError{http.StatusForbidden, "attempt_forbidden", "Attempt does not belong to user"}},
+	{service.ErrAttemptNotSubmitted, apiError{http.StatusConflict, "attempt_not_submitted", "Exam not yet submitted"}},
+	{service.ErrExamNotFound, apiError{http.StatusNotFound, "exam_not_found", "Exam not found"}},
+	{service.ErrAttemptNotSectioned, apiError{http.StatusBadRequest, "attempt_not_sectioned", "Attempt is not divided into sections"}},
+	{service.ErrSectionClosed, apiError{http.StatusConflict, "section_closed", "Section is already closed"}},
+	{service.ErrSectionNotOpen, apiError{http.StatusConflict, "section_not_open", "Section is not open"}},
+	{service.ErrEventBatchTooLarge, apiError{http.StatusRequestEntityTooLarge, "event_batch_too_large", "Too many events in one batch"}},
+	{service.ErrCohortNotFound, apiError{http.StatusNotFound, "cohort_not_found", "Cohort not found"}},
+	{service.ErrCohortForbidden, apiError{http.StatusForbidden, "cohort_forbidden", "User cannot access this cohort"}},
+	{service.ErrInvalidCohort, apiError{http.StatusBadRequest, "invalid_cohort", "Cohort name is required"}},
+	{service.ErrInviteCodeInvalid, apiError{http.StatusNotFound, "invite_code_invalid", "Invite code not recognised"}},
+	{service.ErrInviteCodeExhausted, apiError{http.StatusServiceUnavailable, "invite_code_exhausted", "Could not generate an unused code; please try again"}},
+	{service.ErrInvalidAssignment, apiError{http.StatusBadRequest, "invalid_assignment", "Kind must be one of quiz, exam, hard or pmp"}},
+	{service.ErrReportNotFound, apiError{http.StatusNotFound, "report_not_found", "Report not found"}},
+	{service.ErrInvalidReport, apiError{http.StatusBadRequest, "invalid_report", "Category must be one of wrong_key, ambiguous, typo or outdated; status one of open, in_review, resolved or rejected; comments need an author and body"}},
+	{service.ErrInvalidKeyCorrection, apiError{http.StatusBadRequest, "invalid_key_correction", "Corrected choices must belong to the question and are only accepted when resolving a wrong-key report"}},
+	{service.ErrQuestionNotInAttempt, apiError{http.StatusBadRequest, "question_not_in_attempt", "Question was not part of this attempt"}},
+	{service.ErrQuestionNotFound, apiError{http.StatusNotFound, "question_not_found", "Question not found"}},
+	{service.ErrRegradeJobNotFound, apiError{http.StatusNotFound, "regrade_job_not_found", "Regrade job not found"}},
+	{service.ErrInvalidFamily, apiError{http.StatusBadRequest, "invalid_family", "Family must be at most 100 characters"}},
+	{service.ErrInvalidEnemyPair, apiError{http.StatusBadRequest, "invalid_enemy_pair", "A question cannot be its own enemy"}},
+	{service.ErrEnemyPairNotFound, apiError{http.StatusNotFound, "enemy_pair_not_found", "Enemy pair not found"}},
+	{service.ErrInvalidExamLength, apiError{http.StatusBadRequest, "invalid_exam_length", "Custom mocks are capm or pmp with 30, 60 or 90 questions"}},
+	{service.ErrInvalidPracticeQuiz, apiError{http.StatusBadRequest, "invalid_practice_quiz", "Practice quizzes need known domains, 1 to 100 questions, a time limit of at most 240 minutes and valid question_type, difficulty, source and feedback values"}},
+	{service.ErrNotEnoughQuestions, apiError{http.StatusConflict, "not_enough_questions", "Not enough questions match these options; choose more domains, fewer questions or fewer filters"}},
+	{service.ErrFeedbackNotImmediate, apiError{http.StatusConflict, "feedback_not_immediate", "Answers to this attempt are revealed after submission"}},
+	{service.ErrTimeExpired, apiError{http.StatusConflict, "time_expired", "The time limit for this attempt has passed"}},
+	{service.ErrEmptyAnswer, apiError{http.StatusBadRequest, "empty_answer", "Select at least one choice of the question"}},
+	{service.ErrUserNotFound, apiError{http.StatusNotFound, "user_not_found", "User not found"}},
+	{service.ErrBookmarkNotFound, apiError{http.StatusNotFound, "bookmark_not_found", "Bookmark not found"}},
+	{service.ErrInvalidReviewWindow, apiError{http.StatusBadRequest, "invalid_review_window", "Days must be between 1 and 365"}},
+	{service.ErrNothingToReview, apiError{http.StatusConflict, "nothing_to_review", "There are no questions to review"}},
+	{service.ErrChallengeNotFound, apiError{http.StatusNotFound, "challenge_not_found", "Challenge link is invalid or no longer exists"}},
+	{service.ErrNoQuestionSet, apiError{http.StatusConflict, "no_question_set", "This attempt predates stored question sets and cannot be shared"}},
+	{service.ErrGroupSessionNotFound, apiError{http.StatusNotFound, "group_session_not_found", "Group session not found"}},
+	{service.ErrGroupSessionForbidden, apiError{http.StatusForbidden, "group_session_forbidden", "User is not the instructor or a participant of this session"}},
+	{service.ErrInvalidGroupSession, apiError{http.StatusBadRequest, "invalid_group_session", "Kind must be one of quiz, exam, hard or pmp and the duration at most 300 minutes"}},
+	{service.ErrJoinCodeInvalid, apiError{http.StatusNotFound, "join_code_invalid", "Join code not recognised"}},
+	{service.ErrGroupSessionStarted, apiError{http.StatusConflict, "group_session_started", "The session has already started"}},
+	{service.ErrGroupSessionNotRunning, apiError{http.StatusConflict, "group_session_not_running", "The session is not running or its time is up"}},
+	{service.ErrGroupSessionEnded, apiError{http.StatusConflict, "group_session_ended", "The session has ended"}},
+	{service.ErrInvalidExtension, apiError{http.StatusBadRequest, "invalid_extension", "Extensions are 1 to 60 minutes"}},
+}
+
+// statusCodes names the generic error code for a status.
+var statusCodes = map[int]string{
+	http.StatusBadRequest:            "bad_request",
+	http.StatusUnauthorized:          "unauthorized",
+	http.StatusForbidden:             "forbidden",
+	http.StatusNotFound:              "not_found",
+	http.StatusConflict:              "conflict",
+	http.StatusRequestEntityTooLarge: "payload_too_large",
+	http.StatusInternalServerError:   "internal",
+}
+
+// writeError sends a JSON error envelope with the given status and message.
+func writeError(w http.ResponseWriter, r *http.Request, status int, message string) {
+	code, ok := statusCodes[status]
+	if !ok {
+		code = "error"
+	}
+	writeAPIError(w, r, apiError{status: status, code: code, message: message})
+}
+
+// writeServiceError reports a failed service call. Sentinel errors become
+// client errors; anything else is logged and answered with a 500 carrying
+// only the fallback message, so internal details never reach the client.
+func writeServiceError(w http.ResponseWriter, r *http.Request, err error, fallback string) {
+	for _, mapping := range serviceErrors {
+		if errors.Is(err, mapping.err) {
+			writeAPIError(w, r, mapping.apiError)
+			return
+		}
+	}
+
+	slog.ErrorContext(r.Context(), fallback, "error", err)
+	writeError(w, r, http.StatusInternalServerError, fallback)
+}
+
+func writeAPIError(w http.ResponseWriter, r *http.Request, apiErr apiError) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(apiErr.status)
+	json.NewEncoder(w).Encode(errorEnvelope{Error: errorDetail{
+		Code:      apiErr.code,
+		Message:   apiErr.message,
+		RequestID: logging.RequestID(r.Context()),
+	}})
+}
@@ -1,45 +1,98 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
-	"errors"
 	"net/http"
 	"strconv"
 
+	"capm-exam-system/internal/config"
+	"capm-exam-system/internal/metrics"
 	"capm-exam-system/internal/models"
 	"capm-exam-system/internal/pdf"
 	"capm-exam-system/internal/service"
 
 	"github.com/google/uuid"
 	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
+// maxIdempotencyKeyLength matches the column the key is stored in.
+const maxIdempotencyKeyLength = 255
+
 type Handlers struct {
 	service    *service.Service
 	pdfService *pdf.PDFService
+	features   config.FeatureConfig
+	adminToken string
+	// streams is cancelled at shutdown to end open event streams, which
+	// would otherwise hold the drain until its timeout
+	streams      context.Context
+	closeStreams context.CancelFunc
 }
 
-func New(service *service.Service, pdfService *pdf.PDFService) *Handlers {
+func New(service *service.Service, pdfService *pdf.PDFService, cfg *config.Config) *Handlers {
+	streams, closeStreams := context.WithCancel(context.Background())
 	return &Handlers{
-		service:    service,
-		pdfService: pdfService,
+		service:      service,
+		pdfService:   pdfService,
+		features:     cfg.Features,
+		adminToken:   cfg.Admin.Token,
+		streams:      streams,
+		closeStreams: closeStreams,
 	}
 }
 
+// CloseStreams ends every open event stream. Register it with
+// http.Server.RegisterOnShutdown.
+func (h *Handlers) CloseStreams() {
+	h.closeStreams()
+}
+
 func (h *Handlers) SetupRoutes() *mux.Router {
 	r := mux.NewRouter()
+	r.Use(withRequestID, withTracing, withAccessLog, withMetrics)
+
+	// Operational endpoints
+	if h.features.Metrics {
+		r.Handle("/metrics", promhttp.HandlerFor(metrics.Registry, promhttp.HandlerOpts{})).Methods("GET")
+	}
+	r.HandleFunc("/healthz", h.Healthz).Methods("GET")
+	r.HandleFunc("/readyz", h.Readyz).Methods("GET")
 
 	// API routes
 	api := r.PathPrefix("/api").Subrouter()
 	api.HandleFunc("/exams/start", h.StartExam).Methods("POST")
+	api.HandleFunc("/exams/custom/start", h.StartCustomExam).Methods("POST")
 	api.HandleFunc("/quiz/start", h.StartShortQuiz).Methods("POST")
 	api.HandleFunc("/hard/start", h.StartHardDrill).Methods("POST")
 	api.HandleFunc("/pmp/start", h.StartPmpExam).Methods("POST")
+	api.HandleFunc("/practice/domains", h.GetPracticeDomains).Methods("GET")
+	api.HandleFunc("/practice/start", h.StartPracticeQuiz).Methods("POST")
 	api.HandleFunc("/exams/{attemptId}/questions", h.GetExamQuestions).Methods("GET")
+	api.HandleFunc("/exams/{attemptId}/questions/{questionId}/answer", h.AnswerQuestion).Methods("POST")
+	api.HandleFunc("/exams/{attemptId}/settings", h.GetAttemptSettings).Methods("GET")
 	api.HandleFunc("/exams/{attemptId}/submit", h.SubmitExam).Methods("POST")
 	api.HandleFunc("/exams/{attemptId}/results", h.GetExamResults).Methods("GET")
-	api.HandleFunc("/exams/{attemptId}/report.pdf", h.DownloadReport).Methods("GET")
+	api.HandleFunc("/exams/{attemptId}/events", h.RecordAttemptEvents).Methods("POST")
+	api.HandleFunc("/exams/{attemptId}/answer-changes", h.GetAttemptAnswerChanges).Methods("GET")
+	api.HandleFunc("/exams/{attemptId}/sections", h.GetSectionState).Methods("GET")
+	api.HandleFunc("/exams/{attemptId}/sections/open", h.OpenSection).Methods("POST")
+	api.HandleFunc("/exams/{attemptId}/sections/{index}/submit", h.SubmitSection).Methods("POST")
+	api.HandleFunc("/exams/{attemptId}/retake-missed", h.StartMissedRetake).Methods("POST")
+	api.HandleFunc("/exams/{attemptId}/challenge", h.CreateChallenge).Methods("POST")
+	api.HandleFunc("/challenges/{token}", h.GetChallenge).Methods("GET")
+	api.HandleFunc("/challenges/{token}/start", h.StartChallenge).Methods("POST")
 	api.HandleFunc("/users/{userId}/attempts", h.GetUserAttempts).Methods("GET")
+	api.HandleFunc("/users/{userId}/answer-changes", h.GetUserAnswerChanges).Methods("GET")
+	api.HandleFunc("/users/{userId}/progress", h.GetUserProgress).Methods("GET")
+	api.HandleFunc("/users/{userId}/notifications", h.GetUserNotifications).Methods("GET")
+	api.HandleFunc("/users/{userId}/notifications/read", h.MarkUserNotificationsRead).Methods("POST")
+	api.HandleFunc("/users/{userId}/bookmarks", h.GetUserBookmarks).Methods("GET")
+	api.HandleFunc("/users/{userId}/bookmarks/start", h.StartBookmarkReview).Methods("POST")
+	api.HandleFunc("/users/{userId}/bookmarks/{questionId}", h.AddBookmark).Methods("PUT")
+	api.HandleFunc("/users/{userId}/bookmarks/{questionId}", h.RemoveBookmark).Methods("DELETE")
+	api.HandleFunc("/users/{userId}/missed/start", h.StartMissedReview).Methods("POST")
 	api.HandleFunc("/users/login", h.LoginUser).Methods("POST")
 	api.HandleFunc("/attempts/{attemptId}", h.DeleteAttempt).Methods("DELETE")
 	api.HandleFunc("/earned-value/questions", h.GetEarnedValueQuestions).Methods("GET")
@@ -47,6 +100,31 @@ func (h *Handlers) SetupRoutes() *mux.Router {
 	api.HandleFunc("/stakeholder-salience/questions", h.GetStakeholderSalienceQuestions).Methods("GET")
 	api.HandleFunc("/project-operations/questions", h.GetProjectOperationsQuestions).Methods("GET")
 	api.HandleFunc("/team-motivation/questions", h.GetTeamMotivationQuestions).Methods("GET")
+	api.HandleFunc("/drills/questions/{questionId}/check", h.CheckDrillAnswer).Methods("POST")
+	if h.features.PDFReports {
+		api.HandleFunc("/exams/{attemptId}/report.pdf", h.DownloadReport).Methods("GET")
+	}
+	if h.features.QuestionReports {
+		api.HandleFunc("/questions/{questionId}/reports", h.ReportQuestionIssue).Methods("POST")
+	}
+	if h.features.Cohorts {
+		api.HandleFunc("/users/{userId}/cohorts", h.GetUserCohorts).Methods("GET")
+		api.HandleFunc("/cohorts", h.CreateCohort).Methods("POST")
+		api.HandleFunc("/cohorts/join", h.JoinCohort).Methods("POST")
+		api.HandleFunc("/cohorts/{cohortId}/assignments", h.CreateCohortAssignment).Methods("POST")
+		api.HandleFunc("/cohorts/{cohortId}/assignments", h.GetCohortAssignments).Methods("GET")
+		api.HandleFunc("/cohorts/{cohortId}/report", h.GetCohortReport).Methods("GET")
+	}
+	if h.features.GroupSessions {
+		api.HandleFunc("/sessions", h.CreateGroupSession).Methods("POST")
+		api.HandleFunc("/sessions/join", h.JoinGroupSession).Methods("POST")
+		api.HandleFunc("/sessions/{sessionId}/start", h.StartGroupSession).Methods("POST")
+		api.HandleFunc("/sessions/{sessionId}/extend", h.ExtendGroupSession).Methods("POST")
+		api.HandleFunc("/sessions/{sessionId}/end", h.EndGroupSession).Methods("POST")
+		api.HandleFunc("/sessions/{sessionId}/progress", h.StreamGroupSessionProgress).Methods("GET")
+		api.HandleFunc("/sessions/{sessionId}/events", h.StreamGroupSessionEvents).Methods("GET")
+	}
+	h.setupAdminRoutes(api)
 
 	// Static files
 	r.PathPrefix("/static/").Handler(http.StripPrefix("/static/", http.FileServer(http.Dir("./web/static/"))))
@@ -56,6 +134,11 @@ func (h *Handlers) SetupRoutes() *mux.Router {
 	r.HandleFunc("/exam/{attemptId}", h.ExamPage).Methods("GET")
 	r.HandleFunc("/quiz/{attemptId}", h.QuizPage).Methods("GET")
 	r.HandleFunc("/results/{attemptId}", h.ResultsPage).Methods("GET")
+	r.HandleFunc("/challenge/{token}", h.ChallengePage).Methods("GET")
+	if h.features.GroupSessions {
+		r.HandleFunc("/sessions", h.GroupSessionsPage).Methods("GET")
+		r.HandleFunc("/sessions/join", h.JoinGroupSessionPage).Methods("GET")
+	}
 	r.HandleFunc("/formula", h.FormulaPage).Methods("GET")
 	r.HandleFunc("/earned-value-drill", h.EarnedValueDrillPage).Methods("GET")
 	r.HandleFunc("/pert-drill", h.PertDrillPage).Methods("GET")
@@ -71,29 +154,30 @@ func (h *Handlers) StartExam(w http.ResponseWriter, r *http.Request) {
 	var req struct {
 		Email string `json:"email"`
 		Name  string `json:"name"`
+		Tutor bool   `json:"tutor"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		writeError(w, r, http.StatusBadRequest, "Invalid request body")
 		return
 	}
 
 	if req.Email == "" || req.Name == "" {
-		http.Error(w, "Email and name are required", http.StatusBadRequest)
+		writeError(w, r, http.StatusBadRequest, "Email and name are required")
 		return
 	}
 
 	// Get or create user
 	user, err := h.service.GetOrCreateUser(r.Context(), req.Email, req.Name)
 	if err != nil {
-		http.Error(w, "Failed to create user", http.StatusInternalServerError)
+		writeServiceError(w, r, err, "Failed to create user")
 		return
 	}
 
 	// Start exam
-	attempt, err := h.service.StartExam(r.Context(), user.ID)
+	attempt, err := h.service.StartExam(r.Context(), user.ID, req.Tutor)
 	if err != nil {
-		http.Error(w, "Failed to start exam", http.StatusInternalServerError)
+		writeServiceError(w, r, err, "Failed to start exam")
 		return
 	}
 
@@ -109,29 +193,30 @@ func (h *Handlers) StartShortQuiz(w http.ResponseWriter, r *http.Request) {
 	var req struct {
 		Email string `json:"email"`
 		Name  string `json:"name"`
+		Tutor bool   `json:"tutor"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		writeError(w, r, http.StatusBadRequest, "Invalid request body")
 		return
 	}
 
 	if req.Email == "" || req.Name == "" {
-		http.Error(w, "Email and name are required", http.StatusBadRequest)
+		writeError(w, r, http.StatusBadRequest, "Email and name are required")
 		return
 	}
 
 	// Get or create user
 	user, err := h.service.GetOrCreateUser(r.Context(), req.Email, req.Name)
 	if err != nil {
-		http.Error(w, "Failed to create user", http.StatusInternalServerError)
+		writeServiceError(w, r, err, "Failed to create user")
 		return
 	}
 
 	// Start short quiz
-	attempt, err := h.service.StartShortQuiz(r.Context(), user.ID)
+	attempt, err := h.service.StartShortQuiz(r.Context(), user.ID, req.Tutor)
 	if err != nil {
-		http.Error(w, "Failed to start short quiz", http.StatusInternalServerError)
+		writeServiceError(w, r, err, "Failed to start short quiz")
 		return
 	}
 
@@ -147,27 +232,28 @@ func (h *Handlers) StartPmpExam(w http.ResponseWriter, r *http.Request) {
 	var req struct {
 		Email string `json:"email"`
 		Name  string `json:"name"`
+		Tutor bool   `json:"tutor"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		writeError(w, r, http.StatusBadRequest, "Invalid request body")
 		return
 	}
 
 	if req.Email == "" || req.Name == "" {
-		http.Error(w, "Email and name are required", http.StatusBadRequest)
+		writeError(w, r, http.StatusBadRequest, "Email and name are required")
 		return
 	}
 
 	user, err := h.service.GetOrCreateUser(r.Context(), req.Email, req.Name)
 	if err != nil {
-		http.Error(w, "Failed to create user", http.StatusInternalServerError)
+		writeServiceError(w, r, err, "Failed to create user")
 		return
 	}
 
-	attempt, err := h.service.StartPMPExam(r.Context(), user.ID)
+	attempt, err := h.service.StartPMPExam(r.Context(), user.ID, req.Tutor)
 	if err != nil {
-		http.Error(w, "Failed to start PMP exam", http.StatusInternalServerError)
+		writeServiceError(w, r, err, "Failed to start PMP exam")
 		return
 	}
 
@@ -179,31 +265,74 @@ func (h *Handlers) StartPmpExam(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// StartCustomExam starts a shorter mock with the full exam's domain
+// proportions: exam "capm" (default) or "pmp", question_count 30, 60 or 90.
+func (h *Handlers) StartCustomExam(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Email         string `json:"email"`
+		Name          string `json:"name"`
+		Exam          string `json:"exam"`
+		QuestionCount int    `json:"question_count"`
+		Tutor         bool   `json:"tutor"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, r, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if req.Email == "" || req.Name == "" {
+		writeError(w, r, http.StatusBadRequest, "Email and name are required")
+		return
+	}
+
+	user, err := h.service.GetOrCreateUser(r.Context(), req.Email, req.Name)
+	if err != nil {
+		writeServiceError(w, r, err, "Failed to create user")
+		return
+	}
+
+	attempt, err := h.service.StartCustomExam(r.Context(), user.ID, req.Exam, req.QuestionCount, req.Tutor)
+	if err != nil {
+		writeServiceError(w, r, err, "Failed to start custom exam")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"attempt_id":     attempt.ID,
+		"user_id":        user.ID,
+		"started_at":     attempt.StartedAt,
+		"question_count": attempt.MaxScore,
+	})
+}
+
 func (h *Handlers) StartHardDrill(w http.ResponseWriter, r *http.Request) {
 	var req struct {
 		Email string `json:"email"`
 		Name  string `json:"name"`
+		Tutor bool   `json:"tutor"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		writeError(w, r, http.StatusBadRequest, "Invalid request body")
 		return
 	}
 
 	if req.Email == "" || req.Name == "" {
-		http.Error(w, "Email and name are required", http.StatusBadRequest)
+		writeError(w, r, http.StatusBadRequest, "Email and name are required")
 		return
 	}
 
 	user, err := h.service.GetOrCreateUser(r.Context(), req.Email, req.Name)
 	if err != nil {
-		http.Error(w, "Failed to create user", http.StatusInternalServerError)
+		writeServiceError(w, r, err, "Failed to create user")
 		return
 	}
 
-	attempt, err := h.service.StartHardDrill(r.Context(), user.ID)
+	attempt, err := h.service.StartHardDrill(r.Context(), user.ID, req.Tutor)
 	if err != nil {
-		http.Error(w, "Failed to start hard drill", http.StatusInternalServerError)
+		writeServiceError(w, r, err, "Failed to start hard drill")
 		return
 	}
 
@@ -222,24 +351,24 @@ func (h *Handlers) LoginUser(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		writeError(w, r, http.StatusBadRequest, "Invalid request body")
 		return
 	}
 
 	if req.Email == "" || req.Name == "" {
-		http.Error(w, "Email and name are required", http.StatusBadRequest)
+		writeError(w, r, http.StatusBadRequest, "Email and name are required")
 		return
 	}
 
 	user, err := h.service.GetOrCreateUser(r.Context(), req.Email, req.Name)
 	if err != nil {
-		http.Error(w, "Failed to create user", http.StatusInternalServerError)
+		writeServiceError(w, r, err, "Failed to create user")
 		return
 	}
 
 	attempts, err := h.service.GetUserAttemptHistory(r.Context(), user.ID)
 	if err != nil {
-		http.Error(w, "Failed to load attempt history", http.StatusInternalServerError)
+		writeServiceError(w, r, err, "Failed to load attempt history")
 		return
 	}
 
@@ -258,13 +387,13 @@ func (h *Handlers) GetExamQuestions(w http.ResponseWriter, r *http.Request) {
 
 	attemptID, err := uuid.Parse(attemptIDStr)
 	if err != nil {
-		http.Error(w, "Invalid attempt ID", http.StatusBadRequest)
+		writeError(w, r, http.StatusBadRequest, "Invalid attempt ID")
 		return
 	}
 
 	questions, err := h.service.GetExamQuestions(r.Context(), attemptID)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeServiceError(w, r, err, "Failed to load questions")
 		return
 	}
 
@@ -278,19 +407,25 @@ func (h *Handlers) SubmitExam(w http.ResponseWriter, r *http.Request) {
 
 	attemptID, err := uuid.Parse(attemptIDStr)
 	if err != nil {
-		http.Error(w, "Invalid attempt ID", http.StatusBadRequest)
+		writeError(w, r, http.StatusBadRequest, "Invalid attempt ID")
 		return
 	}
 
 	var submission models.ExamSubmission
 	if err := json.NewDecoder(r.Body).Decode(&submission); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		writeError(w, r, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	submission.IdempotencyKey = r.Header.Get("Idempotency-Key")
+	if len(submission.IdempotencyKey) > maxIdempotencyKeyLength {
+		writeError(w, r, http.StatusBadRequest, "Idempotency-Key is too long")
 		return
 	}
 
 	result, err := h.service.SubmitExam(r.Context(), attemptID, submission)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeServiceError(w, r, err, "Failed to submit exam")
 		return
 	}
 
@@ -298,25 +433,161 @@ func (h *Handlers) SubmitExam(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(result)
 }
 
+func (h *Handlers) RecordAttemptEvents(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	attemptIDStr := vars["attemptId"]
+
+	attemptID, err := uuid.Parse(attemptIDStr)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, "Invalid attempt ID")
+		return
+	}
+
+	var batch models.AttemptEventBatch
+	if err := json.NewDecoder(r.Body).Decode(&batch); err != nil {
+		writeError(w, r, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if err := h.service.RecordAttemptEvents(r.Context(), attemptID, batch.Events); err != nil {
+		writeServiceError(w, r, err, "Failed to record events")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handlers) GetAttemptAnswerChanges(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	attemptIDStr := vars["attemptId"]
+
+	attemptID, err := uuid.Parse(attemptIDStr)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, "Invalid attempt ID")
+		return
+	}
+
+	report, err := h.service.GetAttemptAnswerChanges(r.Context(), attemptID)
+	if err != nil {
+		writeServiceError(w, r, err, "Failed to load answer changes")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
+
+func (h *Handlers) GetUserAnswerChanges(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	userIDStr := vars["userId"]
+
+	userID, err := uuid.Parse(userIDStr)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	report, err := h.service.GetUserAnswerChanges(r.Context(), userID)
+	if err != nil {
+		writeServiceError(w, r, err, "Failed to load answer changes")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
+
+func (h *Handlers) GetSectionState(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	attemptIDStr := vars["attemptId"]
+
+	attemptID, err := uuid.Parse(attemptIDStr)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, "Invalid attempt ID")
+		return
+	}
+
+	state, err := h.service.GetSectionState(r.Context(), attemptID)
+	if err != nil {
+		writeServiceError(w, r, err, "Failed to load sections")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(state)
+}
+
+func (h *Handlers) OpenSection(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	attemptIDStr := vars["attemptId"]
+
+	attemptID, err := uuid.Parse(attemptIDStr)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, "Invalid attempt ID")
+		return
+	}
+
+	state, err := h.service.OpenSection(r.Context(), attemptID)
+	if err != nil {
+		writeServiceError(w, r, err, "Failed to open section")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(state)
+}
+
+func (h *Handlers) SubmitSection(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	attemptIDStr := vars["attemptId"]
+
+	attemptID, err := uuid.Parse(attemptIDStr)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, "Invalid attempt ID")
+		return
+	}
+
+	index, err := strconv.Atoi(vars["index"])
+	if err != nil || index < 0 {
+		writeError(w, r, http.StatusBadRequest, "Invalid section index")
+		return
+	}
+
+	var submission models.ExamSubmission
+	if err := json.NewDecoder(r.Body).Decode(&submission); err != nil {
+		writeError(w, r, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	state, err := h.service.SubmitSection(r.Context(), attemptID, index, submission)
+	if err != nil {
+		writeServiceError(w, r, err, "Failed to submit section")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(state)
+}
+
 func (h *Handlers) DownloadReport(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	attemptIDStr := vars["attemptId"]
 
 	attemptID, err := uuid.Parse(attemptIDStr)
 	if err != nil {
-		http.Error(w, "Invalid attempt ID", http.StatusBadRequest)
+		writeError(w, r, http.StatusBadRequest, "Invalid attempt ID")
 		return
 	}
 
 	result, err := h.service.GetExamResult(r.Context(), attemptID)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeServiceError(w, r, err, "Failed to load results")
 		return
 	}
 
 	pdfBuffer, err := h.pdfService.GenerateExamReport(result)
 	if err != nil {
-		http.Error(w, "Failed to generate PDF", http.StatusInternalServerError)
+		writeServiceError(w, r, err, "Failed to generate PDF")
 		return
 	}
 
@@ -333,7 +604,7 @@ func (h *Handlers) DeleteAttempt(w http.ResponseWriter, r *http.Request) {
 
 	attemptID, err := uuid.Parse(attemptIDStr)
 	if err != nil {
-		http.Error(w, "Invalid attempt ID", http.StatusBadRequest)
+		writeError(w, r, http.StatusBadRequest, "Invalid attempt ID")
 		return
 	}
 
@@ -342,47 +613,73 @@ func (h *Handlers) DeleteAttempt(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		writeError(w, r, http.StatusBadRequest, "Invalid request body")
 		return
 	}
 
 	userID, err := uuid.Parse(req.UserID)
 	if err != nil {
-		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		writeError(w, r, http.StatusBadRequest, "Invalid user ID")
 		return
 	}
 
 	if err := h.service.DeleteAttempt(r.Context(), userID, attemptID); err != nil {
-		switch {
-		case errors.Is(err, service.ErrAttemptNotFound):
-			http.Error(w, "Attempt not found", http.StatusNotFound)
-		case errors.Is(err, service.ErrAttemptForbidden):
-			http.Error(w, "Attempt does not belong to user", http.StatusForbidden)
-		case errors.Is(err, service.ErrAttemptAlreadyClosed):
-			http.Error(w, "Attempt already completed", http.StatusBadRequest)
-		default:
-			http.Error(w, "Failed to delete attempt", http.StatusInternalServerError)
-		}
+		writeServiceError(w, r, err, "Failed to delete attempt")
 		return
 	}
 
 	w.WriteHeader(http.StatusNoContent)
 }
 
+func (h *Handlers) ReportQuestionIssue(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	questionID, err := strconv.Atoi(vars["questionId"])
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, "Invalid question ID")
+		return
+	}
+
+	var req models.QuestionReportRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, r, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	userID, err := uuid.Parse(req.UserID)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	attemptID, err := uuid.Parse(req.AttemptID)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, "Invalid attempt ID")
+		return
+	}
+
+	report, err := h.service.ReportQuestionIssue(r.Context(), questionID, userID, attemptID, req.Category, req.Message)
+	if err != nil {
+		writeServiceError(w, r, err, "Failed to report issue")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(report)
+}
+
 func (h *Handlers) GetEarnedValueQuestions(w http.ResponseWriter, r *http.Request) {
-	count := 10
+	// Zero asks the service for the configured default
+	count := 0
 	if raw := r.URL.Query().Get("count"); raw != "" {
 		if parsed, err := strconv.Atoi(raw); err == nil && parsed > 0 {
 			count = parsed
 		}
 	}
-	if count > 50 {
-		count = 50
-	}
 
 	questions, err := h.service.GetEarnedValueDrill(r.Context(), count)
 	if err != nil {
-		http.Error(w, "Failed to load earned value questions", http.StatusInternalServerError)
+		writeServiceError(w, r, err, "Failed to load earned value questions")
 		return
 	}
 
@@ -391,19 +688,17 @@ func (h *Handlers) GetEarnedValueQuestions(w http.ResponseWriter, r *http.Reques
 }
 
 func (h *Handlers) GetPertQuestions(w http.ResponseWriter, r *http.Request) {
-	count := 10
+	// Zero asks the service for the configured default
+	count := 0
 	if raw := r.URL.Query().Get("count"); raw != "" {
 		if parsed, err := strconv.Atoi(raw); err == nil && parsed > 0 {
 			count = parsed
 		}
 	}
-	if count > 50 {
-		count = 50
-	}
 
 	questions, err := h.service.GetPertDrill(r.Context(), count)
 	if err != nil {
-		http.Error(w, "Failed to load PERT questions", http.StatusInternalServerError)
+		writeServiceError(w, r, err, "Failed to load PERT questions")
 		return
 	}
 
@@ -412,19 +707,17 @@ func (h *Handlers) GetPertQuestions(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *Handlers) GetStakeholderSalienceQuestions(w http.ResponseWriter, r *http.Request) {
-	count := 10
+	// Zero asks the service for the configured default
+	count := 0
 	if raw := r.URL.Query().Get("count"); raw != "" {
 		if parsed, err := strconv.Atoi(raw); err == nil && parsed > 0 {
 			count = parsed
 		}
 	}
-	if count > 50 {
-		count = 50
-	}
 
 	questions, err := h.service.GetStakeholderSalienceDrill(r.Context(), count)
 	if err != nil {
-		http.Error(w, "Failed to load stakeholder salience questions", http.StatusInternalServerError)
+		writeServiceError(w, r, err, "Failed to load stakeholder salience questions")
 		return
 	}
 
@@ -433,19 +726,17 @@ func (h *Handlers) GetStakeholderSalienceQuestions(w http.ResponseWriter, r *htt
 }
 
 func (h *Handlers) GetProjectOperationsQuestions(w http.ResponseWriter, r *http.Request) {
-	count := 15
+	// Zero asks the service for the configured default
+	count := 0
 	if raw := r.URL.Query().Get("count"); raw != "" {
 		if parsed, err := strconv.Atoi(raw); err == nil && parsed > 0 {
 			count = parsed
 		}
 	}
-	if count > 20 {
-		count = 20
-	}
 
 	questions, err := h.service.GetProjectOperationsDrill(r.Context(), count)
 	if err != nil {
-		http.Error(w, "Failed to load project vs operations questions", http.StatusInternalServerError)
+		writeServiceError(w, r, err, "Failed to load project vs operations questions")
 		return
 	}
 
@@ -454,19 +745,17 @@ func (h *Handlers) GetProjectOperationsQuestions(w http.ResponseWriter, r *http.
 }
 
 func (h *Handlers) GetTeamMotivationQuestions(w http.ResponseWriter, r *http.Request) {
-	count := 20
+	// Zero asks the service for the configured default
+	count := 0
 	if raw := r.URL.Query().Get("count"); raw != "" {
 		if parsed, err := strconv.Atoi(raw); err == nil && parsed > 0 {
 			count = parsed
 		}
 	}
-	if count > 20 {
-		count = 20
-	}
 
 	questions, err := h.service.GetTeamMotivationDrill(r.Context(), count)
 	if err != nil {
-		http.Error(w, "Failed to load team motivation questions", http.StatusInternalServerError)
+		writeServiceError(w, r, err, "Failed to load team motivation questions")
 		return
 	}
 
@@ -474,19 +763,46 @@ func (h *Handlers) GetTeamMotivationQuestions(w http.ResponseWriter, r *http.Req
 	json.NewEncoder(w).Encode(questions)
 }
 
+// CheckDrillAnswer grades one answer on a drill page, which receives its
+// questions without the key.
+func (h *Handlers) CheckDrillAnswer(w http.ResponseWriter, r *http.Request) {
+	questionID, err := strconv.Atoi(mux.Vars(r)["questionId"])
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, "Invalid question ID")
+		return
+	}
+
+	var req struct {
+		ChoiceIDs []int `json:"choice_ids"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, r, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	feedback, err := h.service.CheckDrillAnswer(r.Context(), questionID, req.ChoiceIDs)
+	if err != nil {
+		writeServiceError(w, r, err, "Failed to check answer")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(feedback)
+}
+
 func (h *Handlers) GetExamResults(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	attemptIDStr := vars["attemptId"]
 
 	attemptID, err := uuid.Parse(attemptIDStr)
 	if err != nil {
-		http.Error(w, "Invalid attempt ID", http.StatusBadRequest)
+		writeError(w, r, http.StatusBadRequest, "Invalid attempt ID")
 		return
 	}
 
 	result, err := h.service.GetExamResult(r.Context(), attemptID)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeServiceError(w, r, err, "Failed to load results")
 		return
 	}
 
@@ -500,13 +816,13 @@ func (h *Handlers) GetUserAttempts(w http.ResponseWriter, r *http.Request) {
 
 	userID, err := uuid.Parse(userIDStr)
 	if err != nil {
-		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		writeError(w, r, http.StatusBadRequest, "Invalid user ID")
 		return
 	}
 
 	attempts, err := h.service.GetUserAttemptHistory(r.Context(), userID)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeServiceError(w, r, err, "Failed to load attempts")
 		return
 	}
 
@@ -514,6 +830,65 @@ func (h *Handlers) GetUserAttempts(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(attempts)
 }
 
+func (h *Handlers) GetUserProgress(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	userIDStr := vars["userId"]
+
+	userID, err := uuid.Parse(userIDStr)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	progress, err := h.service.GetUserProgress(r.Context(), userID)
+	if err != nil {
+		writeServiceError(w, r, err, "Failed to load progress")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(progress)
+}
+
+func (h *Handlers) GetUserNotifications(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	userIDStr := vars["userId"]
+
+	userID, err := uuid.Parse(userIDStr)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	unreadOnly, _ := strconv.ParseBool(r.URL.Query().Get("unread"))
+	notifications, err := h.service.GetUserNotifications(r.Context(), userID, unreadOnly)
+	if err != nil {
+		writeServiceError(w, r, err, "Failed to load notifications")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(notifications)
+}
+
+func (h *Handlers) MarkUserNotificationsRead(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	userIDStr := vars["userId"]
+
+	userID, err := uuid.Parse(userIDStr)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	if err := h.service.MarkUserNotificationsRead(r.Context(), userID); err != nil {
+		writeServiceError(w, r, err, "Failed to update notifications")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
 // Frontend handlers
 func (h *Handlers) HomePage(w http.ResponseWriter, r *http.Request) {
 	http.ServeFile(w, r, "./web/templates/index.html")
@@ -0,0 +1,38 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"capm-exam-system/internal/models"
+)
+
+const healthCheckTimeout = 2 * time.Second
+
+// Healthz reports whether the server can reach the database.
+func (h *Handlers) Healthz(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), healthCheckTimeout)
+	defer cancel()
+
+	writeHealthReport(w, h.service.CheckHealth(ctx))
+}
+
+// Readyz reports whether the server can take traffic: the database answers
+// and its schema is at the version this build expects.
+func (h *Handlers) Readyz(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), healthCheckTimeout)
+	defer cancel()
+
+	writeHealthReport(w, h.service.CheckReadiness(ctx))
+}
+
+func writeHealthReport(w http.ResponseWriter, report *models.HealthReport) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	if report.Status != "ok" {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(report)
+}
@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"capm-exam-system/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+func (h *Handlers) GetPracticeDomains(w http.ResponseWriter, r *http.Request) {
+	domains, err := h.service.ListPracticeDomains(r.Context())
+	if err != nil {
+		writeServiceError(w, r, err, "Failed to load domains")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(domains)
+}
+
+// StartPracticeQuiz starts a learner-built quiz: domains, question count,
+// question type, difficulty, source, time limit and feedback mode.
+func (h *Handlers) StartPracticeQuiz(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Email string `json:"email"`
+		Name  string `json:"name"`
+		models.PracticeQuizOptions
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, r, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if req.Email == "" || req.Name == "" {
+		writeError(w, r, http.StatusBadRequest, "Email and name are required")
+		return
+	}
+
+	user, err := h.service.GetOrCreateUser(r.Context(), req.Email, req.Name)
+	if err != nil {
+		writeServiceError(w, r, err, "Failed to create user")
+		return
+	}
+
+	attempt, err := h.service.StartPracticeQuiz(r.Context(), user.ID, req.PracticeQuizOptions)
+	if err != nil {
+		writeServiceError(w, r, err, "Failed to start practice quiz")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"attempt_id":         attempt.ID,
+		"user_id":            user.ID,
+		"started_at":         attempt.StartedAt,
+		"question_count":     attempt.MaxScore,
+		"time_limit_seconds": attempt.TimeLimitSeconds,
+		"feedback":           attempt.Feedback,
+	})
+}
+
+func (h *Handlers) GetAttemptSettings(w http.ResponseWriter, r *http.Request) {
+	attemptID, err := uuid.Parse(mux.Vars(r)["attemptId"])
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, "Invalid attempt ID")
+		return
+	}
+
+	settings, err := h.service.GetAttemptSettings(r.Context(), attemptID)
+	if err != nil {
+		writeServiceError(w, r, err, "Failed to load attempt settings")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(settings)
+}
+
+// AnswerQuestion locks in one answer of an attempt with immediate feedback
+// and returns the verdict.
+func (h *Handlers) AnswerQuestion(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	attemptID, err := uuid.Parse(vars["attemptId"])
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, "Invalid attempt ID")
+		return
+	}
+	questionID, err := strconv.Atoi(vars["questionId"])
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, "Invalid question ID")
+		return
+	}
+
+	var req struct {
+		ChoiceIDs []int `json:"choice_ids"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, r, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	feedback, err := h.service.AnswerQuestion(r.Context(), attemptID, questionID, req.ChoiceIDs)
+	if err != nil {
+		writeServiceError(w, r, err, "Failed to record answer")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(feedback)
+}
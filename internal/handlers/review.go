@@ -0,0 +1,164 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"capm-exam-system/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+func (h *Handlers) GetUserBookmarks(w http.ResponseWriter, r *http.Request) {
+	userID, err := uuid.Parse(mux.Vars(r)["userId"])
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	bookmarks, err := h.service.ListBookmarks(r.Context(), userID)
+	if err != nil {
+		writeServiceError(w, r, err, "Failed to load bookmarks")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(bookmarks)
+}
+
+func (h *Handlers) AddBookmark(w http.ResponseWriter, r *http.Request) {
+	userID, questionID, ok := bookmarkVars(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.service.AddBookmark(r.Context(), userID, questionID); err != nil {
+		writeServiceError(w, r, err, "Failed to add bookmark")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handlers) RemoveBookmark(w http.ResponseWriter, r *http.Request) {
+	userID, questionID, ok := bookmarkVars(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.service.RemoveBookmark(r.Context(), userID, questionID); err != nil {
+		writeServiceError(w, r, err, "Failed to remove bookmark")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func bookmarkVars(w http.ResponseWriter, r *http.Request) (uuid.UUID, int, bool) {
+	vars := mux.Vars(r)
+	userID, err := uuid.Parse(vars["userId"])
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, "Invalid user ID")
+		return uuid.Nil, 0, false
+	}
+	questionID, err := strconv.Atoi(vars["questionId"])
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, "Invalid question ID")
+		return uuid.Nil, 0, false
+	}
+	return userID, questionID, true
+}
+
+// StartMissedRetake starts an attempt over the questions missed in a
+// submitted attempt of the user.
+func (h *Handlers) StartMissedRetake(w http.ResponseWriter, r *http.Request) {
+	attemptID, err := uuid.Parse(mux.Vars(r)["attemptId"])
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, "Invalid attempt ID")
+		return
+	}
+
+	var req struct {
+		UserID string `json:"user_id"`
+		Tutor  bool   `json:"tutor"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, r, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	userID, err := uuid.Parse(req.UserID)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	attempt, err := h.service.StartMissedRetake(r.Context(), userID, attemptID, req.Tutor)
+	if err != nil {
+		writeServiceError(w, r, err, "Failed to start retake")
+		return
+	}
+	writeReviewStarted(w, attempt)
+}
+
+// StartBookmarkReview starts an attempt over the user's bookmarks.
+func (h *Handlers) StartBookmarkReview(w http.ResponseWriter, r *http.Request) {
+	userID, err := uuid.Parse(mux.Vars(r)["userId"])
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	var req struct {
+		Tutor bool `json:"tutor"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, r, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	attempt, err := h.service.StartBookmarkReview(r.Context(), userID, req.Tutor)
+	if err != nil {
+		writeServiceError(w, r, err, "Failed to start bookmark practice")
+		return
+	}
+	writeReviewStarted(w, attempt)
+}
+
+// StartMissedReview starts an attempt over everything the user missed in
+// the last days.
+func (h *Handlers) StartMissedReview(w http.ResponseWriter, r *http.Request) {
+	userID, err := uuid.Parse(mux.Vars(r)["userId"])
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	var req struct {
+		Days  int  `json:"days"`
+		Tutor bool `json:"tutor"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, r, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	attempt, err := h.service.StartMissedReview(r.Context(), userID, req.Days, req.Tutor)
+	if err != nil {
+		writeServiceError(w, r, err, "Failed to start missed-question review")
+		return
+	}
+	writeReviewStarted(w, attempt)
+}
+
+func writeReviewStarted(w http.ResponseWriter, attempt *models.Attempt) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"attempt_id":     attempt.ID,
+		"user_id":        attempt.UserID,
+		"started_at":     attempt.StartedAt,
+		"question_count": attempt.MaxScore,
+		"feedback":       attempt.Feedback,
+	})
+}
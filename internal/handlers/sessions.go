@@ -0,0 +1,285 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"capm-exam-system/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+const (
+	// sessionStreamInterval is how often a session stream polls for changes.
+	sessionStreamInterval = 2 * time.Second
+	// sessionStreamKeepAlive is the longest a stream stays silent; proxies
+	// drop idle connections.
+	sessionStreamKeepAlive = 15 * time.Second
+	// sessionStreamWriteTimeout replaces the server write timeout, which
+	// would otherwise end every stream, for each write.
+	sessionStreamWriteTimeout = 10 * time.Second
+)
+
+// CreateGroupSession opens a group session for the instructor named in the
+// body.
+func (h *Handlers) CreateGroupSession(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Email           string `json:"email"`
+		Name            string `json:"name"`
+		Title           string `json:"title"`
+		Kind            string `json:"kind"`
+		DurationMinutes int    `json:"duration_minutes"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, r, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if req.Email == "" || req.Name == "" {
+		writeError(w, r, http.StatusBadRequest, "Email and name are required")
+		return
+	}
+
+	instructor, err := h.service.GetOrCreateUser(r.Context(), req.Email, req.Name)
+	if err != nil {
+		writeServiceError(w, r, err, "Failed to create user")
+		return
+	}
+
+	session, err := h.service.CreateGroupSession(r.Context(), instructor.ID, req.Title, req.Kind, req.DurationMinutes)
+	if err != nil {
+		writeServiceError(w, r, err, "Failed to create group session")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(session)
+}
+
+func (h *Handlers) JoinGroupSession(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Email    string `json:"email"`
+		Name     string `json:"name"`
+		JoinCode string `json:"join_code"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, r, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if req.Email == "" || req.Name == "" || req.JoinCode == "" {
+		writeError(w, r, http.StatusBadRequest, "Email, name and join code are required")
+		return
+	}
+
+	user, err := h.service.GetOrCreateUser(r.Context(), req.Email, req.Name)
+	if err != nil {
+		writeServiceError(w, r, err, "Failed to create user")
+		return
+	}
+
+	state, err := h.service.JoinGroupSession(r.Context(), user.ID, req.JoinCode)
+	if err != nil {
+		writeServiceError(w, r, err, "Failed to join group session")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"user_id": user.ID,
+		"state":   state,
+	})
+}
+
+func (h *Handlers) StartGroupSession(w http.ResponseWriter, r *http.Request) {
+	h.controlGroupSession(w, r, "Failed to start group session", func(ctx context.Context, sessionID, instructorID uuid.UUID, _ int) (*models.GroupSession, error) {
+		return h.service.StartGroupSession(ctx, sessionID, instructorID)
+	})
+}
+
+func (h *Handlers) ExtendGroupSession(w http.ResponseWriter, r *http.Request) {
+	h.controlGroupSession(w, r, "Failed to extend group session", h.service.ExtendGroupSession)
+}
+
+// EndGroupSession cuts the session's time to now, which makes every
+// participant's page submit.
+func (h *Handlers) EndGroupSession(w http.ResponseWriter, r *http.Request) {
+	h.controlGroupSession(w, r, "Failed to end group session", func(ctx context.Context, sessionID, instructorID uuid.UUID, _ int) (*models.GroupSession, error) {
+		return h.service.EndGroupSession(ctx, sessionID, instructorID)
+	})
+}
+
+// controlGroupSession decodes an instructor command, which carries the
+// instructor and, for extensions, the minutes, and runs it.
+func (h *Handlers) controlGroupSession(w http.ResponseWriter, r *http.Request, fallback string,
+	command func(ctx context.Context, sessionID, instructorID uuid.UUID, minutes int) (*models.GroupSession, error)) {
+	sessionID, err := uuid.Parse(mux.Vars(r)["sessionId"])
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, "Invalid session ID")
+		return
+	}
+
+	var req struct {
+		InstructorID string `json:"instructor_id"`
+		Minutes      int    `json:"minutes"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, r, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	instructorID, err := uuid.Parse(req.InstructorID)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, "Invalid instructor ID")
+		return
+	}
+
+	session, err := command(r.Context(), sessionID, instructorID, req.Minutes)
+	if err != nil {
+		writeServiceError(w, r, err, fallback)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(session)
+}
+
+// StreamGroupSessionProgress is the instructor's control channel: a
+// server-sent event stream of every participant's progress.
+func (h *Handlers) StreamGroupSessionProgress(w http.ResponseWriter, r *http.Request) {
+	sessionID, err := uuid.Parse(mux.Vars(r)["sessionId"])
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, "Invalid session ID")
+		return
+	}
+	instructorID, err := uuid.Parse(r.URL.Query().Get("instructor_id"))
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, "Invalid instructor ID")
+		return
+	}
+
+	// The clock moves, so every poll is sent
+	h.streamGroupSession(w, r, "progress", func(ctx context.Context) (interface{}, string, bool, error) {
+		progress, err := h.service.GetGroupSessionProgress(ctx, sessionID, instructorID)
+		if err != nil {
+			return nil, "", false, err
+		}
+		return progress, "", progress.Session.Status == "ended", nil
+	})
+}
+
+// StreamGroupSessionEvents is a participant's event stream: the session
+// status, its end time and the participant's attempt, sent when they change.
+func (h *Handlers) StreamGroupSessionEvents(w http.ResponseWriter, r *http.Request) {
+	sessionID, err := uuid.Parse(mux.Vars(r)["sessionId"])
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, "Invalid session ID")
+		return
+	}
+	userID, err := uuid.Parse(r.URL.Query().Get("user_id"))
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	h.streamGroupSession(w, r, "state", func(ctx context.Context) (interface{}, string, bool, error) {
+		state, err := h.service.GetGroupSessionState(ctx, sessionID, userID)
+		if err != nil {
+			return nil, "", false, err
+		}
+		key := fmt.Sprint(state.Session.Status, state.Session.EndsAt, state.AttemptID, state.Submitted)
+		return state, key, state.Submitted || state.Session.Status == "ended", nil
+	})
+}
+
+// streamGroupSession answers with a server-sent event stream. It polls load
+// and sends the payload as the named event whenever the key changes (an
+// empty key sends every poll), with keep-alive comments in between. The
+// stream ends after a payload load reports as final, when the client goes
+// away or when the server shuts down. An error before the first event is
+// answered as a normal API error.
+func (h *Handlers) streamGroupSession(w http.ResponseWriter, r *http.Request, event string,
+	load func(ctx context.Context) (payload interface{}, key string, final bool, err error)) {
+	ctx := r.Context()
+	payload, key, final, err := load(ctx)
+	if err != nil {
+		writeServiceError(w, r, err, "Failed to load group session")
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	rc := http.NewResponseController(w)
+	write := func(message string) bool {
+		// Not every writer supports deadlines; the stream then ends at the
+		// server write timeout and the client reconnects
+		rc.SetWriteDeadline(time.Now().Add(sessionStreamWriteTimeout))
+		if _, err := fmt.Fprint(w, message); err != nil {
+			return false
+		}
+		return rc.Flush() == nil
+	}
+	send := func(payload interface{}) bool {
+		data, err := json.Marshal(payload)
+		if err != nil {
+			slog.ErrorContext(ctx, "failed to encode group session event", "error", err)
+			return false
+		}
+		return write(fmt.Sprintf("event: %s\ndata: %s\n\n", event, data))
+	}
+
+	if !write(fmt.Sprintf("retry: %d\n\n", sessionStreamInterval.Milliseconds())) || !send(payload) || final {
+		return
+	}
+
+	ticker := time.NewTicker(sessionStreamInterval)
+	defer ticker.Stop()
+	lastKey, lastSent := key, time.Now()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-h.streams.Done():
+			return
+		case <-ticker.C:
+		}
+
+		payload, key, final, err := load(ctx)
+		if err != nil {
+			if ctx.Err() == nil {
+				slog.ErrorContext(ctx, "failed to load group session", "error", err)
+			}
+			return
+		}
+
+		if key == "" || key != lastKey || final {
+			if !send(payload) || final {
+				return
+			}
+			lastKey, lastSent = key, time.Now()
+		} else if time.Since(lastSent) >= sessionStreamKeepAlive {
+			if !write(": keep-alive\n\n") {
+				return
+			}
+			lastSent = time.Now()
+		}
+	}
+}
+
+func (h *Handlers) GroupSessionsPage(w http.ResponseWriter, r *http.Request) {
+	http.ServeFile(w, r, "./web/templates/sessions.html")
+}
+
+func (h *Handlers) JoinGroupSessionPage(w http.ResponseWriter, r *http.Request) {
+	http.ServeFile(w, r, "./web/templates/session_join.html")
+}
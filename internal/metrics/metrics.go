@@ -0,0 +1,85 @@
+// Package metrics defines the Prometheus collectors exposed on /metrics.
+package metrics
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+)
+
+const namespace = "capm"
+
+// Registry holds every collector served on /metrics, including the Go
+// runtime and process collectors.
+var Registry = prometheus.NewRegistry()
+
+var (
+	RequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency by route template, method and status.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"route", "method", "status"})
+
+	AttemptsStarted = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "attempts_started_total",
+		Help:      "Attempts started by exam type.",
+	}, []string{"exam_type"})
+
+	AttemptsSubmitted = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "attempts_submitted_total",
+		Help:      "Attempts graded by exam type.",
+	}, []string{"exam_type"})
+
+	QuestionDrawDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "question_draw_duration_seconds",
+		Help:      "Time to draw the question set for an attempt.",
+		Buckets:   []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5},
+	}, []string{"exam_type"})
+
+	// SelectionRelaxations counts questions drawn against a family or enemy
+	// constraint because their domain had nothing else left, a sign that the
+	// bank needs more questions there.
+	SelectionRelaxations = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "question_selection_relaxations_total",
+		Help:      "Questions drawn despite repeating a family or conflicting with an enemy, by exam type and constraint.",
+	}, []string{"exam_type", "constraint"})
+
+	GradingDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "grading_duration_seconds",
+		Help:      "Time to grade and store a submitted attempt.",
+		Buckets:   []float64{.01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10},
+	}, []string{"exam_type"})
+
+	PDFGenerationDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "pdf_generation_duration_seconds",
+		Help:      "Time to render a PDF report.",
+		Buckets:   []float64{.01, .025, .05, .1, .25, .5, 1, 2.5, 5},
+	}, []string{"report"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		RequestDuration,
+		AttemptsStarted,
+		AttemptsSubmitted,
+		QuestionDrawDuration,
+		SelectionRelaxations,
+		GradingDuration,
+		PDFGenerationDuration,
+	)
+}
+
+// Since reports the seconds elapsed since start, for Observe calls.
+func Since(start time.Time) float64 {
+	return time.Since(start).Seconds()
+}
@@ -1,6 +1,7 @@
 package models
 
 import (
+	"encoding/json"
 	"github.com/google/uuid"
 	"time"
 )
@@ -18,6 +19,12 @@ type Question struct {
 	PopularityScore float64 `json:"popularity_score"`
 	Explanation     string  `json:"explanation"`
 	IsMultiSelect   bool    `json:"is_multi_select"`
+	// ShuffleChoices is false for questions whose choices refer to each
+	// other by label, which must keep their authored order.
+	ShuffleChoices bool `json:"-"`
+	// Family groups questions rendered from the same template. Empty means
+	// the question is a family of its own.
+	Family string `json:"-"`
 }
 
 type Choice struct {
@@ -26,6 +33,7 @@ type Choice struct {
 	Text       string `json:"text"`
 	IsCorrect  bool   `json:"is_correct"`
 	Label      string `json:"label"` // A, B, C, D
+	Rationale  string `json:"rationale,omitempty"`
 }
 
 type QuestionWithChoices struct {
@@ -41,22 +49,631 @@ type Exam struct {
 }
 
 type Attempt struct {
-	ID        uuid.UUID  `json:"id"`
-	ExamID    uuid.UUID  `json:"exam_id"`
-	UserID    uuid.UUID  `json:"user_id"`
-	Seed      int64      `json:"seed"`
-	Score     *int       `json:"score,omitempty"`
-	MaxScore  int        `json:"max_score"`
-	StartedAt time.Time  `json:"started_at"`
-	EndedAt   *time.Time `json:"ended_at,omitempty"`
+	ID               uuid.UUID  `json:"id"`
+	ExamID           uuid.UUID  `json:"exam_id"`
+	UserID           uuid.UUID  `json:"user_id"`
+	Seed             int64      `json:"seed"`
+	Score            *int       `json:"score,omitempty"`
+	MaxScore         int        `json:"max_score"`
+	StartedAt        time.Time  `json:"started_at"`
+	EndedAt          *time.Time `json:"ended_at,omitempty"`
+	CurrentSection   int        `json:"current_section"`
+	SectionStatus    string     `json:"section_status"`
+	SectionStartedAt *time.Time `json:"section_started_at,omitempty"`
+	BreakStartedAt   *time.Time `json:"break_started_at,omitempty"`
+	SubmitKey        *string    `json:"-"`
+	TimeLimitSeconds *int       `json:"time_limit_seconds,omitempty"`
+	Feedback         string     `json:"feedback"` // end, immediate
+	ChallengeID      *uuid.UUID `json:"challenge_id,omitempty"`
+}
+
+// AttemptSettings tells the client how to run an attempt: whether it is
+// timed and when answers are revealed.
+type AttemptSettings struct {
+	AttemptID        uuid.UUID  `json:"attempt_id"`
+	QuestionCount    int        `json:"question_count"`
+	Feedback         string     `json:"feedback"`
+	TimeLimitSeconds *int       `json:"time_limit_seconds,omitempty"`
+	EndsAt           *time.Time `json:"ends_at,omitempty"`
+	ServerTime       time.Time  `json:"server_time"`
+	// GroupSessionID is set when the attempt belongs to a group session,
+	// whose event stream moves the deadline
+	GroupSessionID *uuid.UUID `json:"group_session_id,omitempty"`
+}
+
+// PracticeQuizOptions describes a quiz built by the learner. Empty values
+// mean no restriction.
+type PracticeQuizOptions struct {
+	Domains          []string `json:"domains"`
+	QuestionCount    int      `json:"question_count"`
+	QuestionType     string   `json:"question_type"` // single, multi
+	Difficulty       string   `json:"difficulty"`    // easy, medium, hard
+	Source           string   `json:"source"`        // unseen, missed
+	TimeLimitMinutes int      `json:"time_limit_minutes"`
+	Feedback         string   `json:"feedback"` // end, immediate
+}
+
+// PracticeDomain is a domain a practice quiz can draw from.
+type PracticeDomain struct {
+	Domain               string `json:"domain"`
+	Questions            int    `json:"questions"`
+	MultiSelectQuestions int    `json:"multi_select_questions"`
+	Drill                bool   `json:"drill"`
+}
+
+// Challenge freezes the question set, order and seed of an attempt so that
+// everyone who opens its link takes an identical attempt.
+type Challenge struct {
+	ID               uuid.UUID `json:"id"`
+	CreatorID        uuid.UUID `json:"creator_id"`
+	ExamID           uuid.UUID `json:"exam_id"`
+	QuestionIDs      []int     `json:"-"`
+	Seed             int64     `json:"-"`
+	Sectioned        bool      `json:"sectioned"`
+	TimeLimitSeconds *int      `json:"time_limit_seconds,omitempty"`
+	Feedback         string    `json:"feedback"`
+	CreatedAt        time.Time `json:"created_at"`
+}
+
+// ChallengeLink is a challenge with the signed token that opens it.
+type ChallengeLink struct {
+	Challenge
+	Token string `json:"token"`
+	URL   string `json:"url"`
+}
+
+// ChallengeSummary is what the challenge page shows: what the attempt is and
+// how everyone who submitted it scored, per domain.
+type ChallengeSummary struct {
+	Token            string                 `json:"token"`
+	ExamName         string                 `json:"exam_name"`
+	AttemptType      string                 `json:"attempt_type"`
+	QuestionCount    int                    `json:"question_count"`
+	CreatorName      string                 `json:"creator_name"`
+	TimeLimitSeconds *int                   `json:"time_limit_seconds,omitempty"`
+	Feedback         string                 `json:"feedback"`
+	CreatedAt        time.Time              `json:"created_at"`
+	Domains          []string               `json:"domains"`
+	Participants     []ChallengeParticipant `json:"participants"`
+}
+
+type ChallengeParticipant struct {
+	AttemptID  uuid.UUID              `json:"attempt_id"`
+	Name       string                 `json:"name"`
+	Score      int                    `json:"score"`
+	MaxScore   int                    `json:"max_score"`
+	Percentage float64                `json:"percentage"`
+	EndedAt    time.Time              `json:"ended_at"`
+	Domains    []ChallengeDomainScore `json:"domains"`
+}
+
+type ChallengeDomainScore struct {
+	Domain     string  `json:"domain"`
+	Correct    int     `json:"correct"`
+	Total      int     `json:"total"`
+	Percentage float64 `json:"percentage"`
+}
+
+// ChallengeDomainResult is one submitted challenge attempt's question and
+// correct counts for a domain.
+type ChallengeDomainResult struct {
+	AttemptID uuid.UUID
+	Name      string
+	Score     int
+	MaxScore  int
+	EndedAt   time.Time
+	Domain    string
+	Total     int
+	Correct   int
+}
+
+// GroupSession is a timed exam an instructor runs for a whole room at once.
+// It waits for participants, runs until EndsAt and is ended once every
+// attempt has been submitted.
+type GroupSession struct {
+	ID               uuid.UUID  `json:"id"`
+	InstructorID     uuid.UUID  `json:"instructor_id"`
+	Title            string     `json:"title"`
+	Kind             string     `json:"kind"` // quiz, exam, hard, pmp
+	JoinCode         string     `json:"join_code,omitempty"`
+	TimeLimitSeconds int        `json:"time_limit_seconds"`
+	Status           string     `json:"status"` // waiting, running, ended
+	StartedAt        *time.Time `json:"started_at,omitempty"`
+	EndsAt           *time.Time `json:"ends_at,omitempty"`
+	CreatedAt        time.Time  `json:"created_at"`
+}
+
+// GroupSessionState is what a participant's event stream reports: the
+// session clock and the participant's own attempt.
+type GroupSessionState struct {
+	Session    GroupSession `json:"session"`
+	ServerTime time.Time    `json:"server_time"`
+	AttemptID  *uuid.UUID   `json:"attempt_id,omitempty"`
+	Submitted  bool         `json:"submitted"`
+}
+
+// GroupSessionParticipant is one participant's live progress. Answered
+// counts questions whose latest recorded selection is not empty.
+type GroupSessionParticipant struct {
+	UserID          uuid.UUID  `json:"user_id"`
+	Name            string     `json:"name"`
+	AttemptID       *uuid.UUID `json:"attempt_id,omitempty"`
+	QuestionCount   int        `json:"question_count"`
+	Answered        int        `json:"answered"`
+	Submitted       bool       `json:"submitted"`
+	Score           *int       `json:"score,omitempty"`
+	EndsAt          *time.Time `json:"ends_at,omitempty"`
+	TimeLeftSeconds int        `json:"time_left_seconds"`
+	LastActivityAt  *time.Time `json:"last_activity_at,omitempty"`
+	JoinedAt        time.Time  `json:"joined_at"`
+}
+
+// GroupSessionProgress is what the instructor's control stream reports.
+type GroupSessionProgress struct {
+	Session         GroupSession              `json:"session"`
+	ServerTime      time.Time                 `json:"server_time"`
+	TimeLeftSeconds int                       `json:"time_left_seconds"`
+	Submitted       int                       `json:"submitted"`
+	Participants    []GroupSessionParticipant `json:"participants"`
+}
+
+// Bookmark is a question a user saved to practice again.
+type Bookmark struct {
+	QuestionID int       `json:"question_id"`
+	Prompt     string    `json:"prompt"`
+	Domain     string    `json:"domain"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// AnswerFeedback is the verdict on an answer locked in during an attempt
+// with immediate feedback.
+type AnswerFeedback struct {
+	QuestionID       int    `json:"question_id"`
+	ChoiceIDs        []int  `json:"choice_ids"`
+	CorrectChoiceIDs []int  `json:"correct_choice_ids"`
+	IsCorrect        bool   `json:"is_correct"`
+	Explanation      string `json:"explanation"`
+	// Choices carry the key and each choice's rationale, in display order
+	Choices []Choice `json:"choices"`
+}
+
+// SectionState describes where a sectioned attempt currently stands: which
+// section is open (or which break is running) and the deadlines that apply.
+type SectionState struct {
+	AttemptID      uuid.UUID     `json:"attempt_id"`
+	Status         string        `json:"status"` // pending, open, break, completed
+	CurrentSection int           `json:"current_section"`
+	Sections       []SectionInfo `json:"sections"`
+	SectionEndsAt  *time.Time    `json:"section_ends_at,omitempty"`
+	BreakEndsAt    *time.Time    `json:"break_ends_at,omitempty"`
+	ServerTime     time.Time     `json:"server_time"`
+}
+
+type SectionInfo struct {
+	Index            int    `json:"index"`
+	QuestionCount    int    `json:"question_count"`
+	TimeLimitSeconds int    `json:"time_limit_seconds"`
+	Status           string `json:"status"` // upcoming, open, closed
 }
 
 type AttemptAnswer struct {
-	ID         uuid.UUID `json:"id"`
+	ID            uuid.UUID `json:"id"`
+	AttemptID     uuid.UUID `json:"attempt_id"`
+	QuestionID    int       `json:"question_id"`
+	ChoiceID      *int      `json:"choice_id,omitempty"`
+	IsCorrect     *bool     `json:"is_correct,omitempty"`
+	DwellMs       *int      `json:"dwell_ms,omitempty"`
+	AnswerChanges *int      `json:"answer_changes,omitempty"`
+}
+
+// AttemptEvent is a client-side interaction with a question while an attempt
+// is in progress: a view, leaving the question after DwellMs, or a change of
+// the selected choices.
+type AttemptEvent struct {
+	QuestionID int       `json:"question_id"`
+	Type       string    `json:"type"` // view, leave, answer
+	ChoiceIDs  []int     `json:"choice_ids,omitempty"`
+	DwellMs    int       `json:"dwell_ms,omitempty"`
+	OccurredAt time.Time `json:"occurred_at"`
+}
+
+type AttemptEventBatch struct {
+	Events []AttemptEvent `json:"events"`
+}
+
+// AnswerSelection is one entry in the selection history of a question within
+// an attempt; the last entry is the candidate's final answer.
+type AnswerSelection struct {
 	AttemptID  uuid.UUID `json:"attempt_id"`
 	QuestionID int       `json:"question_id"`
-	ChoiceID   *int      `json:"choice_id,omitempty"`
-	IsCorrect  *bool     `json:"is_correct,omitempty"`
+	ChoiceIDs  []int     `json:"choice_ids"`
+	SelectedAt time.Time `json:"selected_at"`
+}
+
+// AnswerChangeReport measures second-guessing: how often the first selection
+// was right and how many points switching gained or lost.
+type AnswerChangeReport struct {
+	Summary        AnswerChangeBreakdown   `json:"summary"`
+	ByDomain       []AnswerChangeBreakdown `json:"by_domain"`
+	ByQuestionType []AnswerChangeBreakdown `json:"by_question_type"`
+}
+
+type AnswerChangeBreakdown struct {
+	Key                  string  `json:"key"`
+	Questions            int     `json:"questions"`
+	FirstInstinctCorrect int     `json:"first_instinct_correct"`
+	FirstInstinctRate    float64 `json:"first_instinct_rate"`
+	Changed              int     `json:"changed"`
+	WrongToRight         int     `json:"wrong_to_right"`
+	RightToWrong         int     `json:"right_to_wrong"`
+	WrongToWrong         int     `json:"wrong_to_wrong"`
+	NetPoints            int     `json:"net_points"`
+}
+
+type UserProgress struct {
+	UserID            uuid.UUID        `json:"user_id"`
+	CompletedAttempts int              `json:"completed_attempts"`
+	ScoreTrend        []ScorePoint     `json:"score_trend"`
+	DomainTrends      []DomainTrend    `json:"domain_trends"`
+	Readiness         *Readiness       `json:"readiness,omitempty"`
+	WeakestTopics     []DomainAccuracy `json:"weakest_topics"`
+}
+
+type ScorePoint struct {
+	AttemptID     uuid.UUID `json:"attempt_id"`
+	AttemptType   string    `json:"attempt_type"`
+	EndedAt       time.Time `json:"ended_at"`
+	Score         int       `json:"score"`
+	MaxScore      int       `json:"max_score"`
+	Percentage    float64   `json:"percentage"`
+	MovingAverage float64   `json:"moving_average"`
+}
+
+type DomainTrend struct {
+	Domain          string        `json:"domain"`
+	Points          []DomainPoint `json:"points"`
+	OverallAccuracy float64       `json:"overall_accuracy"`
+	RecentAccuracy  float64       `json:"recent_accuracy"`
+	Slope           float64       `json:"slope"`
+}
+
+type DomainPoint struct {
+	AttemptID uuid.UUID `json:"attempt_id"`
+	EndedAt   time.Time `json:"ended_at"`
+	Correct   int       `json:"correct"`
+	Answered  int       `json:"answered"`
+	Accuracy  float64   `json:"accuracy"`
+}
+
+// DomainResult is the number of answered and correctly answered questions of
+// one domain in one submitted attempt.
+type DomainResult struct {
+	AttemptID uuid.UUID
+	EndedAt   time.Time
+	Domain    string
+	Answered  int
+	Correct   int
+}
+
+type Readiness struct {
+	PassProbability float64 `json:"pass_probability"`
+	EstimatedScore  float64 `json:"estimated_score"`
+	StandardError   float64 `json:"standard_error"`
+	PassMark        float64 `json:"pass_mark"`
+	MocksConsidered int     `json:"mocks_considered"`
+}
+
+type DomainAccuracy struct {
+	Domain         string  `json:"domain"`
+	Answered       int     `json:"answered"`
+	Correct        int     `json:"correct"`
+	Accuracy       float64 `json:"accuracy"`
+	RecentAccuracy float64 `json:"recent_accuracy"`
+}
+
+type Cohort struct {
+	ID           uuid.UUID `json:"id"`
+	Name         string    `json:"name"`
+	Description  string    `json:"description"`
+	InstructorID uuid.UUID `json:"instructor_id"`
+	InviteCode   string    `json:"invite_code,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+type CohortMember struct {
+	UserID   uuid.UUID `json:"user_id"`
+	Name     string    `json:"name"`
+	Email    string    `json:"email"`
+	JoinedAt time.Time `json:"joined_at"`
+}
+
+type CohortAssignment struct {
+	ID        uuid.UUID  `json:"id"`
+	CohortID  uuid.UUID  `json:"cohort_id"`
+	Kind      string     `json:"kind"`
+	Title     string     `json:"title"`
+	DueAt     *time.Time `json:"due_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// CohortAttempt is a submitted attempt made by a cohort member after joining.
+type CohortAttempt struct {
+	UserID      uuid.UUID
+	AttemptID   uuid.UUID
+	AttemptType string
+	Score       int
+	MaxScore    int
+	EndedAt     time.Time
+}
+
+// CohortDomainResult is one member's answered and correct counts for a domain
+// across all their attempts since joining the cohort.
+type CohortDomainResult struct {
+	UserID   uuid.UUID
+	Domain   string
+	Answered int
+	Correct  int
+}
+
+type CohortReport struct {
+	Cohort         Cohort                 `json:"cohort"`
+	Members        []CohortMember         `json:"members"`
+	Assignments    []AssignmentCompletion `json:"assignments"`
+	DomainAverages []CohortDomainAverage  `json:"domain_averages"`
+	MostMissed     []MissedQuestion       `json:"most_missed"`
+	AtRisk         []AtRiskLearner        `json:"at_risk"`
+}
+
+type AssignmentCompletion struct {
+	Assignment CohortAssignment   `json:"assignment"`
+	Completed  int                `json:"completed"`
+	Members    []AssignmentStatus `json:"members"`
+}
+
+type AssignmentStatus struct {
+	UserID      uuid.UUID  `json:"user_id"`
+	Status      string     `json:"status"`
+	AttemptID   *uuid.UUID `json:"attempt_id,omitempty"`
+	Percentage  *float64   `json:"percentage,omitempty"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+}
+
+type CohortDomainAverage struct {
+	Domain          string  `json:"domain"`
+	Members         int     `json:"members"`
+	Answered        int     `json:"answered"`
+	AverageAccuracy float64 `json:"average_accuracy"`
+}
+
+type MissedQuestion struct {
+	QuestionID int     `json:"question_id"`
+	Prompt     string  `json:"prompt"`
+	Domain     string  `json:"domain"`
+	Answered   int     `json:"answered"`
+	Missed     int     `json:"missed"`
+	MissRate   float64 `json:"miss_rate"`
+}
+
+type AtRiskLearner struct {
+	UserID        uuid.UUID `json:"user_id"`
+	Name          string    `json:"name"`
+	Email         string    `json:"email"`
+	RecentAverage *float64  `json:"recent_average,omitempty"`
+	Overdue       int       `json:"overdue"`
+	Reasons       []string  `json:"reasons"`
+}
+
+// ItemResponse is how one submitted attempt responded to one question it was
+// served. Unanswered questions have no choices and are not correct.
+type ItemResponse struct {
+	AttemptID    uuid.UUID
+	QuestionID   int
+	AttemptScore float64
+	ChoiceIDs    []int
+	IsCorrect    bool
+	DwellMs      *int
+}
+
+type ItemAnalysisReport struct {
+	GeneratedAt time.Time      `json:"generated_at"`
+	Attempts    int            `json:"attempts"`
+	Items       []ItemAnalysis `json:"items"`
+}
+
+type ItemAnalysis struct {
+	QuestionID     int          `json:"question_id"`
+	Domain         string       `json:"domain"`
+	Prompt         string       `json:"prompt"`
+	IsMultiSelect  bool         `json:"is_multi_select"`
+	Served         int          `json:"served"`
+	Answered       int          `json:"answered"`
+	Correct        int          `json:"correct"`
+	PercentCorrect float64      `json:"percent_correct"`
+	Options        []OptionStat `json:"options"`
+	AverageSeconds *float64     `json:"average_seconds,omitempty"`
+	MedianSeconds  *float64     `json:"median_seconds,omitempty"`
+	Discrimination *float64     `json:"discrimination,omitempty"`
+	Flags          []string     `json:"flags"`
+}
+
+// ExposureReport shows how often questions were served per exam form over
+// the exposure window.
+type ExposureReport struct {
+	GeneratedAt time.Time      `json:"generated_at"`
+	Since       time.Time      `json:"since"`
+	MaxRate     float64        `json:"max_rate"`
+	Exams       []ExamExposure `json:"exams"`
+}
+
+// ExamExposure covers one exam at one length; the short quiz and the mock
+// exam draw from the same exam's questions but are reported apart.
+type ExamExposure struct {
+	ExamID        uuid.UUID      `json:"exam_id"`
+	ExamName      string         `json:"exam_name"`
+	AttemptType   string         `json:"attempt_type"`
+	QuestionCount int            `json:"question_count"`
+	Attempts      int            `json:"attempts"`
+	Items         []ItemExposure `json:"items"`
+}
+
+type ItemExposure struct {
+	QuestionID  int     `json:"question_id"`
+	Served      int     `json:"served"`
+	Rate        float64 `json:"rate"`
+	OverExposed bool    `json:"over_exposed"`
+}
+
+type OptionStat struct {
+	ChoiceID  int     `json:"choice_id"`
+	Label     string  `json:"label"`
+	Text      string  `json:"text"`
+	IsCorrect bool    `json:"is_correct"`
+	Chosen    int     `json:"chosen"`
+	Share     float64 `json:"share"`
+}
+
+type QuestionReport struct {
+	ID                 uuid.UUID               `json:"id"`
+	QuestionID         int                     `json:"question_id"`
+	AttemptID          *uuid.UUID              `json:"attempt_id,omitempty"`
+	UserID             uuid.UUID               `json:"user_id"`
+	Category           string                  `json:"category"`
+	Message            string                  `json:"message"`
+	Status             string                  `json:"status"`
+	Resolution         string                  `json:"resolution,omitempty"`
+	CorrectedChoiceIDs []int                   `json:"corrected_choice_ids,omitempty"`
+	RegradeJobID       *uuid.UUID              `json:"regrade_job_id,omitempty"`
+	CreatedAt          time.Time               `json:"created_at"`
+	UpdatedAt          time.Time               `json:"updated_at"`
+	ResolvedAt         *time.Time              `json:"resolved_at,omitempty"`
+	Comments           []QuestionReportComment `json:"comments,omitempty"`
+}
+
+type QuestionReportComment struct {
+	ID        int64     `json:"id"`
+	ReportID  uuid.UUID `json:"report_id"`
+	Author    string    `json:"author"`
+	Body      string    `json:"body"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// QuestionFamily assigns a question to a template family.
+type QuestionFamily struct {
+	QuestionID int    `json:"question_id"`
+	Family     string `json:"family"`
+}
+
+// EnemyPair marks two questions that give each other away and must not be
+// served in the same attempt. QuestionID is always the lower of the two.
+type EnemyPair struct {
+	QuestionID int       `json:"question_id"`
+	EnemyID    int       `json:"enemy_id"`
+	Reason     string    `json:"reason,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+type QuestionReportRequest struct {
+	UserID    string `json:"user_id"`
+	AttemptID string `json:"attempt_id"`
+	Category  string `json:"category"`
+	Message   string `json:"message"`
+}
+
+// QuestionReportUpdate is an admin triage change. Nil fields are left as
+// they are.
+type QuestionReportUpdate struct {
+	Status             *string `json:"status"`
+	Resolution         *string `json:"resolution"`
+	CorrectedChoiceIDs []int   `json:"corrected_choice_ids"`
+	Rescore            bool    `json:"rescore"`
+}
+
+// RegradeJob regrades stored answers against the current answer key. A job
+// without a question checks every answered question.
+type RegradeJob struct {
+	ID              uuid.UUID  `json:"id"`
+	QuestionID      *int       `json:"question_id,omitempty"`
+	Reason          string     `json:"reason"`
+	Status          string     `json:"status"`
+	AttemptsChecked int        `json:"attempts_checked"`
+	AttemptsChanged int        `json:"attempts_changed"`
+	Error           string     `json:"error,omitempty"`
+	CreatedAt       time.Time  `json:"created_at"`
+	StartedAt       *time.Time `json:"started_at,omitempty"`
+	FinishedAt      *time.Time `json:"finished_at,omitempty"`
+}
+
+// AnswerCorrection is a regraded answer row whose stored correctness no
+// longer matches the answer key.
+type AnswerCorrection struct {
+	AnswerID  uuid.UUID
+	IsCorrect bool
+}
+
+// AttemptRegrade is everything written when one attempt is regraded. Message
+// builds the text of the notification sent when the score moves.
+type AttemptRegrade struct {
+	JobID            uuid.UUID
+	AttemptID        uuid.UUID
+	Corrections      []AnswerCorrection
+	NotificationKind string
+	Message          func(startedAt time.Time, oldScore, newScore int) string
+}
+
+type ScoreAdjustment struct {
+	ID        int64      `json:"id"`
+	JobID     *uuid.UUID `json:"job_id,omitempty"`
+	AttemptID uuid.UUID  `json:"attempt_id"`
+	UserID    uuid.UUID  `json:"user_id"`
+	OldScore  int        `json:"old_score"`
+	NewScore  int        `json:"new_score"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+type UserNotification struct {
+	ID        int64      `json:"id"`
+	UserID    uuid.UUID  `json:"user_id"`
+	AttemptID *uuid.UUID `json:"attempt_id,omitempty"`
+	Kind      string     `json:"kind"`
+	Message   string     `json:"message"`
+	ReadAt    *time.Time `json:"read_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// AuditEntry records one state change: who made it, what it touched and the
+// entity before and after, as JSON.
+type AuditEntry struct {
+	ID         int64           `json:"id"`
+	OccurredAt time.Time       `json:"occurred_at"`
+	Actor      string          `json:"actor"`
+	Action     string          `json:"action"`
+	EntityType string          `json:"entity_type"`
+	EntityID   string          `json:"entity_id"`
+	UserID     *uuid.UUID      `json:"user_id,omitempty"`
+	Before     json.RawMessage `json:"before,omitempty"`
+	After      json.RawMessage `json:"after,omitempty"`
+	RequestID  string          `json:"request_id,omitempty"`
+	IP         string          `json:"ip,omitempty"`
+}
+
+// AuditFilter narrows an audit log query. Zero values match everything.
+type AuditFilter struct {
+	UserID     *uuid.UUID
+	EntityType string
+	EntityID   string
+	Action     string
+	Since      *time.Time
+	Until      *time.Time
+	Limit      int
+}
+
+// HealthReport is the body of /healthz and /readyz. Checks maps each
+// dependency to "ok" or what is wrong with it.
+type HealthReport struct {
+	Status                string            `json:"status"`
+	Checks                map[string]string `json:"checks"`
+	SchemaVersion         int               `json:"schema_version,omitempty"`
+	ExpectedSchemaVersion int               `json:"expected_schema_version,omitempty"`
 }
 
 type ExamQuestion struct {
@@ -68,6 +685,42 @@ type ExamQuestion struct {
 
 type ExamSubmission struct {
 	Answers []AnswerSubmission `json:"answers"`
+	// IdempotencyKey comes from the Idempotency-Key header; a retried submit
+	// with the same key returns the original result.
+	IdempotencyKey string `json:"-"`
+}
+
+// GradedChoice is one selected choice of a graded answer, as stored in
+// attempt_answers.
+type GradedChoice struct {
+	QuestionID int
+	ChoiceID   int
+	IsCorrect  bool
+}
+
+// AttemptSubmission is everything written when an attempt is graded in one
+// go.
+type AttemptSubmission struct {
+	AttemptID      uuid.UUID
+	Score          int
+	Answers        []GradedChoice
+	Selections     []AnswerSelection
+	IdempotencyKey string
+}
+
+// SectionClose is everything written when a section of a sectioned attempt
+// ends. Section and Status are the state the attempt was loaded in; the close
+// only applies while the attempt is still in it. Final closes the attempt
+// instead of starting a break.
+type SectionClose struct {
+	AttemptID      uuid.UUID
+	Section        int
+	Status         string
+	Answers        []GradedChoice
+	Selections     []AnswerSelection
+	Final          bool
+	BreakStartedAt *time.Time
+	IdempotencyKey string
 }
 
 type AnswerSubmission struct {
@@ -81,9 +734,56 @@ type ExamResult struct {
 	ExamID    uuid.UUID        `json:"exam_id"`
 	Score     int              `json:"score"`
 	MaxScore  int              `json:"max_score"`
+	PassMark  float64          `json:"pass_mark"`
+	Passed    bool             `json:"passed"`
 	StartedAt time.Time        `json:"started_at"`
 	EndedAt   *time.Time       `json:"ended_at,omitempty"`
 	Results   []QuestionResult `json:"results"`
+	Pacing    *PacingAnalytics `json:"pacing,omitempty"`
+}
+
+// PacingAnalytics separates time pressure from knowledge gaps using the
+// per-question events captured during the attempt.
+type PacingAnalytics struct {
+	TotalSeconds               float64        `json:"total_seconds"`
+	ExpectedSecondsPerQuestion float64        `json:"expected_seconds_per_question"`
+	TimePerDomain              []DomainTime   `json:"time_per_domain"`
+	SlowestQuestions           []QuestionTime `json:"slowest_questions"`
+	Quarters                   []QuarterPace  `json:"quarters"`
+	RightToWrong               []AnswerChange `json:"right_to_wrong"`
+}
+
+type DomainTime struct {
+	Domain         string  `json:"domain"`
+	Questions      int     `json:"questions"`
+	TotalSeconds   float64 `json:"total_seconds"`
+	AverageSeconds float64 `json:"average_seconds"`
+}
+
+type QuestionTime struct {
+	QuestionID int     `json:"question_id"`
+	Position   int     `json:"position"`
+	Domain     string  `json:"domain"`
+	Seconds    float64 `json:"seconds"`
+	IsCorrect  bool    `json:"is_correct"`
+}
+
+// QuarterPace compares the time spent on the questions up to the end of a
+// quarter of the exam with the time the blueprint allows for them.
+type QuarterPace struct {
+	Quarter         int     `json:"quarter"`
+	ThroughPosition int     `json:"through_position"`
+	ElapsedSeconds  float64 `json:"elapsed_seconds"`
+	ExpectedSeconds float64 `json:"expected_seconds"`
+	Status          string  `json:"status"` // ahead, on_pace, behind
+}
+
+type AnswerChange struct {
+	QuestionID    int    `json:"question_id"`
+	Position      int    `json:"position"`
+	Domain        string `json:"domain"`
+	FromChoiceIDs []int  `json:"from_choice_ids"`
+	ToChoiceIDs   []int  `json:"to_choice_ids"`
 }
 
 type AttemptHistory struct {
@@ -96,6 +796,8 @@ type AttemptHistory struct {
 	MaxScore      int        `json:"max_score"`
 	QuestionCount int        `json:"question_count"`
 	AttemptType   string     `json:"attempt_type"`
+	Feedback      string     `json:"feedback"`
+	Passed        *bool      `json:"passed,omitempty"`
 }
 
 type QuestionResult struct {
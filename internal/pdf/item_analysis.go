@@ -0,0 +1,111 @@
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"time"
+
+	"capm-exam-system/internal/models"
+
+	"github.com/jung-kurt/gofpdf/v2"
+)
+
+func (p *PDFService) GenerateItemAnalysisReport(report *models.ItemAnalysisReport) (*bytes.Buffer, error) {
+	defer observeDuration("item_analysis", time.Now())
+
+	pdf := gofpdf.New("L", "mm", "A4", "")
+	pdf.SetMargins(10, 15, 10)
+	pdf.AddPage()
+
+	pdf.SetFont("Arial", "B", 18)
+	pdf.Cell(277, 10, "Item Analysis Report")
+	pdf.Ln(8)
+
+	pdf.SetFont("Arial", "", 10)
+	pdf.Cell(277, 6, fmt.Sprintf("%d questions across %d submitted attempts - generated %s UTC",
+		len(report.Items), report.Attempts, report.GeneratedAt.Format("2006-01-02 15:04")))
+	pdf.Ln(8)
+
+	widths := []float64{14, 52, 16, 16, 18, 18, 16, 80, 47}
+	headers := []string{"ID", "Domain", "Served", "Answered", "% Correct", "Avg sec", "D", "Options (chosen, * = key)", "Flags"}
+
+	writeHeader := func() {
+		pdf.SetFont("Arial", "B", 9)
+		pdf.SetFillColor(242, 242, 242)
+		for i, h := range headers {
+			pdf.CellFormat(widths[i], 7, h, "1", 0, "L", true, 0, "")
+		}
+		pdf.Ln(-1)
+		pdf.SetFont("Arial", "", 8)
+	}
+	writeHeader()
+
+	for _, item := range report.Items {
+		if pdf.GetY() > 185 {
+			pdf.AddPage()
+			writeHeader()
+		}
+
+		flagged := len(item.Flags) > 0
+		if flagged {
+			pdf.SetFillColor(253, 236, 234)
+		} else {
+			pdf.SetFillColor(255, 255, 255)
+		}
+
+		cells := []string{
+			fmt.Sprintf("%d", item.QuestionID),
+			truncate(item.Domain, 32),
+			fmt.Sprintf("%d", item.Served),
+			fmt.Sprintf("%d", item.Answered),
+			fmt.Sprintf("%.1f%%", item.PercentCorrect),
+			optionalFloat(item.AverageSeconds, "%.0f"),
+			optionalFloat(item.Discrimination, "%.2f"),
+			truncate(OptionSummary(item.Options), 60),
+			strings.Join(item.Flags, ", "),
+		}
+		for i, c := range cells {
+			pdf.CellFormat(widths[i], 6, c, "1", 0, "L", flagged, 0, "")
+		}
+		pdf.Ln(-1)
+
+		pdf.SetFont("Arial", "I", 7)
+		pdf.CellFormat(277, 5, truncate(item.Prompt, 220), "LRB", 1, "L", false, 0, "")
+		pdf.SetFont("Arial", "", 8)
+	}
+
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		return nil, fmt.Errorf("failed to generate PDF: %v", err)
+	}
+
+	return &buf, nil
+}
+
+// OptionSummary renders the choice distribution as "A*:12 B:3 ...".
+func OptionSummary(options []models.OptionStat) string {
+	parts := make([]string, 0, len(options))
+	for _, option := range options {
+		label := option.Label
+		if option.IsCorrect {
+			label += "*"
+		}
+		parts = append(parts, fmt.Sprintf("%s:%d (%.0f%%)", label, option.Chosen, option.Share*100))
+	}
+	return strings.Join(parts, " ")
+}
+
+func optionalFloat(v *float64, format string) string {
+	if v == nil {
+		return "-"
+	}
+	return fmt.Sprintf(format, *v)
+}
+
+func truncate(s string, max int) string {
+	if len(s) <= max {
+		return s
+	}
+	return s[:max] + "..."
+}
@@ -6,18 +6,28 @@ import (
 	"strings"
 	"time"
 
+	"capm-exam-system/internal/config"
+	"capm-exam-system/internal/metrics"
 	"capm-exam-system/internal/models"
 
 	"github.com/jung-kurt/gofpdf/v2"
 )
 
-type PDFService struct{}
+type PDFService struct {
+	passMark float64
+}
+
+func New(cfg config.ExamConfig) *PDFService {
+	return &PDFService{passMark: cfg.PassMark}
+}
 
-func New() *PDFService {
-	return &PDFService{}
+func observeDuration(report string, start time.Time) {
+	metrics.PDFGenerationDuration.WithLabelValues(report).Observe(metrics.Since(start))
 }
 
 func (p *PDFService) GenerateExamReport(result *models.ExamResult) (*bytes.Buffer, error) {
+	defer observeDuration("exam", time.Now())
+
 	pdf := gofpdf.New("P", "mm", "A4", "")
 	pdf.SetMargins(10, 15, 10)
 	pdf.AddPage()
@@ -40,7 +50,7 @@ func (p *PDFService) GenerateExamReport(result *models.ExamResult) (*bytes.Buffe
 	pdf.Ln(7)
 
 	status := "FAIL"
-	if percentage >= 70 {
+	if percentage >= p.passMark*100 {
 		status = "PASS"
 	}
 	pdf.SetFont("Arial", "B", 12)
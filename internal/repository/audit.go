@@ -0,0 +1,91 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"capm-exam-system/internal/models"
+)
+
+// CreateAuditEntry appends to the audit log. The table rejects updates and
+// deletes, so this is the only write it ever sees.
+func (r *Repository) CreateAuditEntry(ctx context.Context, entry *models.AuditEntry) error {
+	err := r.db.Pool.QueryRow(ctx,
+		`INSERT INTO audit_log (actor, action, entity_type, entity_id, user_id, before, after, request_id, ip)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		 RETURNING id, occurred_at`,
+		entry.Actor, entry.Action, entry.EntityType, entry.EntityID, entry.UserID,
+		nullableJSON(entry.Before), nullableJSON(entry.After), entry.RequestID, entry.IP,
+	).Scan(&entry.ID, &entry.OccurredAt)
+	if err != nil {
+		return fmt.Errorf("failed to create audit entry: %v", err)
+	}
+	return nil
+}
+
+// ListAuditEntries returns matching entries newest first.
+func (r *Repository) ListAuditEntries(ctx context.Context, filter models.AuditFilter) ([]models.AuditEntry, error) {
+	conditions := make([]string, 0, 6)
+	args := make([]interface{}, 0, 7)
+	if filter.UserID != nil {
+		args = append(args, *filter.UserID)
+		conditions = append(conditions, fmt.Sprintf("user_id = $%d", len(args)))
+	}
+	if filter.EntityType != "" {
+		args = append(args, filter.EntityType)
+		conditions = append(conditions, fmt.Sprintf("entity_type = $%d", len(args)))
+	}
+	if filter.EntityID != "" {
+		args = append(args, filter.EntityID)
+		conditions = append(conditions, fmt.Sprintf("entity_id = $%d", len(args)))
+	}
+	if filter.Action != "" {
+		args = append(args, filter.Action)
+		conditions = append(conditions, fmt.Sprintf("action = $%d", len(args)))
+	}
+	if filter.Since != nil {
+		args = append(args, *filter.Since)
+		conditions = append(conditions, fmt.Sprintf("occurred_at >= $%d", len(args)))
+	}
+	if filter.Until != nil {
+		args = append(args, *filter.Until)
+		conditions = append(conditions, fmt.Sprintf("occurred_at < $%d", len(args)))
+	}
+
+	query := `SELECT id, occurred_at, actor, action, entity_type, entity_id, user_id, before, after, request_id, ip
+		FROM audit_log`
+	if len(conditions) > 0 {
+		query += ` WHERE ` + strings.Join(conditions, " AND ")
+	}
+	args = append(args, filter.Limit)
+	query += fmt.Sprintf(` ORDER BY occurred_at DESC, id DESC LIMIT $%d`, len(args))
+
+	rows, err := r.db.Pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list audit entries: %v", err)
+	}
+	defer rows.Close()
+
+	var entries []models.AuditEntry
+	for rows.Next() {
+		var entry models.AuditEntry
+		var before, after []byte
+		if err := rows.Scan(&entry.ID, &entry.OccurredAt, &entry.Actor, &entry.Action, &entry.EntityType,
+			&entry.EntityID, &entry.UserID, &before, &after, &entry.RequestID, &entry.IP); err != nil {
+			return nil, fmt.Errorf("failed to scan audit entry: %v", err)
+		}
+		entry.Before = before
+		entry.After = after
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// nullableJSON stores an absent payload as SQL NULL rather than JSON null.
+func nullableJSON(payload []byte) interface{} {
+	if len(payload) == 0 {
+		return nil
+	}
+	return string(payload)
+}
@@ -0,0 +1,135 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	"capm-exam-system/internal/tracing"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// bankCacheTTL bounds how stale the cached question bank can get when
+// another process, such as the seed command, changes the questions. Changes
+// made through this repository invalidate the cache immediately.
+const bankCacheTTL = 5 * time.Minute
+
+// questionBank is the part of every question that exam assembly needs,
+// grouped by domain in a stable order so draws are reproducible per seed.
+type questionBank struct {
+	byDomain map[string][]selectionCandidate
+	enemies  map[int][]int
+	loadedAt time.Time
+}
+
+type bankCache struct {
+	mu   sync.Mutex
+	bank *questionBank
+}
+
+// invalidateBank drops the cached bank after a change to questions,
+// families or enemy pairs.
+func (r *Repository) invalidateBank() {
+	r.bank.mu.Lock()
+	r.bank.bank = nil
+	r.bank.mu.Unlock()
+}
+
+// questionBank returns the cached bank, loading it when it is missing or
+// older than bankCacheTTL. Concurrent callers wait for a single load.
+func (r *Repository) questionBank(ctx context.Context) (*questionBank, error) {
+	r.bank.mu.Lock()
+	defer r.bank.mu.Unlock()
+
+	if r.bank.bank != nil && time.Since(r.bank.bank.loadedAt) < bankCacheTTL {
+		return r.bank.bank, nil
+	}
+
+	bank, err := r.loadQuestionBank(ctx)
+	if err != nil {
+		return nil, err
+	}
+	r.bank.bank = bank
+	return bank, nil
+}
+
+func (r *Repository) loadQuestionBank(ctx context.Context) (*questionBank, error) {
+	ctx, span := tracing.Start(ctx, "repository.loadQuestionBank")
+	defer span.End()
+
+	rows, err := r.db.Pool.Query(ctx, `
+		SELECT id, domain, popularity_score, COALESCE(family, '')
+		FROM questions
+		ORDER BY domain, popularity_score DESC, id`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load question bank: %v", err)
+	}
+	defer rows.Close()
+
+	bank := &questionBank{byDomain: make(map[string][]selectionCandidate)}
+	count := 0
+	for rows.Next() {
+		var q selectionCandidate
+		var domain string
+		if err := rows.Scan(&q.ID, &domain, &q.Weight, &q.Family); err != nil {
+			return nil, fmt.Errorf("failed to scan question: %v", err)
+		}
+		bank.byDomain[domain] = append(bank.byDomain[domain], q)
+		count++
+	}
+	rows.Close()
+
+	bank.enemies, err = r.getQuestionEnemies(ctx)
+	if err != nil {
+		return nil, err
+	}
+	bank.loadedAt = time.Now()
+
+	span.SetAttributes(attribute.Int("capm.question.count", count))
+	return bank, nil
+}
+
+// DomainDraw asks for Count questions from Domain, drawn with Seed.
+type DomainDraw struct {
+	Domain string
+	Count  int
+	Seed   int64
+}
+
+// DrawQuestions assembles an attempt from the cached bank, one domain after
+// another in the order given, weighted by popularity and reproducible for
+// the seeds. sel carries the weight adjustments for the attempt and records
+// the picks, so families and enemy pairs are respected across domains.
+func (r *Repository) DrawQuestions(ctx context.Context, sel *Selection, draws []DomainDraw) ([]int, error) {
+	ctx, span := tracing.Start(ctx, "repository.DrawQuestions")
+	defer span.End()
+
+	bank, err := r.questionBank(ctx)
+	if err != nil {
+		return nil, err
+	}
+	sel.enemies = bank.enemies
+
+	total := 0
+	for _, d := range draws {
+		total += d.Count
+	}
+	span.SetAttributes(attribute.Int("capm.question.count", total))
+
+	selected := make([]int, 0, total)
+	for _, d := range draws {
+		if d.Count <= 0 {
+			continue
+		}
+		candidates := bank.byDomain[d.Domain]
+		if available := sel.available(candidates); available < d.Count {
+			return nil, fmt.Errorf("not enough questions available in domain %s: have %d, need %d", d.Domain, available, d.Count)
+		}
+		rng := rand.New(rand.NewSource(d.Seed))
+		selected = append(selected, sel.pick(rng, candidates, d.Count)...)
+	}
+	return selected, nil
+}
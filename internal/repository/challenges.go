@@ -0,0 +1,89 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"capm-exam-system/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+const challengeColumns = "id, creator_id, exam_id, question_ids, seed, sectioned, time_limit_seconds, feedback, created_at"
+
+func scanChallenge(row pgx.Row, challenge *models.Challenge) error {
+	return row.Scan(&challenge.ID, &challenge.CreatorID, &challenge.ExamID, &challenge.QuestionIDs, &challenge.Seed,
+		&challenge.Sectioned, &challenge.TimeLimitSeconds, &challenge.Feedback, &challenge.CreatedAt)
+}
+
+// CreateChallenge freezes a question set, in serving order, with the seed
+// and settings every attempt started from it will use. The attempt it was
+// taken from joins the challenge too.
+func (r *Repository) CreateChallenge(ctx context.Context, challenge *models.Challenge, sourceAttemptID uuid.UUID) (*models.Challenge, error) {
+	var created models.Challenge
+	err := scanChallenge(r.db.Pool.QueryRow(ctx,
+		`WITH created AS (
+			INSERT INTO challenges (creator_id, exam_id, question_ids, seed, sectioned, time_limit_seconds, feedback)
+			VALUES ($1, $2, $3, $4, $5, $6, $7)
+			RETURNING `+challengeColumns+`
+		 ), linked AS (
+			UPDATE attempts SET challenge_id = (SELECT id FROM created)
+			WHERE id = $8 AND challenge_id IS NULL
+		 )
+		 SELECT `+challengeColumns+` FROM created`,
+		challenge.CreatorID, challenge.ExamID, challenge.QuestionIDs, challenge.Seed, challenge.Sectioned,
+		challenge.TimeLimitSeconds, challenge.Feedback, sourceAttemptID), &created)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create challenge: %v", err)
+	}
+	return &created, nil
+}
+
+func (r *Repository) GetChallenge(ctx context.Context, challengeID uuid.UUID) (*models.Challenge, error) {
+	var challenge models.Challenge
+	err := scanChallenge(r.db.Pool.QueryRow(ctx,
+		`SELECT `+challengeColumns+` FROM challenges WHERE id = $1`, challengeID), &challenge)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get challenge: %v", err)
+	}
+	return &challenge, nil
+}
+
+// GetChallengeDomainResults returns, for every submitted attempt started from
+// the challenge, its question and correct counts per domain. Unanswered
+// questions count as served but not correct.
+func (r *Repository) GetChallengeDomainResults(ctx context.Context, challengeID uuid.UUID) ([]models.ChallengeDomainResult, error) {
+	rows, err := r.db.Pool.Query(ctx,
+		`SELECT a.id, u.name, COALESCE(a.score, 0), a.max_score, a.ended_at, q.domain,
+		        COUNT(*),
+		        COUNT(*) FILTER (WHERE EXISTS (
+		            SELECT 1 FROM attempt_answers aa
+		            WHERE aa.attempt_id = aq.attempt_id AND aa.question_id = aq.question_id AND aa.is_correct
+		        ))
+		 FROM attempts a
+		 JOIN users u ON u.id = a.user_id
+		 JOIN attempt_questions aq ON aq.attempt_id = a.id
+		 JOIN questions q ON q.id = aq.question_id
+		 WHERE a.challenge_id = $1 AND a.ended_at IS NOT NULL
+		 GROUP BY a.id, u.name, a.score, a.max_score, a.ended_at, q.domain
+		 ORDER BY a.ended_at, a.id, q.domain`, challengeID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get challenge results: %v", err)
+	}
+	defer rows.Close()
+
+	var results []models.ChallengeDomainResult
+	for rows.Next() {
+		var result models.ChallengeDomainResult
+		if err := rows.Scan(&result.AttemptID, &result.Name, &result.Score, &result.MaxScore, &result.EndedAt,
+			&result.Domain, &result.Total, &result.Correct); err != nil {
+			return nil, fmt.Errorf("failed to scan challenge result: %v", err)
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
@@ -0,0 +1,272 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"capm-exam-system/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+const cohortColumns = `id, name, description, instructor_id, invite_code, created_at`
+
+func scanCohort(row pgx.Row, cohort *models.Cohort) error {
+	return row.Scan(&cohort.ID, &cohort.Name, &cohort.Description, &cohort.InstructorID, &cohort.InviteCode, &cohort.CreatedAt)
+}
+
+// CreateCohort stores a cohort, or returns nil when another cohort already
+// has the invite code.
+func (r *Repository) CreateCohort(ctx context.Context, name, description string, instructorID uuid.UUID, inviteCode string) (*models.Cohort, error) {
+	var cohort models.Cohort
+	err := scanCohort(r.db.Pool.QueryRow(ctx,
+		`INSERT INTO cohorts (name, description, instructor_id, invite_code)
+		 VALUES ($1, $2, $3, $4)
+		 ON CONFLICT (invite_code) DO NOTHING
+		 RETURNING `+cohortColumns,
+		name, description, instructorID, inviteCode), &cohort)
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cohort: %v", err)
+	}
+	return &cohort, nil
+}
+
+func (r *Repository) GetCohort(ctx context.Context, cohortID uuid.UUID) (*models.Cohort, error) {
+	var cohort models.Cohort
+	err := scanCohort(r.db.Pool.QueryRow(ctx,
+		`SELECT `+cohortColumns+` FROM cohorts WHERE id = $1`, cohortID), &cohort)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get cohort: %v", err)
+	}
+	return &cohort, nil
+}
+
+func (r *Repository) GetCohortByInviteCode(ctx context.Context, inviteCode string) (*models.Cohort, error) {
+	var cohort models.Cohort
+	err := scanCohort(r.db.Pool.QueryRow(ctx,
+		`SELECT `+cohortColumns+` FROM cohorts WHERE invite_code = $1`, inviteCode), &cohort)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get cohort by invite code: %v", err)
+	}
+	return &cohort, nil
+}
+
+// GetCohortsForUser returns the cohorts the user teaches or belongs to.
+func (r *Repository) GetCohortsForUser(ctx context.Context, userID uuid.UUID) ([]models.Cohort, error) {
+	rows, err := r.db.Pool.Query(ctx,
+		`SELECT `+cohortColumns+`
+		 FROM cohorts
+		 WHERE instructor_id = $1
+		    OR id IN (SELECT cohort_id FROM cohort_members WHERE user_id = $1)
+		 ORDER BY created_at DESC`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get cohorts: %v", err)
+	}
+	defer rows.Close()
+
+	var cohorts []models.Cohort
+	for rows.Next() {
+		var cohort models.Cohort
+		if err := scanCohort(rows, &cohort); err != nil {
+			return nil, fmt.Errorf("failed to scan cohort: %v", err)
+		}
+		cohorts = append(cohorts, cohort)
+	}
+	return cohorts, nil
+}
+
+// AddCohortMember enrols the user; enrolling twice keeps the original join date.
+func (r *Repository) AddCohortMember(ctx context.Context, cohortID, userID uuid.UUID) error {
+	_, err := r.db.Pool.Exec(ctx,
+		`INSERT INTO cohort_members (cohort_id, user_id)
+		 VALUES ($1, $2)
+		 ON CONFLICT (cohort_id, user_id) DO NOTHING`,
+		cohortID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to add cohort member: %v", err)
+	}
+	return nil
+}
+
+func (r *Repository) IsCohortMember(ctx context.Context, cohortID, userID uuid.UUID) (bool, error) {
+	var exists bool
+	err := r.db.Pool.QueryRow(ctx,
+		`SELECT EXISTS (SELECT 1 FROM cohort_members WHERE cohort_id = $1 AND user_id = $2)`,
+		cohortID, userID).Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("failed to check cohort membership: %v", err)
+	}
+	return exists, nil
+}
+
+func (r *Repository) GetCohortMembers(ctx context.Context, cohortID uuid.UUID) ([]models.CohortMember, error) {
+	rows, err := r.db.Pool.Query(ctx,
+		`SELECT u.id, u.name, u.email, m.joined_at
+		 FROM cohort_members m
+		 JOIN users u ON u.id = m.user_id
+		 WHERE m.cohort_id = $1
+		 ORDER BY u.name`, cohortID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get cohort members: %v", err)
+	}
+	defer rows.Close()
+
+	var members []models.CohortMember
+	for rows.Next() {
+		var member models.CohortMember
+		if err := rows.Scan(&member.UserID, &member.Name, &member.Email, &member.JoinedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan cohort member: %v", err)
+		}
+		members = append(members, member)
+	}
+	return members, nil
+}
+
+func (r *Repository) CreateCohortAssignment(ctx context.Context, cohortID uuid.UUID, kind, title string, dueAt *time.Time) (*models.CohortAssignment, error) {
+	var assignment models.CohortAssignment
+	err := r.db.Pool.QueryRow(ctx,
+		`INSERT INTO cohort_assignments (cohort_id, kind, title, due_at)
+		 VALUES ($1, $2, $3, $4)
+		 RETURNING id, cohort_id, kind, title, due_at, created_at`,
+		cohortID, kind, title, dueAt).Scan(&assignment.ID, &assignment.CohortID, &assignment.Kind, &assignment.Title, &assignment.DueAt, &assignment.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cohort assignment: %v", err)
+	}
+	return &assignment, nil
+}
+
+func (r *Repository) GetCohortAssignments(ctx context.Context, cohortID uuid.UUID) ([]models.CohortAssignment, error) {
+	rows, err := r.db.Pool.Query(ctx,
+		`SELECT id, cohort_id, kind, title, due_at, created_at
+		 FROM cohort_assignments
+		 WHERE cohort_id = $1
+		 ORDER BY due_at NULLS LAST, created_at`, cohortID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get cohort assignments: %v", err)
+	}
+	defer rows.Close()
+
+	var assignments []models.CohortAssignment
+	for rows.Next() {
+		var assignment models.CohortAssignment
+		if err := rows.Scan(&assignment.ID, &assignment.CohortID, &assignment.Kind, &assignment.Title, &assignment.DueAt, &assignment.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan cohort assignment: %v", err)
+		}
+		assignments = append(assignments, assignment)
+	}
+	return assignments, nil
+}
+
+// GetCohortAttempts returns the submitted attempts members made after joining
+// the cohort, oldest first.
+func (r *Repository) GetCohortAttempts(ctx context.Context, cohortID uuid.UUID) ([]models.CohortAttempt, error) {
+	rows, err := r.db.Pool.Query(ctx,
+		`SELECT a.user_id, a.id, e.name, a.score, a.max_score, a.ended_at
+		 FROM attempts a
+		 JOIN exams e ON e.id = a.exam_id
+		 JOIN cohort_members m ON m.user_id = a.user_id AND m.cohort_id = $1
+		 WHERE a.ended_at IS NOT NULL AND a.score IS NOT NULL AND a.ended_at >= m.joined_at
+		 ORDER BY a.ended_at`, cohortID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get cohort attempts: %v", err)
+	}
+	defer rows.Close()
+
+	var attempts []models.CohortAttempt
+	for rows.Next() {
+		var attempt models.CohortAttempt
+		var examName string
+		if err := rows.Scan(&attempt.UserID, &attempt.AttemptID, &examName, &attempt.Score, &attempt.MaxScore, &attempt.EndedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan cohort attempt: %v", err)
+		}
+		attempt.AttemptType = AttemptType(examName, attempt.MaxScore)
+		attempts = append(attempts, attempt)
+	}
+	return attempts, nil
+}
+
+// GetCohortDomainResults returns each member's per-domain answer counts over
+// the attempts submitted since joining.
+func (r *Repository) GetCohortDomainResults(ctx context.Context, cohortID uuid.UUID) ([]models.CohortDomainResult, error) {
+	rows, err := r.db.Pool.Query(ctx,
+		`SELECT a.user_id, q.domain,
+		        COUNT(*),
+		        COUNT(*) FILTER (WHERE aq.is_correct)
+		 FROM (
+			SELECT attempt_id, question_id, BOOL_OR(COALESCE(is_correct, FALSE)) AS is_correct
+			FROM attempt_answers
+			GROUP BY attempt_id, question_id
+		 ) aq
+		 JOIN attempts a ON a.id = aq.attempt_id
+		 JOIN cohort_members m ON m.user_id = a.user_id AND m.cohort_id = $1
+		 JOIN questions q ON q.id = aq.question_id
+		 WHERE a.ended_at IS NOT NULL AND a.ended_at >= m.joined_at
+		 GROUP BY a.user_id, q.domain`, cohortID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get cohort domain results: %v", err)
+	}
+	defer rows.Close()
+
+	var results []models.CohortDomainResult
+	for rows.Next() {
+		var result models.CohortDomainResult
+		if err := rows.Scan(&result.UserID, &result.Domain, &result.Answered, &result.Correct); err != nil {
+			return nil, fmt.Errorf("failed to scan cohort domain result: %v", err)
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// GetCohortMissedQuestions ranks questions by how many members got them wrong
+// at least once since joining.
+func (r *Repository) GetCohortMissedQuestions(ctx context.Context, cohortID uuid.UUID, limit int) ([]models.MissedQuestion, error) {
+	rows, err := r.db.Pool.Query(ctx,
+		`WITH member_answers AS (
+			SELECT a.user_id, aa.question_id, BOOL_AND(COALESCE(aa.is_correct, FALSE)) AS always_correct
+			FROM attempt_answers aa
+			JOIN attempts a ON a.id = aa.attempt_id
+			JOIN cohort_members m ON m.user_id = a.user_id AND m.cohort_id = $1
+			WHERE a.ended_at IS NOT NULL AND a.ended_at >= m.joined_at
+			GROUP BY a.user_id, aa.question_id
+		 )
+		 SELECT q.id, q.prompt, q.domain,
+		        COUNT(*),
+		        COUNT(*) FILTER (WHERE NOT ma.always_correct)
+		 FROM member_answers ma
+		 JOIN questions q ON q.id = ma.question_id
+		 GROUP BY q.id, q.prompt, q.domain
+		 HAVING COUNT(*) FILTER (WHERE NOT ma.always_correct) > 0
+		 ORDER BY COUNT(*) FILTER (WHERE NOT ma.always_correct) DESC,
+		          COUNT(*) FILTER (WHERE NOT ma.always_correct)::float / COUNT(*) DESC,
+		          q.id
+		 LIMIT $2`, cohortID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get cohort missed questions: %v", err)
+	}
+	defer rows.Close()
+
+	var questions []models.MissedQuestion
+	for rows.Next() {
+		var question models.MissedQuestion
+		if err := rows.Scan(&question.QuestionID, &question.Prompt, &question.Domain, &question.Answered, &question.Missed); err != nil {
+			return nil, fmt.Errorf("failed to scan missed question: %v", err)
+		}
+		if question.Answered > 0 {
+			question.MissRate = float64(question.Missed) / float64(question.Answered)
+		}
+		questions = append(questions, question)
+	}
+	return questions, nil
+}
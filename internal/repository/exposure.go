@@ -0,0 +1,109 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"capm-exam-system/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// GetRecentQuestionIDs returns the questions served to the user in their
+// last `attempts` attempts or since `since`, not counting excludeAttemptID.
+func (r *Repository) GetRecentQuestionIDs(ctx context.Context, userID, excludeAttemptID uuid.UUID, attempts int, since time.Time) ([]int, error) {
+	rows, err := r.db.Pool.Query(ctx,
+		`WITH recent AS (
+			SELECT id FROM (
+				SELECT id FROM attempts
+				WHERE user_id = $1 AND id <> $2
+				ORDER BY started_at DESC
+				LIMIT $3
+			) latest
+			UNION
+			SELECT id FROM attempts
+			WHERE user_id = $1 AND id <> $2 AND started_at >= $4
+		)
+		SELECT DISTINCT aq.question_id
+		FROM attempt_questions aq
+		JOIN recent ON recent.id = aq.attempt_id`,
+		userID, excludeAttemptID, attempts, since)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get recent questions: %v", err)
+	}
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan recent question: %v", err)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// GetExamExposure counts the exam's attempts started since `since` and how
+// many of them served each question. Other attempt lengths of the same exam
+// (the short quiz shares the mock exam's questions) are counted separately
+// by maxScore.
+func (r *Repository) GetExamExposure(ctx context.Context, examID uuid.UUID, maxScore int, since time.Time) (int, map[int]int, error) {
+	var attempts int
+	err := r.db.Pool.QueryRow(ctx,
+		`SELECT COUNT(*) FROM attempts WHERE exam_id = $1 AND max_score = $2 AND started_at >= $3`,
+		examID, maxScore, since).Scan(&attempts)
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to count exam attempts: %v", err)
+	}
+
+	rows, err := r.db.Pool.Query(ctx,
+		`SELECT aq.question_id, COUNT(*)
+		 FROM attempt_questions aq
+		 JOIN attempts a ON a.id = aq.attempt_id
+		 WHERE a.exam_id = $1 AND a.max_score = $2 AND a.started_at >= $3
+		 GROUP BY aq.question_id`,
+		examID, maxScore, since)
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to get question exposure: %v", err)
+	}
+	defer rows.Close()
+
+	served := make(map[int]int)
+	for rows.Next() {
+		var questionID, count int
+		if err := rows.Scan(&questionID, &count); err != nil {
+			return 0, nil, fmt.Errorf("failed to scan question exposure: %v", err)
+		}
+		served[questionID] = count
+	}
+	return attempts, served, nil
+}
+
+// ListExamForms returns every exam and attempt length started since `since`,
+// with its attempt count, busiest first.
+func (r *Repository) ListExamForms(ctx context.Context, since time.Time) ([]models.ExamExposure, error) {
+	rows, err := r.db.Pool.Query(ctx,
+		`SELECT e.id, e.name, a.max_score, COUNT(*)
+		 FROM attempts a
+		 JOIN exams e ON e.id = a.exam_id
+		 WHERE a.started_at >= $1
+		 GROUP BY e.id, e.name, a.max_score
+		 ORDER BY COUNT(*) DESC, e.name`, since)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list exam forms: %v", err)
+	}
+	defer rows.Close()
+
+	var forms []models.ExamExposure
+	for rows.Next() {
+		var form models.ExamExposure
+		if err := rows.Scan(&form.ExamID, &form.ExamName, &form.QuestionCount, &form.Attempts); err != nil {
+			return nil, fmt.Errorf("failed to scan exam form: %v", err)
+		}
+		form.AttemptType = AttemptType(form.ExamName, form.QuestionCount)
+		forms = append(forms, form)
+	}
+	return forms, nil
+}
@@ -0,0 +1,285 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"capm-exam-system/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+const groupSessionColumns = "id, instructor_id, title, kind, join_code, time_limit_seconds, status, started_at, ends_at, created_at"
+
+func scanGroupSession(row pgx.Row, session *models.GroupSession) error {
+	return row.Scan(&session.ID, &session.InstructorID, &session.Title, &session.Kind, &session.JoinCode,
+		&session.TimeLimitSeconds, &session.Status, &session.StartedAt, &session.EndsAt, &session.CreatedAt)
+}
+
+// queryGroupSession runs a statement returning at most one session row. No
+// row is not an error: the session is missing or not in the state the
+// statement expects.
+func (r *Repository) queryGroupSession(ctx context.Context, action, query string, args ...interface{}) (*models.GroupSession, error) {
+	var session models.GroupSession
+	err := scanGroupSession(r.db.Pool.QueryRow(ctx, query, args...), &session)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to %s: %v", action, err)
+	}
+	return &session, nil
+}
+
+// CreateGroupSession stores a waiting session, or returns nil when another
+// session already has the join code.
+func (r *Repository) CreateGroupSession(ctx context.Context, instructorID uuid.UUID, title, kind, joinCode string, timeLimitSeconds int) (*models.GroupSession, error) {
+	var session models.GroupSession
+	err := scanGroupSession(r.db.Pool.QueryRow(ctx,
+		`INSERT INTO group_sessions (instructor_id, title, kind, join_code, time_limit_seconds)
+		 VALUES ($1, $2, $3, $4, $5)
+		 ON CONFLICT (join_code) DO NOTHING
+		 RETURNING `+groupSessionColumns,
+		instructorID, title, kind, joinCode, timeLimitSeconds), &session)
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create group session: %v", err)
+	}
+	return &session, nil
+}
+
+func (r *Repository) GetGroupSession(ctx context.Context, sessionID uuid.UUID) (*models.GroupSession, error) {
+	return r.queryGroupSession(ctx, "get group session",
+		`SELECT `+groupSessionColumns+` FROM group_sessions WHERE id = $1`, sessionID)
+}
+
+func (r *Repository) GetGroupSessionByJoinCode(ctx context.Context, joinCode string) (*models.GroupSession, error) {
+	return r.queryGroupSession(ctx, "get group session by join code",
+		`SELECT `+groupSessionColumns+` FROM group_sessions WHERE join_code = $1`, joinCode)
+}
+
+// StartGroupSession starts the session clock. It returns nil when the
+// session is not waiting.
+func (r *Repository) StartGroupSession(ctx context.Context, sessionID uuid.UUID) (*models.GroupSession, error) {
+	return r.queryGroupSession(ctx, "start group session",
+		`UPDATE group_sessions
+		 SET status = 'running', started_at = NOW(), ends_at = NOW() + time_limit_seconds * INTERVAL '1 second'
+		 WHERE id = $1 AND status = 'waiting'
+		 RETURNING `+groupSessionColumns, sessionID)
+}
+
+// ExtendGroupSession moves the end of a running session, and the deadline of
+// every attempt still open in it, by the given number of minutes. It returns
+// nil when the session is not running or its time is already up.
+func (r *Repository) ExtendGroupSession(ctx context.Context, sessionID uuid.UUID, minutes int) (*models.GroupSession, error) {
+	return r.queryGroupSession(ctx, "extend group session",
+		`WITH extended AS (
+			UPDATE group_sessions SET ends_at = ends_at + $2::int * INTERVAL '1 minute'
+			WHERE id = $1 AND status = 'running' AND ends_at > NOW()
+			RETURNING `+groupSessionColumns+`
+		 ), attempts_extended AS (
+			UPDATE attempts SET time_limit_seconds = time_limit_seconds + $2::int * 60
+			WHERE id IN (SELECT attempt_id FROM group_session_participants WHERE session_id = $1)
+			  AND ended_at IS NULL AND EXISTS (SELECT 1 FROM extended)
+		 )
+		 SELECT `+groupSessionColumns+` FROM extended`, sessionID, minutes)
+}
+
+// CutGroupSession ends the time of a running session now, for the session and
+// every attempt still open in it. It returns nil when the session is not
+// running or its time is already up.
+func (r *Repository) CutGroupSession(ctx context.Context, sessionID uuid.UUID) (*models.GroupSession, error) {
+	return r.queryGroupSession(ctx, "cut group session",
+		`WITH cut AS (
+			UPDATE group_sessions SET ends_at = NOW()
+			WHERE id = $1 AND status = 'running' AND ends_at > NOW()
+			RETURNING `+groupSessionColumns+`
+		 ), attempts_cut AS (
+			UPDATE attempts SET time_limit_seconds = GREATEST(0, FLOOR(EXTRACT(EPOCH FROM NOW()::timestamp - started_at)))::int
+			WHERE id IN (SELECT attempt_id FROM group_session_participants WHERE session_id = $1)
+			  AND ended_at IS NULL AND EXISTS (SELECT 1 FROM cut)
+		 )
+		 SELECT `+groupSessionColumns+` FROM cut`, sessionID)
+}
+
+// CloseGroupSession marks the session ended. It reports false when it already
+// was.
+func (r *Repository) CloseGroupSession(ctx context.Context, sessionID uuid.UUID) (bool, error) {
+	commandTag, err := r.db.Pool.Exec(ctx,
+		`UPDATE group_sessions SET status = 'ended', ends_at = LEAST(COALESCE(ends_at, NOW()), NOW())
+		 WHERE id = $1 AND status <> 'ended'`, sessionID)
+	if err != nil {
+		return false, fmt.Errorf("failed to close group session: %v", err)
+	}
+	return commandTag.RowsAffected() > 0, nil
+}
+
+// GetDueGroupSessions returns the running sessions whose time ran out before
+// the given moment.
+func (r *Repository) GetDueGroupSessions(ctx context.Context, before time.Time) ([]models.GroupSession, error) {
+	rows, err := r.db.Pool.Query(ctx,
+		`SELECT `+groupSessionColumns+` FROM group_sessions
+		 WHERE status = 'running' AND ends_at < $1
+		 ORDER BY ends_at`, before)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get due group sessions: %v", err)
+	}
+	defer rows.Close()
+
+	var sessions []models.GroupSession
+	for rows.Next() {
+		var session models.GroupSession
+		if err := scanGroupSession(rows, &session); err != nil {
+			return nil, fmt.Errorf("failed to scan group session: %v", err)
+		}
+		sessions = append(sessions, session)
+	}
+	return sessions, nil
+}
+
+func (r *Repository) AddGroupSessionParticipant(ctx context.Context, sessionID, userID uuid.UUID) error {
+	_, err := r.db.Pool.Exec(ctx,
+		`INSERT INTO group_session_participants (session_id, user_id) VALUES ($1, $2)
+		 ON CONFLICT DO NOTHING`, sessionID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to add group session participant: %v", err)
+	}
+	return nil
+}
+
+// GetGroupSessionParticipant reports whether the user joined the session and
+// the attempt they take in it, if it was started.
+func (r *Repository) GetGroupSessionParticipant(ctx context.Context, sessionID, userID uuid.UUID) (*uuid.UUID, bool, error) {
+	var attemptID *uuid.UUID
+	err := r.db.Pool.QueryRow(ctx,
+		`SELECT attempt_id FROM group_session_participants WHERE session_id = $1 AND user_id = $2`,
+		sessionID, userID).Scan(&attemptID)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, false, nil
+		}
+		return nil, false, fmt.Errorf("failed to get group session participant: %v", err)
+	}
+	return attemptID, true, nil
+}
+
+// GetWaitingParticipantIDs returns the participants who have no attempt yet.
+func (r *Repository) GetWaitingParticipantIDs(ctx context.Context, sessionID uuid.UUID) ([]uuid.UUID, error) {
+	return r.queryUUIDs(ctx, "get waiting participants",
+		`SELECT user_id FROM group_session_participants
+		 WHERE session_id = $1 AND attempt_id IS NULL
+		 ORDER BY joined_at`, sessionID)
+}
+
+// GetOpenGroupSessionAttemptIDs returns the session attempts not yet
+// submitted.
+func (r *Repository) GetOpenGroupSessionAttemptIDs(ctx context.Context, sessionID uuid.UUID) ([]uuid.UUID, error) {
+	return r.queryUUIDs(ctx, "get open group session attempts",
+		`SELECT a.id FROM group_session_participants p
+		 JOIN attempts a ON a.id = p.attempt_id
+		 WHERE p.session_id = $1 AND a.ended_at IS NULL`, sessionID)
+}
+
+func (r *Repository) queryUUIDs(ctx context.Context, action, query string, args ...interface{}) ([]uuid.UUID, error) {
+	rows, err := r.db.Pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to %s: %v", action, err)
+	}
+	defer rows.Close()
+
+	var ids []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to %s: %v", action, err)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// LinkGroupSessionAttempt records the participant's attempt and lines its
+// deadline up with the end of the session. It reports false when the
+// participant already has one.
+func (r *Repository) LinkGroupSessionAttempt(ctx context.Context, sessionID, userID, attemptID uuid.UUID) (bool, error) {
+	var linked bool
+	err := r.db.Pool.QueryRow(ctx,
+		`WITH linked AS (
+			UPDATE group_session_participants SET attempt_id = $3
+			WHERE session_id = $1 AND user_id = $2 AND attempt_id IS NULL
+			RETURNING session_id
+		 ), aligned AS (
+			UPDATE attempts a
+			SET time_limit_seconds = GREATEST(0, CEIL(EXTRACT(EPOCH FROM s.ends_at - a.started_at)))::int
+			FROM group_sessions s
+			WHERE a.id = $3 AND s.id = $1 AND s.ends_at IS NOT NULL AND EXISTS (SELECT 1 FROM linked)
+		 )
+		 SELECT EXISTS (SELECT 1 FROM linked)`, sessionID, userID, attemptID).Scan(&linked)
+	if err != nil {
+		return false, fmt.Errorf("failed to link group session attempt: %v", err)
+	}
+	return linked, nil
+}
+
+// GetAttemptGroupSessionID returns the group session the attempt is taken
+// in, or nil.
+func (r *Repository) GetAttemptGroupSessionID(ctx context.Context, attemptID uuid.UUID) (*uuid.UUID, error) {
+	var sessionID uuid.UUID
+	err := r.db.Pool.QueryRow(ctx,
+		`SELECT session_id FROM group_session_participants WHERE attempt_id = $1`, attemptID).Scan(&sessionID)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get attempt group session: %v", err)
+	}
+	return &sessionID, nil
+}
+
+// GetGroupSessionParticipants returns every participant with the progress
+// of their attempt. Answered counts come from the selections the client
+// records as the participant works, so they trail it by a few seconds.
+func (r *Repository) GetGroupSessionParticipants(ctx context.Context, sessionID uuid.UUID) ([]models.GroupSessionParticipant, error) {
+	rows, err := r.db.Pool.Query(ctx,
+		`SELECT p.user_id, u.name, p.attempt_id, COALESCE(a.max_score, 0),
+		        COALESCE((
+		            SELECT COUNT(*) FROM (
+		                SELECT DISTINCT ON (s.question_id) s.choice_ids
+		                FROM answer_selections s
+		                WHERE s.attempt_id = p.attempt_id
+		                ORDER BY s.question_id, s.selected_at DESC, s.id DESC
+		            ) latest
+		            WHERE cardinality(latest.choice_ids) > 0
+		        ), 0),
+		        a.ended_at IS NOT NULL, a.score,
+		        a.started_at + a.time_limit_seconds * INTERVAL '1 second',
+		        (SELECT MAX(e.created_at) FROM attempt_events e WHERE e.attempt_id = p.attempt_id),
+		        p.joined_at
+		 FROM group_session_participants p
+		 JOIN users u ON u.id = p.user_id
+		 LEFT JOIN attempts a ON a.id = p.attempt_id
+		 WHERE p.session_id = $1
+		 ORDER BY u.name, p.user_id`, sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get group session participants: %v", err)
+	}
+	defer rows.Close()
+
+	var participants []models.GroupSessionParticipant
+	for rows.Next() {
+		var participant models.GroupSessionParticipant
+		var submitted *bool
+		if err := rows.Scan(&participant.UserID, &participant.Name, &participant.AttemptID, &participant.QuestionCount,
+			&participant.Answered, &submitted, &participant.Score, &participant.EndsAt,
+			&participant.LastActivityAt, &participant.JoinedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan group session participant: %v", err)
+		}
+		participant.Submitted = submitted != nil && *submitted
+		participants = append(participants, participant)
+	}
+	return participants, nil
+}
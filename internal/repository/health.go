@@ -0,0 +1,44 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+func (r *Repository) Ping(ctx context.Context) error {
+	if err := r.db.Pool.Ping(ctx); err != nil {
+		return fmt.Errorf("failed to ping database: %v", err)
+	}
+	return nil
+}
+
+func (r *Repository) GetSchemaVersion(ctx context.Context) (int, error) {
+	return r.db.CurrentSchemaVersion(ctx)
+}
+
+// CountOpenAttempts counts unsubmitted attempts started within the window,
+// by attempt type.
+func (r *Repository) CountOpenAttempts(ctx context.Context, window time.Duration) (map[string]int, error) {
+	rows, err := r.db.Pool.Query(ctx,
+		`SELECT e.name, a.max_score, COUNT(*)
+		 FROM attempts a
+		 JOIN exams e ON e.id = a.exam_id
+		 WHERE a.ended_at IS NULL AND a.started_at >= NOW() - make_interval(secs => $1)
+		 GROUP BY e.name, a.max_score`, window.Seconds())
+	if err != nil {
+		return nil, fmt.Errorf("failed to count open attempts: %v", err)
+	}
+	defer rows.Close()
+
+	counts := make(map[string]int)
+	for rows.Next() {
+		var examName string
+		var maxScore, count int
+		if err := rows.Scan(&examName, &maxScore, &count); err != nil {
+			return nil, fmt.Errorf("failed to scan open attempt count: %v", err)
+		}
+		counts[AttemptType(examName, maxScore)] += count
+	}
+	return counts, nil
+}
@@ -0,0 +1,60 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"capm-exam-system/internal/models"
+)
+
+// GetItemResponses returns one row per question served in a submitted
+// attempt. Attempts from before question sets were stored only contribute
+// the questions they answered.
+func (r *Repository) GetItemResponses(ctx context.Context) ([]models.ItemResponse, error) {
+	query := `
+		WITH submitted AS (
+			SELECT id, score::float / NULLIF(max_score, 0) AS pct
+			FROM attempts
+			WHERE ended_at IS NOT NULL AND score IS NOT NULL
+		),
+		served AS (
+			SELECT aq.attempt_id, aq.question_id
+			FROM attempt_questions aq
+			JOIN submitted s ON s.id = aq.attempt_id
+			UNION
+			SELECT aa.attempt_id, aa.question_id
+			FROM attempt_answers aa
+			JOIN submitted s ON s.id = aa.attempt_id
+		),
+		answers AS (
+			SELECT attempt_id, question_id,
+			       ARRAY_AGG(choice_id ORDER BY choice_id) FILTER (WHERE choice_id IS NOT NULL) AS choice_ids,
+			       BOOL_OR(COALESCE(is_correct, FALSE)) AS is_correct,
+			       MAX(dwell_ms) AS dwell_ms
+			FROM attempt_answers
+			GROUP BY attempt_id, question_id
+		)
+		SELECT sv.attempt_id, sv.question_id, COALESCE(s.pct, 0),
+		       COALESCE(a.choice_ids, '{}'), COALESCE(a.is_correct, FALSE), a.dwell_ms
+		FROM served sv
+		JOIN submitted s ON s.id = sv.attempt_id
+		LEFT JOIN answers a ON a.attempt_id = sv.attempt_id AND a.question_id = sv.question_id
+		ORDER BY sv.question_id`
+
+	rows, err := r.db.Pool.Query(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get item responses: %v", err)
+	}
+	defer rows.Close()
+
+	var responses []models.ItemResponse
+	for rows.Next() {
+		var resp models.ItemResponse
+		if err := rows.Scan(&resp.AttemptID, &resp.QuestionID, &resp.AttemptScore, &resp.ChoiceIDs, &resp.IsCorrect, &resp.DwellMs); err != nil {
+			return nil, fmt.Errorf("failed to scan item response: %v", err)
+		}
+		responses = append(responses, resp)
+	}
+
+	return responses, nil
+}
@@ -0,0 +1,100 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+)
+
+// GetLegacyQuestionsByDomain replays the seeded draw that picked the
+// questions of attempts started before question sets were stored: count
+// questions of the domain, by popularity weight, skipping excludeIDs. It is
+// only used to rebuild those attempts' sets and must not change.
+func (r *Repository) GetLegacyQuestionsByDomain(ctx context.Context, count int, seed int64, domain string, excludeIDs []int) ([]int, error) {
+	query := `
+		SELECT id, popularity_score
+		FROM questions
+		WHERE domain = $1`
+
+	args := []interface{}{domain}
+
+	if len(excludeIDs) > 0 {
+		query += ` AND NOT (id = ANY($2))`
+		exclusion := make([]int32, len(excludeIDs))
+		for i, id := range excludeIDs {
+			exclusion[i] = int32(id)
+		}
+		args = append(args, exclusion)
+	}
+
+	query += `
+		ORDER BY popularity_score DESC`
+
+	rows, err := r.db.Pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get questions for domain %s: %v", domain, err)
+	}
+	defer rows.Close()
+
+	type weightedQuestion struct {
+		ID     int
+		Weight float64
+	}
+
+	var questions []weightedQuestion
+	for rows.Next() {
+		var wq weightedQuestion
+		if err := rows.Scan(&wq.ID, &wq.Weight); err != nil {
+			return nil, fmt.Errorf("failed to scan question: %v", err)
+		}
+		questions = append(questions, wq)
+	}
+
+	if len(questions) < count {
+		return nil, fmt.Errorf("not enough questions available in domain %s: have %d, need %d", domain, len(questions), count)
+	}
+
+	rng := rand.New(rand.NewSource(seed))
+	selected := make([]int, 0, count)
+	used := make(map[int]bool)
+
+	for len(selected) < count {
+		totalWeight := 0.0
+		for _, q := range questions {
+			if !used[q.ID] {
+				totalWeight += q.Weight
+			}
+		}
+
+		if totalWeight == 0 {
+			break
+		}
+
+		target := rng.Float64() * totalWeight
+		current := 0.0
+
+		for _, q := range questions {
+			if used[q.ID] {
+				continue
+			}
+			current += q.Weight
+			if current >= target {
+				selected = append(selected, q.ID)
+				used[q.ID] = true
+				break
+			}
+		}
+	}
+
+	for len(selected) < count {
+		for _, q := range questions {
+			if !used[q.ID] {
+				selected = append(selected, q.ID)
+				used[q.ID] = true
+				break
+			}
+		}
+	}
+
+	return selected, nil
+}
@@ -0,0 +1,114 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"capm-exam-system/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// PracticeFilter selects the questions a practice quiz may draw from.
+// Empty fields do not restrict.
+type PracticeFilter struct {
+	UserID       uuid.UUID
+	Domains      []string
+	QuestionType string // single, multi
+	Difficulty   string // easy, medium, hard
+	Source       string // unseen, missed
+
+	// A question is easy when at least EasyRate of its MinResponses or more
+	// responses were correct and hard below HardRate. Questions with fewer
+	// responses are hard in HardDomain and medium elsewhere.
+	MinResponses int
+	EasyRate     float64
+	HardRate     float64
+	HardDomain   string
+
+	// MissedSince limits "missed" to attempts started since then.
+	MissedSince time.Time
+}
+
+// ListPracticeDomains returns every domain with its question counts.
+func (r *Repository) ListPracticeDomains(ctx context.Context) ([]models.PracticeDomain, error) {
+	rows, err := r.db.Pool.Query(ctx,
+		`SELECT domain, COUNT(*), COUNT(*) FILTER (WHERE is_multi_select)
+		 FROM questions
+		 GROUP BY domain
+		 ORDER BY domain`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list practice domains: %v", err)
+	}
+	defer rows.Close()
+
+	var domains []models.PracticeDomain
+	for rows.Next() {
+		var domain models.PracticeDomain
+		if err := rows.Scan(&domain.Domain, &domain.Questions, &domain.MultiSelectQuestions); err != nil {
+			return nil, fmt.Errorf("failed to scan practice domain: %v", err)
+		}
+		domains = append(domains, domain)
+	}
+	return domains, nil
+}
+
+// FindPracticeQuestionIDs returns the questions that match the filter,
+// grouped by domain. Difficulty comes from how often submitted attempts answered a question
+// correctly; "missed" means the user left it wrong or unanswered in a
+// submitted attempt.
+func (r *Repository) FindPracticeQuestionIDs(ctx context.Context, filter PracticeFilter) (map[string][]int, error) {
+	rows, err := r.db.Pool.Query(ctx,
+		`WITH responses AS (
+			SELECT aq.question_id, a.user_id, a.started_at,
+			       EXISTS (
+			           SELECT 1 FROM attempt_answers aa
+			           WHERE aa.attempt_id = aq.attempt_id AND aa.question_id = aq.question_id AND aa.is_correct
+			       ) AS correct
+			FROM attempt_questions aq
+			JOIN attempts a ON a.id = aq.attempt_id
+			WHERE a.ended_at IS NOT NULL
+		),
+		stats AS (
+			SELECT question_id, COUNT(*) AS served, COUNT(*) FILTER (WHERE correct) AS correct
+			FROM responses
+			GROUP BY question_id
+		)
+		SELECT q.id, q.domain
+		FROM questions q
+		LEFT JOIN stats s ON s.question_id = q.id
+		WHERE q.domain = ANY($1)
+		  AND ($2 = '' OR q.is_multi_select = ($2 = 'multi'))
+		  AND ($3 = '' OR $3 = CASE
+		      WHEN COALESCE(s.served, 0) < $4 THEN CASE WHEN q.domain = $7 THEN 'hard' ELSE 'medium' END
+		      WHEN s.correct::float / s.served >= $5 THEN 'easy'
+		      WHEN s.correct::float / s.served < $6 THEN 'hard'
+		      ELSE 'medium' END)
+		  AND ($8 = '' OR ($8 = 'unseen' AND NOT EXISTS (
+		          SELECT 1 FROM attempt_questions aq
+		          JOIN attempts a ON a.id = aq.attempt_id
+		          WHERE a.user_id = $9 AND aq.question_id = q.id
+		      )) OR ($8 = 'missed' AND EXISTS (
+		          SELECT 1 FROM responses rs
+		          WHERE rs.user_id = $9 AND rs.question_id = q.id AND NOT rs.correct AND rs.started_at >= $10
+		      )))
+		ORDER BY q.id`,
+		filter.Domains, filter.QuestionType, filter.Difficulty, filter.MinResponses, filter.EasyRate, filter.HardRate,
+		filter.HardDomain, filter.Source, filter.UserID, filter.MissedSince)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find practice questions: %v", err)
+	}
+	defer rows.Close()
+
+	byDomain := make(map[string][]int)
+	for rows.Next() {
+		var id int
+		var domain string
+		if err := rows.Scan(&id, &domain); err != nil {
+			return nil, fmt.Errorf("failed to scan practice question: %v", err)
+		}
+		byDomain[domain] = append(byDomain[domain], id)
+	}
+	return byDomain, nil
+}
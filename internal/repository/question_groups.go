@@ -0,0 +1,110 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"capm-exam-system/internal/models"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// SetQuestionFamily moves a question into a family; an empty family makes it
+// a family of its own. It reports false when the question does not exist.
+func (r *Repository) SetQuestionFamily(ctx context.Context, questionID int, family string) (bool, error) {
+	tag, err := r.db.Pool.Exec(ctx,
+		`UPDATE questions SET family = NULLIF($2, '') WHERE id = $1`, questionID, family)
+	if err != nil {
+		return false, fmt.Errorf("failed to set question family: %v", err)
+	}
+	r.invalidateBank()
+	return tag.RowsAffected() > 0, nil
+}
+
+const enemyPairColumns = "question_id, enemy_id, reason, created_at"
+
+func scanEnemyPair(row pgx.Row, pair *models.EnemyPair) error {
+	return row.Scan(&pair.QuestionID, &pair.EnemyID, &pair.Reason, &pair.CreatedAt)
+}
+
+// CreateEnemyPair records that two questions must not share an attempt. The
+// pair is stored lower ID first; recording it again updates the reason.
+func (r *Repository) CreateEnemyPair(ctx context.Context, questionID, enemyID int, reason string) (*models.EnemyPair, error) {
+	if questionID > enemyID {
+		questionID, enemyID = enemyID, questionID
+	}
+
+	var pair models.EnemyPair
+	err := scanEnemyPair(r.db.Pool.QueryRow(ctx,
+		`INSERT INTO question_enemies (question_id, enemy_id, reason)
+		 VALUES ($1, $2, $3)
+		 ON CONFLICT (question_id, enemy_id) DO UPDATE SET reason = EXCLUDED.reason
+		 RETURNING `+enemyPairColumns,
+		questionID, enemyID, reason), &pair)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create enemy pair: %v", err)
+	}
+	r.invalidateBank()
+	return &pair, nil
+}
+
+// DeleteEnemyPair removes a pair in either order. It reports false when the
+// pair did not exist.
+func (r *Repository) DeleteEnemyPair(ctx context.Context, questionID, enemyID int) (bool, error) {
+	if questionID > enemyID {
+		questionID, enemyID = enemyID, questionID
+	}
+
+	tag, err := r.db.Pool.Exec(ctx,
+		`DELETE FROM question_enemies WHERE question_id = $1 AND enemy_id = $2`, questionID, enemyID)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete enemy pair: %v", err)
+	}
+	r.invalidateBank()
+	return tag.RowsAffected() > 0, nil
+}
+
+// ListEnemyPairs returns every pair, or the pairs involving questionID when
+// it is non-zero.
+func (r *Repository) ListEnemyPairs(ctx context.Context, questionID int) ([]models.EnemyPair, error) {
+	rows, err := r.db.Pool.Query(ctx,
+		`SELECT `+enemyPairColumns+`
+		 FROM question_enemies
+		 WHERE $1 = 0 OR question_id = $1 OR enemy_id = $1
+		 ORDER BY question_id, enemy_id`, questionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list enemy pairs: %v", err)
+	}
+	defer rows.Close()
+
+	var pairs []models.EnemyPair
+	for rows.Next() {
+		var pair models.EnemyPair
+		if err := scanEnemyPair(rows, &pair); err != nil {
+			return nil, fmt.Errorf("failed to scan enemy pair: %v", err)
+		}
+		pairs = append(pairs, pair)
+	}
+	return pairs, nil
+}
+
+// getQuestionEnemies maps each question with enemies to all of them, in both
+// directions.
+func (r *Repository) getQuestionEnemies(ctx context.Context) (map[int][]int, error) {
+	rows, err := r.db.Pool.Query(ctx, `SELECT question_id, enemy_id FROM question_enemies`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get question enemies: %v", err)
+	}
+	defer rows.Close()
+
+	enemies := make(map[int][]int)
+	for rows.Next() {
+		var questionID, enemyID int
+		if err := rows.Scan(&questionID, &enemyID); err != nil {
+			return nil, fmt.Errorf("failed to scan question enemies: %v", err)
+		}
+		enemies[questionID] = append(enemies[questionID], enemyID)
+		enemies[enemyID] = append(enemies[enemyID], questionID)
+	}
+	return enemies, nil
+}
@@ -0,0 +1,149 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"capm-exam-system/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+const questionReportColumns = `id, question_id, attempt_id, user_id, category, message, status, resolution,
+	corrected_choice_ids, regrade_job_id, created_at, updated_at, resolved_at`
+
+func scanQuestionReport(row pgx.Row, report *models.QuestionReport) error {
+	return row.Scan(&report.ID, &report.QuestionID, &report.AttemptID, &report.UserID, &report.Category,
+		&report.Message, &report.Status, &report.Resolution, &report.CorrectedChoiceIDs,
+		&report.RegradeJobID, &report.CreatedAt, &report.UpdatedAt, &report.ResolvedAt)
+}
+
+func (r *Repository) CreateQuestionReport(ctx context.Context, questionID int, attemptID *uuid.UUID, userID uuid.UUID, category, message string) (*models.QuestionReport, error) {
+	var report models.QuestionReport
+	err := scanQuestionReport(r.db.Pool.QueryRow(ctx,
+		`INSERT INTO question_reports (question_id, attempt_id, user_id, category, message)
+		 VALUES ($1, $2, $3, $4, $5)
+		 RETURNING `+questionReportColumns,
+		questionID, attemptID, userID, category, message), &report)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create question report: %v", err)
+	}
+	return &report, nil
+}
+
+func (r *Repository) GetQuestionReport(ctx context.Context, reportID uuid.UUID) (*models.QuestionReport, error) {
+	var report models.QuestionReport
+	err := scanQuestionReport(r.db.Pool.QueryRow(ctx,
+		`SELECT `+questionReportColumns+` FROM question_reports WHERE id = $1`, reportID), &report)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get question report: %v", err)
+	}
+	return &report, nil
+}
+
+// ListQuestionReports returns reports oldest first so the triage queue is
+// worked in arrival order. Empty filters match everything.
+func (r *Repository) ListQuestionReports(ctx context.Context, status, category string, questionID int) ([]models.QuestionReport, error) {
+	conditions := make([]string, 0, 3)
+	args := make([]interface{}, 0, 3)
+	if status != "" {
+		args = append(args, status)
+		conditions = append(conditions, fmt.Sprintf("status = $%d", len(args)))
+	}
+	if category != "" {
+		args = append(args, category)
+		conditions = append(conditions, fmt.Sprintf("category = $%d", len(args)))
+	}
+	if questionID > 0 {
+		args = append(args, questionID)
+		conditions = append(conditions, fmt.Sprintf("question_id = $%d", len(args)))
+	}
+
+	query := `SELECT ` + questionReportColumns + ` FROM question_reports`
+	if len(conditions) > 0 {
+		query += ` WHERE ` + strings.Join(conditions, " AND ")
+	}
+	query += ` ORDER BY created_at`
+
+	rows, err := r.db.Pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list question reports: %v", err)
+	}
+	defer rows.Close()
+
+	var reports []models.QuestionReport
+	for rows.Next() {
+		var report models.QuestionReport
+		if err := scanQuestionReport(rows, &report); err != nil {
+			return nil, fmt.Errorf("failed to scan question report: %v", err)
+		}
+		reports = append(reports, report)
+	}
+	return reports, nil
+}
+
+func (r *Repository) UpdateQuestionReport(ctx context.Context, report *models.QuestionReport) error {
+	err := r.db.Pool.QueryRow(ctx,
+		`UPDATE question_reports
+		 SET status = $2, resolution = $3, corrected_choice_ids = $4, regrade_job_id = $5,
+		     resolved_at = $6, updated_at = NOW()
+		 WHERE id = $1
+		 RETURNING updated_at`,
+		report.ID, report.Status, report.Resolution, report.CorrectedChoiceIDs, report.RegradeJobID,
+		report.ResolvedAt).Scan(&report.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to update question report: %v", err)
+	}
+	return nil
+}
+
+func (r *Repository) CreateQuestionReportComment(ctx context.Context, reportID uuid.UUID, author, body string) (*models.QuestionReportComment, error) {
+	var comment models.QuestionReportComment
+	err := r.db.Pool.QueryRow(ctx,
+		`INSERT INTO question_report_comments (report_id, author, body)
+		 VALUES ($1, $2, $3)
+		 RETURNING id, report_id, author, body, created_at`,
+		reportID, author, body).Scan(&comment.ID, &comment.ReportID, &comment.Author, &comment.Body, &comment.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create report comment: %v", err)
+	}
+	return &comment, nil
+}
+
+func (r *Repository) GetQuestionReportComments(ctx context.Context, reportID uuid.UUID) ([]models.QuestionReportComment, error) {
+	rows, err := r.db.Pool.Query(ctx,
+		`SELECT id, report_id, author, body, created_at
+		 FROM question_report_comments
+		 WHERE report_id = $1
+		 ORDER BY created_at, id`, reportID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get report comments: %v", err)
+	}
+	defer rows.Close()
+
+	var comments []models.QuestionReportComment
+	for rows.Next() {
+		var comment models.QuestionReportComment
+		if err := rows.Scan(&comment.ID, &comment.ReportID, &comment.Author, &comment.Body, &comment.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan report comment: %v", err)
+		}
+		comments = append(comments, comment)
+	}
+	return comments, nil
+}
+
+// SetCorrectChoices replaces the answer key of a question.
+func (r *Repository) SetCorrectChoices(ctx context.Context, questionID int, choiceIDs []int) error {
+	_, err := r.db.Pool.Exec(ctx,
+		`UPDATE choices SET is_correct = (id = ANY($2)) WHERE question_id = $1`,
+		questionID, choiceIDs)
+	if err != nil {
+		return fmt.Errorf("failed to update answer key: %v", err)
+	}
+	return nil
+}
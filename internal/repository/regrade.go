@@ -0,0 +1,320 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"capm-exam-system/internal/models"
+	"capm-exam-system/internal/tracing"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+const regradeJobColumns = `id, question_id, reason, status, attempts_checked, attempts_changed, error,
+	created_at, started_at, finished_at`
+
+func scanRegradeJob(row pgx.Row, job *models.RegradeJob) error {
+	return row.Scan(&job.ID, &job.QuestionID, &job.Reason, &job.Status, &job.AttemptsChecked,
+		&job.AttemptsChanged, &job.Error, &job.CreatedAt, &job.StartedAt, &job.FinishedAt)
+}
+
+func (r *Repository) CreateRegradeJob(ctx context.Context, questionID *int, reason string) (*models.RegradeJob, error) {
+	var job models.RegradeJob
+	err := scanRegradeJob(r.db.Pool.QueryRow(ctx,
+		`INSERT INTO regrade_jobs (question_id, reason)
+		 VALUES ($1, $2)
+		 RETURNING `+regradeJobColumns,
+		questionID, reason), &job)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create regrade job: %v", err)
+	}
+	return &job, nil
+}
+
+func (r *Repository) GetRegradeJob(ctx context.Context, jobID uuid.UUID) (*models.RegradeJob, error) {
+	var job models.RegradeJob
+	err := scanRegradeJob(r.db.Pool.QueryRow(ctx,
+		`SELECT `+regradeJobColumns+` FROM regrade_jobs WHERE id = $1`, jobID), &job)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get regrade job: %v", err)
+	}
+	return &job, nil
+}
+
+func (r *Repository) ListRegradeJobs(ctx context.Context, limit int) ([]models.RegradeJob, error) {
+	rows, err := r.db.Pool.Query(ctx,
+		`SELECT `+regradeJobColumns+` FROM regrade_jobs ORDER BY created_at DESC LIMIT $1`, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list regrade jobs: %v", err)
+	}
+	defer rows.Close()
+
+	var jobs []models.RegradeJob
+	for rows.Next() {
+		var job models.RegradeJob
+		if err := scanRegradeJob(rows, &job); err != nil {
+			return nil, fmt.Errorf("failed to scan regrade job: %v", err)
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, nil
+}
+
+// ClaimRegradeJob marks the oldest queued job as running and returns it, or
+// nil when the queue is empty. Concurrent workers never claim the same job.
+func (r *Repository) ClaimRegradeJob(ctx context.Context) (*models.RegradeJob, error) {
+	var job models.RegradeJob
+	err := scanRegradeJob(r.db.Pool.QueryRow(ctx,
+		`UPDATE regrade_jobs
+		 SET status = 'running', started_at = NOW()
+		 WHERE id = (
+			SELECT id FROM regrade_jobs
+			WHERE status = 'queued'
+			ORDER BY created_at
+			FOR UPDATE SKIP LOCKED
+			LIMIT 1
+		 )
+		 RETURNING `+regradeJobColumns), &job)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to claim regrade job: %v", err)
+	}
+	return &job, nil
+}
+
+// RequeueStaleRegradeJobs puts jobs that have been running for longer than
+// maxAge back in the queue, e.g. after the worker process died mid-job.
+func (r *Repository) RequeueStaleRegradeJobs(ctx context.Context, maxAge time.Duration) error {
+	_, err := r.db.Pool.Exec(ctx,
+		`UPDATE regrade_jobs
+		 SET status = 'queued', started_at = NULL
+		 WHERE status = 'running' AND started_at < NOW() - make_interval(secs => $1)`,
+		maxAge.Seconds())
+	if err != nil {
+		return fmt.Errorf("failed to requeue stale regrade jobs: %v", err)
+	}
+	return nil
+}
+
+// ReleaseRegradeJob puts a running job back in the queue, for a worker that
+// stops before finishing it.
+func (r *Repository) ReleaseRegradeJob(ctx context.Context, jobID uuid.UUID) error {
+	_, err := r.db.Pool.Exec(ctx,
+		`UPDATE regrade_jobs SET status = 'queued', started_at = NULL WHERE id = $1 AND status = 'running'`,
+		jobID)
+	if err != nil {
+		return fmt.Errorf("failed to release regrade job: %v", err)
+	}
+	return nil
+}
+
+func (r *Repository) FinishRegradeJob(ctx context.Context, job *models.RegradeJob) error {
+	_, err := r.db.Pool.Exec(ctx,
+		`UPDATE regrade_jobs
+		 SET status = $2, attempts_checked = $3, attempts_changed = $4, error = $5, finished_at = NOW()
+		 WHERE id = $1`,
+		job.ID, job.Status, job.AttemptsChecked, job.AttemptsChanged, job.Error)
+	if err != nil {
+		return fmt.Errorf("failed to finish regrade job: %v", err)
+	}
+	return nil
+}
+
+// GetAnsweredQuestionIDs returns every question with a stored answer.
+func (r *Repository) GetAnsweredQuestionIDs(ctx context.Context) ([]int, error) {
+	rows, err := r.db.Pool.Query(ctx,
+		`SELECT DISTINCT question_id FROM attempt_answers ORDER BY question_id`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get answered questions: %v", err)
+	}
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan answered question: %v", err)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// GetAnswersByQuestion returns the stored answers to a question from every
+// attempt. Open sectioned and tutor attempts store graded answers before they
+// are submitted, and sectioned attempts are scored from them.
+func (r *Repository) GetAnswersByQuestion(ctx context.Context, questionID int) ([]models.AttemptAnswer, error) {
+	rows, err := r.db.Pool.Query(ctx,
+		`SELECT id, attempt_id, question_id, choice_id, is_correct, dwell_ms, answer_changes
+		 FROM attempt_answers
+		 WHERE question_id = $1
+		 ORDER BY attempt_id`, questionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get answers for question: %v", err)
+	}
+	defer rows.Close()
+
+	var answers []models.AttemptAnswer
+	for rows.Next() {
+		var answer models.AttemptAnswer
+		if err := rows.Scan(&answer.ID, &answer.AttemptID, &answer.QuestionID, &answer.ChoiceID, &answer.IsCorrect, &answer.DwellMs, &answer.AnswerChanges); err != nil {
+			return nil, fmt.Errorf("failed to scan answer for question: %v", err)
+		}
+		answers = append(answers, answer)
+	}
+	return answers, nil
+}
+
+// RegradeAttempt applies the corrections to an attempt's answers and rescores
+// it in one transaction, holding the attempt's row lock throughout. The score
+// is recounted from every stored answer and compared with the stored score,
+// so corrections written by an earlier, interrupted run are still picked up.
+// When the score moved it records the adjustment, notifies the learner and
+// returns the adjustment; otherwise it returns nil. An open attempt only takes
+// the corrections and is scored from them when it closes.
+func (r *Repository) RegradeAttempt(ctx context.Context, regrade models.AttemptRegrade) (*models.ScoreAdjustment, error) {
+	ctx, span := tracing.Start(ctx, "repository.RegradeAttempt", tracing.AttemptID(regrade.AttemptID),
+		attribute.Int("capm.correction.count", len(regrade.Corrections)))
+	defer span.End()
+
+	attemptID, jobID := regrade.AttemptID, regrade.JobID
+	tx, err := r.db.Pool.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin regrade: %v", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var userID uuid.UUID
+	var startedAt time.Time
+	var score *int
+	err = tx.QueryRow(ctx,
+		`SELECT user_id, started_at, score FROM attempts WHERE id = $1 FOR UPDATE`,
+		attemptID).Scan(&userID, &startedAt, &score)
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock attempt: %v", err)
+	}
+
+	if len(regrade.Corrections) > 0 {
+		answerIDs := make([]uuid.UUID, 0, len(regrade.Corrections))
+		isCorrect := make([]bool, 0, len(regrade.Corrections))
+		for _, correction := range regrade.Corrections {
+			answerIDs = append(answerIDs, correction.AnswerID)
+			isCorrect = append(isCorrect, correction.IsCorrect)
+		}
+		_, err := tx.Exec(ctx,
+			`UPDATE attempt_answers aa
+			 SET is_correct = c.is_correct
+			 FROM unnest($2::uuid[], $3::boolean[]) AS c(id, is_correct)
+			 WHERE aa.id = c.id AND aa.attempt_id = $1`,
+			attemptID, answerIDs, isCorrect)
+		if err != nil {
+			return nil, fmt.Errorf("failed to correct attempt answers: %v", err)
+		}
+	}
+
+	var adjustment *models.ScoreAdjustment
+	if score != nil {
+		var newScore int
+		err := tx.QueryRow(ctx,
+			`UPDATE attempts
+			 SET score = (
+				SELECT COUNT(DISTINCT question_id)
+				FROM attempt_answers
+				WHERE attempt_id = $1 AND is_correct
+			 )
+			 WHERE id = $1
+			 RETURNING score`, attemptID).Scan(&newScore)
+		if err != nil {
+			return nil, fmt.Errorf("failed to recalculate attempt score: %v", err)
+		}
+
+		if newScore != *score {
+			adjustment = &models.ScoreAdjustment{JobID: &jobID, AttemptID: attemptID, UserID: userID, OldScore: *score, NewScore: newScore}
+			err := tx.QueryRow(ctx,
+				`INSERT INTO score_adjustments (job_id, attempt_id, user_id, old_score, new_score)
+				 VALUES ($1, $2, $3, $4, $5)
+				 RETURNING id, created_at`,
+				jobID, attemptID, userID, *score, newScore).Scan(&adjustment.ID, &adjustment.CreatedAt)
+			if err != nil {
+				return nil, fmt.Errorf("failed to record score adjustment: %v", err)
+			}
+
+			_, err = tx.Exec(ctx,
+				`INSERT INTO user_notifications (user_id, attempt_id, kind, message)
+				 VALUES ($1, $2, $3, $4)`,
+				userID, attemptID, regrade.NotificationKind, regrade.Message(startedAt, *score, newScore))
+			if err != nil {
+				return nil, fmt.Errorf("failed to create notification: %v", err)
+			}
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit regrade: %v", err)
+	}
+	return adjustment, nil
+}
+
+func (r *Repository) GetScoreAdjustments(ctx context.Context, attemptID uuid.UUID) ([]models.ScoreAdjustment, error) {
+	rows, err := r.db.Pool.Query(ctx,
+		`SELECT id, job_id, attempt_id, user_id, old_score, new_score, created_at
+		 FROM score_adjustments
+		 WHERE attempt_id = $1
+		 ORDER BY created_at, id`, attemptID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get score adjustments: %v", err)
+	}
+	defer rows.Close()
+
+	var adjustments []models.ScoreAdjustment
+	for rows.Next() {
+		var adj models.ScoreAdjustment
+		if err := rows.Scan(&adj.ID, &adj.JobID, &adj.AttemptID, &adj.UserID, &adj.OldScore, &adj.NewScore, &adj.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan score adjustment: %v", err)
+		}
+		adjustments = append(adjustments, adj)
+	}
+	return adjustments, nil
+}
+
+func (r *Repository) GetUserNotifications(ctx context.Context, userID uuid.UUID, unreadOnly bool) ([]models.UserNotification, error) {
+	rows, err := r.db.Pool.Query(ctx,
+		`SELECT id, user_id, attempt_id, kind, message, read_at, created_at
+		 FROM user_notifications
+		 WHERE user_id = $1 AND ($2 = FALSE OR read_at IS NULL)
+		 ORDER BY created_at DESC, id DESC`, userID, unreadOnly)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get notifications: %v", err)
+	}
+	defer rows.Close()
+
+	var notifications []models.UserNotification
+	for rows.Next() {
+		var n models.UserNotification
+		if err := rows.Scan(&n.ID, &n.UserID, &n.AttemptID, &n.Kind, &n.Message, &n.ReadAt, &n.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan notification: %v", err)
+		}
+		notifications = append(notifications, n)
+	}
+	return notifications, nil
+}
+
+func (r *Repository) MarkUserNotificationsRead(ctx context.Context, userID uuid.UUID) error {
+	_, err := r.db.Pool.Exec(ctx,
+		`UPDATE user_notifications SET read_at = NOW() WHERE user_id = $1 AND read_at IS NULL`, userID)
+	if err != nil {
+		return fmt.Errorf("failed to mark notifications read: %v", err)
+	}
+	return nil
+}
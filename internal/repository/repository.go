@@ -3,23 +3,43 @@ package repository
 import (
 	"context"
 	"fmt"
-	"math/rand"
 
 	"capm-exam-system/internal/database"
 	"capm-exam-system/internal/models"
+	"capm-exam-system/internal/tracing"
 
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgtype"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 const (
 	pmpExamName  = "PMP Mock Exam"
 	hardExamName = "Hard Question Drill"
+	// practiceExamName holds learner-built quizzes of any length
+	practiceExamName = "Custom Practice Quiz"
+	// Review sessions replay questions the user missed or bookmarked
+	retakeExamName    = "Missed Question Retake"
+	bookmarksExamName = "Bookmarked Questions"
+	missedExamName    = "Recent Misses Review"
+
+	// fullExamLength is the length of the full CAPM and PMP mocks; other
+	// lengths are custom mocks.
+	fullExamLength = 150
 )
 
 type Repository struct {
-	db *database.DB
+	db   *database.DB
+	bank bankCache
+}
+
+// querier is implemented by both the pool and a transaction, so statements
+// shared by transactional and standalone writes are written once.
+type querier interface {
+	Exec(ctx context.Context, sql string, arguments ...interface{}) (pgconn.CommandTag, error)
+	CopyFrom(ctx context.Context, tableName pgx.Identifier, columnNames []string, rowSrc pgx.CopyFromSource) (int64, error)
 }
 
 func New(db *database.DB) *Repository {
@@ -38,6 +58,22 @@ func (r *Repository) CreateUser(ctx context.Context, email, name string) (*model
 	return &user, nil
 }
 
+// GetUserByID returns the user, or nil when there is none.
+func (r *Repository) GetUserByID(ctx context.Context, id uuid.UUID) (*models.User, error) {
+	var user models.User
+	err := r.db.Pool.QueryRow(ctx,
+		"SELECT id, email, name FROM users WHERE id = $1",
+		id).Scan(&user.ID, &user.Email, &user.Name)
+
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %v", err)
+	}
+	return &user, nil
+}
+
 func (r *Repository) GetUserByEmail(ctx context.Context, email string) (*models.User, error) {
 	var user models.User
 	err := r.db.Pool.QueryRow(ctx,
@@ -53,25 +89,28 @@ func (r *Repository) GetUserByEmail(ctx context.Context, email string) (*models.
 	return &user, nil
 }
 
-func (r *Repository) CreateQuestion(ctx context.Context, prompt, domain, explanation string, popularityScore float64, isMultiSelect bool) (*models.Question, error) {
+// CreateQuestion stores a question. An empty family leaves the question in a
+// family of its own.
+func (r *Repository) CreateQuestion(ctx context.Context, prompt, domain, explanation string, popularityScore float64, isMultiSelect, shuffleChoices bool, family string) (*models.Question, error) {
 	var question models.Question
 	err := r.db.Pool.QueryRow(ctx,
-		"INSERT INTO questions (prompt, domain, explanation, popularity_score, is_multi_select) VALUES ($1, $2, $3, $4, $5) RETURNING id, prompt, domain, explanation, popularity_score, is_multi_select",
-		prompt, domain, explanation, popularityScore, isMultiSelect).Scan(
-		&question.ID, &question.Prompt, &question.Domain, &question.Explanation, &question.PopularityScore, &question.IsMultiSelect)
+		"INSERT INTO questions (prompt, domain, explanation, popularity_score, is_multi_select, shuffle_choices, family) VALUES ($1, $2, $3, $4, $5, $6, NULLIF($7, '')) RETURNING id, prompt, domain, explanation, popularity_score, is_multi_select, shuffle_choices, COALESCE(family, '')",
+		prompt, domain, explanation, popularityScore, isMultiSelect, shuffleChoices, family).Scan(
+		&question.ID, &question.Prompt, &question.Domain, &question.Explanation, &question.PopularityScore, &question.IsMultiSelect, &question.ShuffleChoices, &question.Family)
 
 	if err != nil {
 		return nil, fmt.Errorf("failed to create question: %v", err)
 	}
+	r.invalidateBank()
 	return &question, nil
 }
 
-func (r *Repository) CreateChoice(ctx context.Context, questionID int, text, label string, isCorrect bool) (*models.Choice, error) {
+func (r *Repository) CreateChoice(ctx context.Context, questionID int, text, label string, isCorrect bool, rationale string) (*models.Choice, error) {
 	var choice models.Choice
 	err := r.db.Pool.QueryRow(ctx,
-		"INSERT INTO choices (question_id, text, label, is_correct) VALUES ($1, $2, $3, $4) RETURNING id, question_id, text, label, is_correct",
-		questionID, text, label, isCorrect).Scan(
-		&choice.ID, &choice.QuestionID, &choice.Text, &choice.Label, &choice.IsCorrect)
+		"INSERT INTO choices (question_id, text, label, is_correct, rationale) VALUES ($1, $2, $3, $4, $5) RETURNING id, question_id, text, label, is_correct, rationale",
+		questionID, text, label, isCorrect, rationale).Scan(
+		&choice.ID, &choice.QuestionID, &choice.Text, &choice.Label, &choice.IsCorrect, &choice.Rationale)
 
 	if err != nil {
 		return nil, fmt.Errorf("failed to create choice: %v", err)
@@ -80,6 +119,9 @@ func (r *Repository) CreateChoice(ctx context.Context, questionID int, text, lab
 }
 
 func (r *Repository) GetQuestionsWithChoices(ctx context.Context, questionIDs []int) ([]models.QuestionWithChoices, error) {
+	ctx, span := tracing.Start(ctx, "repository.GetQuestionsWithChoices", attribute.Int("capm.question.count", len(questionIDs)))
+	defer span.End()
+
 	if len(questionIDs) == 0 {
 		return []models.QuestionWithChoices{}, nil
 	}
@@ -91,8 +133,8 @@ func (r *Repository) GetQuestionsWithChoices(ctx context.Context, questionIDs []
 	}
 
 	query := `
-		SELECT q.id, q.prompt, q.domain, q.popularity_score, q.explanation, q.is_multi_select,
-		       c.id, c.text, c.label, c.is_correct
+		SELECT q.id, q.prompt, q.domain, q.popularity_score, q.explanation, q.is_multi_select, q.shuffle_choices, COALESCE(q.family, ''),
+		       c.id, c.text, c.label, c.is_correct, c.rationale
 		FROM questions q
 		JOIN choices c ON q.id = c.question_id
 		WHERE q.id = ANY($1)
@@ -111,8 +153,8 @@ func (r *Repository) GetQuestionsWithChoices(ctx context.Context, questionIDs []
 		var q models.Question
 		var c models.Choice
 
-		err := rows.Scan(&qID, &q.Prompt, &q.Domain, &q.PopularityScore, &q.Explanation, &q.IsMultiSelect,
-			&c.ID, &c.Text, &c.Label, &c.IsCorrect)
+		err := rows.Scan(&qID, &q.Prompt, &q.Domain, &q.PopularityScore, &q.Explanation, &q.IsMultiSelect, &q.ShuffleChoices, &q.Family,
+			&c.ID, &c.Text, &c.Label, &c.IsCorrect, &c.Rationale)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan question row: %v", err)
 		}
@@ -316,382 +358,581 @@ func (r *Repository) GetTeamMotivationDrillQuestions(ctx context.Context, count
 	return r.GetQuestionsWithChoices(ctx, ids)
 }
 
-func (r *Repository) GetRandomQuestions(ctx context.Context, count int, seed int64) ([]int, error) {
+func (r *Repository) GetAttemptsByUser(ctx context.Context, userID uuid.UUID) ([]models.AttemptHistory, error) {
 	query := `
-		SELECT id, popularity_score
-		FROM questions
-		ORDER BY popularity_score DESC`
+		SELECT a.id, a.exam_id, e.name, a.max_score, a.score, a.started_at, a.ended_at, a.feedback
+		FROM attempts a
+		JOIN exams e ON e.id = a.exam_id
+		WHERE a.user_id = $1
+		ORDER BY a.started_at DESC`
 
-	rows, err := r.db.Pool.Query(ctx, query)
+	rows, err := r.db.Pool.Query(ctx, query, userID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get questions: %v", err)
+		return nil, fmt.Errorf("failed to get attempts: %v", err)
 	}
 	defer rows.Close()
 
-	type weightedQuestion struct {
-		ID     int
-		Weight float64
-	}
-
-	var questions []weightedQuestion
+	var history []models.AttemptHistory
 	for rows.Next() {
-		var wq weightedQuestion
-		if err := rows.Scan(&wq.ID, &wq.Weight); err != nil {
-			return nil, fmt.Errorf("failed to scan question: %v", err)
+		var record models.AttemptHistory
+		var score pgtype.Int4
+
+		if err := rows.Scan(&record.AttemptID, &record.ExamID, &record.ExamName, &record.MaxScore, &score, &record.StartedAt, &record.EndedAt, &record.Feedback); err != nil {
+			return nil, fmt.Errorf("failed to scan attempt history: %v", err)
+		}
+
+		if score.Valid {
+			val := int(score.Int32)
+			record.Score = &val
 		}
-		questions = append(questions, wq)
-	}
 
-	if len(questions) < count {
-		return nil, fmt.Errorf("not enough questions available: have %d, need %d", len(questions), count)
+		record.QuestionCount = record.MaxScore
+		record.AttemptType = AttemptType(record.ExamName, record.MaxScore)
+
+		history = append(history, record)
 	}
 
-	// Use seed for reproducible randomness
-	rng := rand.New(rand.NewSource(seed))
+	return history, nil
+}
 
-	// Weighted random selection
-	selected := make([]int, 0, count)
-	used := make(map[int]bool)
+// AttemptType names the kind of attempt, as shown in history and used to
+// label metrics.
+func AttemptType(examName string, maxScore int) string {
+	switch {
+	case examName == pmpExamName && maxScore == fullExamLength:
+		return "PMP Mock Exam"
+	case examName == pmpExamName:
+		return "Custom PMP Mock"
+	case examName == hardExamName:
+		return "Hard Drill"
+	case examName == practiceExamName:
+		return "Custom Quiz"
+	case examName == retakeExamName:
+		return "Missed Retake"
+	case examName == bookmarksExamName:
+		return "Bookmarks"
+	case examName == missedExamName:
+		return "Missed Review"
+	case maxScore <= 20:
+		return "Short Quiz"
+	case maxScore != fullExamLength:
+		return "Custom Mock Exam"
+	default:
+		return "Mock Exam"
+	}
+}
 
-	for len(selected) < count {
-		totalWeight := 0.0
-		for _, q := range questions {
-			if !used[q.ID] {
-				totalWeight += q.Weight
-			}
-		}
+const attemptColumns = "id, exam_id, user_id, seed, score, max_score, started_at, ended_at, current_section, section_status, section_started_at, break_started_at, submit_idempotency_key, time_limit_seconds, feedback, challenge_id"
 
-		if totalWeight == 0 {
-			break
-		}
+func scanAttempt(row pgx.Row, attempt *models.Attempt) error {
+	return row.Scan(&attempt.ID, &attempt.ExamID, &attempt.UserID, &attempt.Seed, &attempt.Score, &attempt.MaxScore, &attempt.StartedAt, &attempt.EndedAt,
+		&attempt.CurrentSection, &attempt.SectionStatus, &attempt.SectionStartedAt, &attempt.BreakStartedAt, &attempt.SubmitKey,
+		&attempt.TimeLimitSeconds, &attempt.Feedback, &attempt.ChallengeID)
+}
 
-		target := rng.Float64() * totalWeight
-		current := 0.0
+// CreateAttempt starts an attempt. A nil timeLimitSeconds leaves it untimed;
+// challengeID is set for attempts started from a challenge link.
+func (r *Repository) CreateAttempt(ctx context.Context, userID uuid.UUID, examID uuid.UUID, seed int64, maxScore int, sectionStatus string, timeLimitSeconds *int, feedback string, challengeID *uuid.UUID) (*models.Attempt, error) {
+	ctx, span := tracing.Start(ctx, "repository.CreateAttempt")
+	defer span.End()
 
-		for _, q := range questions {
-			if used[q.ID] {
-				continue
-			}
-			current += q.Weight
-			if current >= target {
-				selected = append(selected, q.ID)
-				used[q.ID] = true
-				break
-			}
-		}
+	var attempt models.Attempt
+	err := scanAttempt(r.db.Pool.QueryRow(ctx,
+		"INSERT INTO attempts (user_id, exam_id, seed, max_score, section_status, time_limit_seconds, feedback, challenge_id) VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING "+attemptColumns,
+		userID, examID, seed, maxScore, sectionStatus, timeLimitSeconds, feedback, challengeID), &attempt)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to create attempt: %v", err)
 	}
+	return &attempt, nil
+}
 
-	// Fill remaining with random unused questions if needed
-	for len(selected) < count {
-		for _, q := range questions {
-			if !used[q.ID] {
-				selected = append(selected, q.ID)
-				used[q.ID] = true
-				break
-			}
-		}
+func (r *Repository) GetAttempt(ctx context.Context, attemptID uuid.UUID) (*models.Attempt, error) {
+	ctx, span := tracing.Start(ctx, "repository.GetAttempt", tracing.AttemptID(attemptID))
+	defer span.End()
+
+	var attempt models.Attempt
+	err := scanAttempt(r.db.Pool.QueryRow(ctx,
+		"SELECT "+attemptColumns+" FROM attempts WHERE id = $1",
+		attemptID), &attempt)
+
+	if err == pgx.ErrNoRows {
+		return nil, nil
 	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get attempt: %v", err)
+	}
+	return &attempt, nil
+}
+
+// UpdateAttemptSection stores the section state of attempt. The update is
+// conditional on the attempt still being open in fromSection with fromStatus,
+// so it reports false, having written nothing, when another request moved it
+// on first.
+func (r *Repository) UpdateAttemptSection(ctx context.Context, attempt *models.Attempt, fromSection int, fromStatus string) (bool, error) {
+	commandTag, err := r.db.Pool.Exec(ctx,
+		`UPDATE attempts SET current_section = $1, section_status = $2, section_started_at = $3, break_started_at = $4
+		 WHERE id = $5 AND current_section = $6 AND section_status = $7 AND ended_at IS NULL`,
+		attempt.CurrentSection, attempt.SectionStatus, attempt.SectionStartedAt, attempt.BreakStartedAt,
+		attempt.ID, fromSection, fromStatus)
 
-	return selected, nil
+	if err != nil {
+		return false, fmt.Errorf("failed to update attempt section: %v", err)
+	}
+	return commandTag.RowsAffected() > 0, nil
 }
 
-func (r *Repository) GetRandomQuestionsByDomain(ctx context.Context, count int, seed int64, domain string, excludeIDs []int) ([]int, error) {
-	query := `
-		SELECT id, popularity_score
-		FROM questions
-		WHERE domain = $1`
+// CreateAttemptQuestions stores the drawn question set of an attempt in
+// serving order.
+func (r *Repository) CreateAttemptQuestions(ctx context.Context, attemptID uuid.UUID, questionIDs []int) error {
+	ctx, span := tracing.Start(ctx, "repository.CreateAttemptQuestions", tracing.AttemptID(attemptID))
+	defer span.End()
 
-	args := []interface{}{domain}
+	rows := make([][]interface{}, 0, len(questionIDs))
+	for i, id := range questionIDs {
+		rows = append(rows, []interface{}{attemptID, i + 1, id})
+	}
 
-	if len(excludeIDs) > 0 {
-		query += ` AND NOT (id = ANY($2))`
-		exclusion := make([]int32, len(excludeIDs))
-		for i, id := range excludeIDs {
-			exclusion[i] = int32(id)
-		}
-		args = append(args, exclusion)
+	_, err := r.db.Pool.CopyFrom(ctx,
+		pgx.Identifier{"attempt_questions"},
+		[]string{"attempt_id", "position", "question_id"},
+		pgx.CopyFromRows(rows))
+	if err != nil {
+		return fmt.Errorf("failed to store attempt questions: %v", err)
 	}
+	return nil
+}
 
-	query += `
-		ORDER BY popularity_score DESC`
+// GetAttemptQuestionIDs returns the stored question set of an attempt in
+// serving order, or nil for attempts created before sets were stored.
+func (r *Repository) GetAttemptQuestionIDs(ctx context.Context, attemptID uuid.UUID) ([]int, error) {
+	ctx, span := tracing.Start(ctx, "repository.GetAttemptQuestionIDs", tracing.AttemptID(attemptID))
+	defer span.End()
 
-	rows, err := r.db.Pool.Query(ctx, query, args...)
+	rows, err := r.db.Pool.Query(ctx,
+		`SELECT question_id FROM attempt_questions WHERE attempt_id = $1 ORDER BY position`, attemptID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get questions for domain %s: %v", domain, err)
+		return nil, fmt.Errorf("failed to get attempt questions: %v", err)
 	}
 	defer rows.Close()
 
-	type weightedQuestion struct {
-		ID     int
-		Weight float64
-	}
-
-	var questions []weightedQuestion
+	var ids []int
 	for rows.Next() {
-		var wq weightedQuestion
-		if err := rows.Scan(&wq.ID, &wq.Weight); err != nil {
-			return nil, fmt.Errorf("failed to scan question: %v", err)
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan attempt question: %v", err)
 		}
-		questions = append(questions, wq)
+		ids = append(ids, id)
 	}
+	return ids, nil
+}
+
+// LockAttemptAnswer stores the graded choices of one question of an open
+// attempt, with its final selections, in one transaction. The attempt row is
+// locked first, so concurrent answers to the question and a concurrent submit
+// are serialised. It reports false, having written nothing, when the attempt
+// is closed or the question already has an answer.
+func (r *Repository) LockAttemptAnswer(ctx context.Context, attemptID uuid.UUID, questionID int, answers []models.GradedChoice, selections []models.AnswerSelection) (bool, error) {
+	ctx, span := tracing.Start(ctx, "repository.LockAttemptAnswer", tracing.AttemptID(attemptID), attribute.Int("capm.answer.count", len(answers)))
+	defer span.End()
 
-	if len(questions) < count {
-		return nil, fmt.Errorf("not enough questions available in domain %s: have %d, need %d", domain, len(questions), count)
+	tx, err := r.db.Pool.Begin(ctx)
+	if err != nil {
+		return false, fmt.Errorf("failed to begin answer lock: %v", err)
 	}
+	defer tx.Rollback(ctx)
 
-	rng := rand.New(rand.NewSource(seed))
-	selected := make([]int, 0, count)
-	used := make(map[int]bool)
+	var answered bool
+	err = tx.QueryRow(ctx,
+		`SELECT EXISTS (SELECT 1 FROM attempt_answers WHERE attempt_id = a.id AND question_id = $2)
+		 FROM attempts a WHERE a.id = $1 AND a.ended_at IS NULL
+		 FOR UPDATE OF a`,
+		attemptID, questionID).Scan(&answered)
+	if err == pgx.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to lock attempt: %v", err)
+	}
+	if answered {
+		return false, nil
+	}
 
-	for len(selected) < count {
-		totalWeight := 0.0
-		for _, q := range questions {
-			if !used[q.ID] {
-				totalWeight += q.Weight
-			}
-		}
+	if err := copyAttemptAnswers(ctx, tx, attemptID, answers); err != nil {
+		return false, err
+	}
+	if err := copyAnswerSelections(ctx, tx, selections); err != nil {
+		return false, err
+	}
 
-		if totalWeight == 0 {
-			break
-		}
+	if err := tx.Commit(ctx); err != nil {
+		return false, fmt.Errorf("failed to commit answer lock: %v", err)
+	}
+	return true, nil
+}
 
-		target := rng.Float64() * totalWeight
-		current := 0.0
+// SubmitAttempt grades an open attempt in one transaction: it closes the
+// attempt with its score, replaces answers left behind by an earlier failed
+// submit, stores the answers and final selections with COPY and derives the
+// answer timings. The close is conditional on ended_at being unset, so a
+// concurrent submit of the same attempt waits on the row lock and then finds
+// it closed. It reports false, having written nothing, when the attempt was
+// already closed.
+func (r *Repository) SubmitAttempt(ctx context.Context, submission models.AttemptSubmission) (bool, error) {
+	ctx, span := tracing.Start(ctx, "repository.SubmitAttempt", tracing.AttemptID(submission.AttemptID),
+		attribute.Int("capm.answer.count", len(submission.Answers)))
+	defer span.End()
+
+	tx, err := r.db.Pool.Begin(ctx)
+	if err != nil {
+		return false, fmt.Errorf("failed to begin submission: %v", err)
+	}
+	defer tx.Rollback(ctx)
 
-		for _, q := range questions {
-			if used[q.ID] {
-				continue
-			}
-			current += q.Weight
-			if current >= target {
-				selected = append(selected, q.ID)
-				used[q.ID] = true
-				break
-			}
-		}
+	commandTag, err := tx.Exec(ctx,
+		`UPDATE attempts SET score = $1, ended_at = NOW(), submit_idempotency_key = NULLIF($3, '')
+		 WHERE id = $2 AND ended_at IS NULL`,
+		submission.Score, submission.AttemptID, submission.IdempotencyKey)
+	if err != nil {
+		return false, fmt.Errorf("failed to close attempt: %v", err)
+	}
+	if commandTag.RowsAffected() == 0 {
+		return false, nil
 	}
 
-	for len(selected) < count {
-		for _, q := range questions {
-			if !used[q.ID] {
-				selected = append(selected, q.ID)
-				used[q.ID] = true
-				break
-			}
-		}
+	if _, err := tx.Exec(ctx, "DELETE FROM attempt_answers WHERE attempt_id = $1", submission.AttemptID); err != nil {
+		return false, fmt.Errorf("failed to clear attempt answers: %v", err)
+	}
+	if err := copyAttemptAnswers(ctx, tx, submission.AttemptID, submission.Answers); err != nil {
+		return false, err
+	}
+	if err := copyAnswerSelections(ctx, tx, submission.Selections); err != nil {
+		return false, err
+	}
+	if err := storeAnswerTimings(ctx, tx, submission.AttemptID); err != nil {
+		return false, err
 	}
 
-	return selected, nil
+	if err := tx.Commit(ctx); err != nil {
+		return false, fmt.Errorf("failed to commit submission: %v", err)
+	}
+	return true, nil
 }
 
-func (r *Repository) GetAttemptsByUser(ctx context.Context, userID uuid.UUID) ([]models.AttemptHistory, error) {
-	query := `
-		SELECT a.id, a.exam_id, e.name, a.max_score, a.score, a.started_at, a.ended_at
-		FROM attempts a
-		JOIN exams e ON e.id = a.exam_id
-		WHERE a.user_id = $1
-		ORDER BY a.started_at DESC`
-
-	rows, err := r.db.Pool.Query(ctx, query, userID)
+// CloseSection ends the current section of a sectioned attempt in one
+// transaction: it moves the attempt on, stores the section's answers and
+// final selections with COPY and, for the last section, scores every stored
+// answer and derives the answer timings. The state change is conditional on
+// the attempt still being in the section and status it was loaded in, so a
+// concurrent close waits on the row lock and then finds it moved on. It
+// reports false, having written nothing, in that case; otherwise it returns
+// the score when the attempt was closed.
+func (r *Repository) CloseSection(ctx context.Context, closing models.SectionClose) (int, bool, error) {
+	ctx, span := tracing.Start(ctx, "repository.CloseSection", tracing.AttemptID(closing.AttemptID),
+		attribute.Int("capm.answer.count", len(closing.Answers)))
+	defer span.End()
+
+	tx, err := r.db.Pool.Begin(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get attempts: %v", err)
+		return 0, false, fmt.Errorf("failed to begin section close: %v", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var commandTag pgconn.CommandTag
+	if closing.Final {
+		commandTag, err = tx.Exec(ctx,
+			`UPDATE attempts SET section_status = 'completed', break_started_at = NULL,
+			        ended_at = NOW(), submit_idempotency_key = NULLIF($4, '')
+			 WHERE id = $1 AND current_section = $2 AND section_status = $3 AND ended_at IS NULL`,
+			closing.AttemptID, closing.Section, closing.Status, closing.IdempotencyKey)
+	} else {
+		commandTag, err = tx.Exec(ctx,
+			`UPDATE attempts SET section_status = 'break', break_started_at = $4
+			 WHERE id = $1 AND current_section = $2 AND section_status = $3 AND ended_at IS NULL`,
+			closing.AttemptID, closing.Section, closing.Status, closing.BreakStartedAt)
+	}
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to close section: %v", err)
+	}
+	if commandTag.RowsAffected() == 0 {
+		return 0, false, nil
 	}
-	defer rows.Close()
 
-	var history []models.AttemptHistory
-	for rows.Next() {
-		var record models.AttemptHistory
-		var score pgtype.Int4
+	if err := copyAttemptAnswers(ctx, tx, closing.AttemptID, closing.Answers); err != nil {
+		return 0, false, err
+	}
+	if err := copyAnswerSelections(ctx, tx, closing.Selections); err != nil {
+		return 0, false, err
+	}
 
-		if err := rows.Scan(&record.AttemptID, &record.ExamID, &record.ExamName, &record.MaxScore, &score, &record.StartedAt, &record.EndedAt); err != nil {
-			return nil, fmt.Errorf("failed to scan attempt history: %v", err)
+	score := 0
+	if closing.Final {
+		err := tx.QueryRow(ctx,
+			`UPDATE attempts SET score = (
+				SELECT COUNT(DISTINCT question_id) FROM attempt_answers
+				WHERE attempt_id = $1 AND is_correct
+			 )
+			 WHERE id = $1
+			 RETURNING score`,
+			closing.AttemptID).Scan(&score)
+		if err != nil {
+			return 0, false, fmt.Errorf("failed to score attempt: %v", err)
 		}
-
-		if score.Valid {
-			val := int(score.Int32)
-			record.Score = &val
+		if err := storeAnswerTimings(ctx, tx, closing.AttemptID); err != nil {
+			return 0, false, err
 		}
+	}
 
-		record.QuestionCount = record.MaxScore
+	if err := tx.Commit(ctx); err != nil {
+		return 0, false, fmt.Errorf("failed to commit section close: %v", err)
+	}
+	return score, true, nil
+}
 
-		switch {
-		case record.ExamName == pmpExamName:
-			record.AttemptType = "PMP Mock Exam"
-		case record.ExamName == hardExamName:
-			record.AttemptType = "Hard Drill"
-		case record.MaxScore <= 20:
-			record.AttemptType = "Short Quiz"
-		default:
-			record.AttemptType = "Mock Exam"
-		}
+func copyAttemptAnswers(ctx context.Context, q querier, attemptID uuid.UUID, answers []models.GradedChoice) error {
+	if len(answers) == 0 {
+		return nil
+	}
 
-		history = append(history, record)
+	rows := make([][]interface{}, 0, len(answers))
+	for _, answer := range answers {
+		rows = append(rows, []interface{}{attemptID, answer.QuestionID, answer.ChoiceID, answer.IsCorrect})
 	}
 
-	return history, nil
+	_, err := q.CopyFrom(ctx,
+		pgx.Identifier{"attempt_answers"},
+		[]string{"attempt_id", "question_id", "choice_id", "is_correct"},
+		pgx.CopyFromRows(rows))
+	if err != nil {
+		return fmt.Errorf("failed to create attempt answers: %v", err)
+	}
+	return nil
 }
 
-func (r *Repository) GetRandomQuestionsExcludingDomain(ctx context.Context, count int, seed int64, domain string) ([]int, error) {
-	query := `
-		SELECT id, popularity_score
-		FROM questions
-		WHERE domain <> $1
-		ORDER BY popularity_score DESC`
+func (r *Repository) DeleteAttempt(ctx context.Context, attemptID uuid.UUID) error {
+	if _, err := r.db.Pool.Exec(ctx, "DELETE FROM attempt_answers WHERE attempt_id = $1", attemptID); err != nil {
+		return fmt.Errorf("failed to delete attempt answers: %v", err)
+	}
 
-	rows, err := r.db.Pool.Query(ctx, query, domain)
+	commandTag, err := r.db.Pool.Exec(ctx, "DELETE FROM attempts WHERE id = $1", attemptID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get questions excluding %s: %v", domain, err)
+		return fmt.Errorf("failed to delete attempt: %v", err)
 	}
-	defer rows.Close()
+	if commandTag.RowsAffected() == 0 {
+		return fmt.Errorf("attempt not found")
+	}
+	return nil
+}
+
+func (r *Repository) GetAttemptAnswers(ctx context.Context, attemptID uuid.UUID) ([]models.AttemptAnswer, error) {
+	query := `
+		SELECT id, attempt_id, question_id, choice_id, is_correct, dwell_ms, answer_changes
+		FROM attempt_answers
+		WHERE attempt_id = $1
+		ORDER BY question_id`
 
-	type weightedQuestion struct {
-		ID     int
-		Weight float64
+	rows, err := r.db.Pool.Query(ctx, query, attemptID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get attempt answers: %v", err)
 	}
+	defer rows.Close()
 
-	var questions []weightedQuestion
+	var answers []models.AttemptAnswer
 	for rows.Next() {
-		var wq weightedQuestion
-		if err := rows.Scan(&wq.ID, &wq.Weight); err != nil {
-			return nil, fmt.Errorf("failed to scan question: %v", err)
+		var answer models.AttemptAnswer
+		err := rows.Scan(&answer.ID, &answer.AttemptID, &answer.QuestionID, &answer.ChoiceID, &answer.IsCorrect, &answer.DwellMs, &answer.AnswerChanges)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan attempt answer: %v", err)
 		}
-		questions = append(questions, wq)
+		answers = append(answers, answer)
 	}
 
-	if len(questions) < count {
-		return nil, fmt.Errorf("not enough questions outside domain %s: have %d, need %d", domain, len(questions), count)
+	return answers, nil
+}
+
+func (r *Repository) CreateAttemptEvents(ctx context.Context, attemptID uuid.UUID, events []models.AttemptEvent) error {
+	if len(events) == 0 {
+		return nil
 	}
 
-	rng := rand.New(rand.NewSource(seed))
-	selected := make([]int, 0, count)
-	used := make(map[int]bool)
+	rows := make([][]interface{}, 0, len(events))
+	for _, event := range events {
+		rows = append(rows, []interface{}{attemptID, event.QuestionID, event.Type, event.ChoiceIDs, event.DwellMs, event.OccurredAt})
+	}
 
-	for len(selected) < count {
-		totalWeight := 0.0
-		for _, q := range questions {
-			if !used[q.ID] {
-				totalWeight += q.Weight
-			}
-		}
+	_, err := r.db.Pool.CopyFrom(ctx,
+		pgx.Identifier{"attempt_events"},
+		[]string{"attempt_id", "question_id", "event_type", "choice_ids", "dwell_ms", "occurred_at"},
+		pgx.CopyFromRows(rows))
 
-		if totalWeight == 0 {
-			break
-		}
+	if err != nil {
+		return fmt.Errorf("failed to create attempt events: %v", err)
+	}
+	return nil
+}
 
-		target := rng.Float64() * totalWeight
-		current := 0.0
+func (r *Repository) GetAttemptEvents(ctx context.Context, attemptID uuid.UUID) ([]models.AttemptEvent, error) {
+	query := `
+		SELECT question_id, event_type, choice_ids, dwell_ms, occurred_at
+		FROM attempt_events
+		WHERE attempt_id = $1
+		ORDER BY occurred_at, id`
 
-		for _, q := range questions {
-			if used[q.ID] {
-				continue
-			}
-			current += q.Weight
-			if current >= target {
-				selected = append(selected, q.ID)
-				used[q.ID] = true
-				break
-			}
-		}
+	rows, err := r.db.Pool.Query(ctx, query, attemptID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get attempt events: %v", err)
 	}
+	defer rows.Close()
 
-	for len(selected) < count {
-		for _, q := range questions {
-			if !used[q.ID] {
-				selected = append(selected, q.ID)
-				used[q.ID] = true
-				break
-			}
+	var events []models.AttemptEvent
+	for rows.Next() {
+		var event models.AttemptEvent
+		if err := rows.Scan(&event.QuestionID, &event.Type, &event.ChoiceIDs, &event.DwellMs, &event.OccurredAt); err != nil {
+			return nil, fmt.Errorf("failed to scan attempt event: %v", err)
 		}
+		events = append(events, event)
 	}
 
-	return selected, nil
+	return events, nil
 }
 
-func (r *Repository) CreateAttempt(ctx context.Context, userID uuid.UUID, examID uuid.UUID, seed int64, maxScore int) (*models.Attempt, error) {
-	var attempt models.Attempt
-	err := r.db.Pool.QueryRow(ctx,
-		"INSERT INTO attempts (user_id, exam_id, seed, max_score) VALUES ($1, $2, $3, $4) RETURNING id, exam_id, user_id, seed, score, max_score, started_at, ended_at",
-		userID, examID, seed, maxScore).Scan(
-		&attempt.ID, &attempt.ExamID, &attempt.UserID, &attempt.Seed, &attempt.Score, &attempt.MaxScore, &attempt.StartedAt, &attempt.EndedAt)
-
-	if err != nil {
-		return nil, fmt.Errorf("failed to create attempt: %v", err)
+// storeAnswerTimings copies the dwell time, number of answer changes and time
+// of the final selection from the attempt's events and selection history onto
+// its stored answers.
+func storeAnswerTimings(ctx context.Context, q querier, attemptID uuid.UUID) error {
+	query := `
+		UPDATE attempt_answers aa
+		SET dwell_ms = t.dwell_ms,
+		    answer_changes = t.answer_changes,
+		    answered_at = t.answered_at
+		FROM (
+			SELECT COALESCE(e.question_id, sel.question_id) AS question_id,
+			       COALESCE(e.dwell_ms, 0) AS dwell_ms,
+			       COALESCE(sel.answer_changes, 0) AS answer_changes,
+			       sel.answered_at
+			FROM (
+				SELECT question_id, SUM(dwell_ms) AS dwell_ms
+				FROM attempt_events
+				WHERE attempt_id = $1
+				GROUP BY question_id
+			) e
+			FULL OUTER JOIN (
+				SELECT question_id,
+				       GREATEST(COUNT(*) - 1, 0) AS answer_changes,
+				       MAX(selected_at) AS answered_at
+				FROM answer_selections
+				WHERE attempt_id = $1
+				GROUP BY question_id
+			) sel ON sel.question_id = e.question_id
+		) t
+		WHERE aa.attempt_id = $1 AND aa.question_id = t.question_id`
+
+	if _, err := q.Exec(ctx, query, attemptID); err != nil {
+		return fmt.Errorf("failed to store answer timings: %v", err)
 	}
-	return &attempt, nil
+	return nil
 }
 
-func (r *Repository) GetAttempt(ctx context.Context, attemptID uuid.UUID) (*models.Attempt, error) {
-	var attempt models.Attempt
-	err := r.db.Pool.QueryRow(ctx,
-		"SELECT id, exam_id, user_id, seed, score, max_score, started_at, ended_at FROM attempts WHERE id = $1",
-		attemptID).Scan(
-		&attempt.ID, &attempt.ExamID, &attempt.UserID, &attempt.Seed, &attempt.Score, &attempt.MaxScore, &attempt.StartedAt, &attempt.EndedAt)
+func (r *Repository) CreateAnswerSelections(ctx context.Context, selections []models.AnswerSelection) error {
+	ctx, span := tracing.Start(ctx, "repository.CreateAnswerSelections", attribute.Int("capm.selection.count", len(selections)))
+	defer span.End()
 
-	if err == pgx.ErrNoRows {
-		return nil, nil
+	return copyAnswerSelections(ctx, r.db.Pool, selections)
+}
+
+func copyAnswerSelections(ctx context.Context, q querier, selections []models.AnswerSelection) error {
+	if len(selections) == 0 {
+		return nil
 	}
-	if err != nil {
-		return nil, fmt.Errorf("failed to get attempt: %v", err)
+
+	rows := make([][]interface{}, 0, len(selections))
+	for _, sel := range selections {
+		rows = append(rows, []interface{}{sel.AttemptID, sel.QuestionID, sel.ChoiceIDs, sel.SelectedAt})
 	}
-	return &attempt, nil
-}
 
-func (r *Repository) CreateAttemptAnswer(ctx context.Context, attemptID uuid.UUID, questionID, choiceID int, isCorrect bool) error {
-	_, err := r.db.Pool.Exec(ctx,
-		"INSERT INTO attempt_answers (attempt_id, question_id, choice_id, is_correct) VALUES ($1, $2, $3, $4)",
-		attemptID, questionID, choiceID, isCorrect)
+	_, err := q.CopyFrom(ctx,
+		pgx.Identifier{"answer_selections"},
+		[]string{"attempt_id", "question_id", "choice_ids", "selected_at"},
+		pgx.CopyFromRows(rows))
 
 	if err != nil {
-		return fmt.Errorf("failed to create attempt answer: %v", err)
+		return fmt.Errorf("failed to create answer selections: %v", err)
 	}
 	return nil
 }
 
-func (r *Repository) UpdateAttemptScore(ctx context.Context, attemptID uuid.UUID, score int) error {
-	_, err := r.db.Pool.Exec(ctx,
-		"UPDATE attempts SET score = $1, ended_at = NOW() WHERE id = $2",
-		score, attemptID)
+func (r *Repository) GetAnswerSelections(ctx context.Context, attemptID uuid.UUID) ([]models.AnswerSelection, error) {
+	ctx, span := tracing.Start(ctx, "repository.GetAnswerSelections", tracing.AttemptID(attemptID))
+	defer span.End()
 
+	query := `
+		SELECT attempt_id, question_id, choice_ids, selected_at
+		FROM answer_selections
+		WHERE attempt_id = $1
+		ORDER BY selected_at, id`
+
+	rows, err := r.db.Pool.Query(ctx, query, attemptID)
 	if err != nil {
-		return fmt.Errorf("failed to update attempt score: %v", err)
+		return nil, fmt.Errorf("failed to get answer selections: %v", err)
 	}
-	return nil
+	defer rows.Close()
+
+	return scanAnswerSelections(rows)
 }
 
-func (r *Repository) DeleteAttempt(ctx context.Context, attemptID uuid.UUID) error {
-	if _, err := r.db.Pool.Exec(ctx, "DELETE FROM attempt_answers WHERE attempt_id = $1", attemptID); err != nil {
-		return fmt.Errorf("failed to delete attempt answers: %v", err)
-	}
+// GetAnswerSelectionsByUser returns the selection history of every submitted
+// attempt of the user.
+func (r *Repository) GetAnswerSelectionsByUser(ctx context.Context, userID uuid.UUID) ([]models.AnswerSelection, error) {
+	query := `
+		SELECT s.attempt_id, s.question_id, s.choice_ids, s.selected_at
+		FROM answer_selections s
+		JOIN attempts a ON a.id = s.attempt_id
+		WHERE a.user_id = $1 AND a.ended_at IS NOT NULL
+		ORDER BY s.attempt_id, s.selected_at, s.id`
 
-	commandTag, err := r.db.Pool.Exec(ctx, "DELETE FROM attempts WHERE id = $1", attemptID)
+	rows, err := r.db.Pool.Query(ctx, query, userID)
 	if err != nil {
-		return fmt.Errorf("failed to delete attempt: %v", err)
+		return nil, fmt.Errorf("failed to get answer selections for user: %v", err)
 	}
-	if commandTag.RowsAffected() == 0 {
-		return fmt.Errorf("attempt not found")
+	defer rows.Close()
+
+	return scanAnswerSelections(rows)
+}
+
+func scanAnswerSelections(rows pgx.Rows) ([]models.AnswerSelection, error) {
+	var selections []models.AnswerSelection
+	for rows.Next() {
+		var sel models.AnswerSelection
+		if err := rows.Scan(&sel.AttemptID, &sel.QuestionID, &sel.ChoiceIDs, &sel.SelectedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan answer selection: %v", err)
+		}
+		selections = append(selections, sel)
 	}
-	return nil
+	return selections, nil
 }
 
-func (r *Repository) GetAttemptAnswers(ctx context.Context, attemptID uuid.UUID) ([]models.AttemptAnswer, error) {
+// GetSubmittedAnswersByUser returns the stored final answers of every
+// submitted attempt of the user.
+func (r *Repository) GetSubmittedAnswersByUser(ctx context.Context, userID uuid.UUID) ([]models.AttemptAnswer, error) {
 	query := `
-		SELECT id, attempt_id, question_id, choice_id, is_correct
-		FROM attempt_answers
-		WHERE attempt_id = $1
-		ORDER BY question_id`
+		SELECT aa.id, aa.attempt_id, aa.question_id, aa.choice_id, aa.is_correct, aa.dwell_ms, aa.answer_changes
+		FROM attempt_answers aa
+		JOIN attempts a ON a.id = aa.attempt_id
+		WHERE a.user_id = $1 AND a.ended_at IS NOT NULL
+		ORDER BY aa.attempt_id, aa.question_id`
 
-	rows, err := r.db.Pool.Query(ctx, query, attemptID)
+	rows, err := r.db.Pool.Query(ctx, query, userID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get attempt answers: %v", err)
+		return nil, fmt.Errorf("failed to get submitted answers for user: %v", err)
 	}
 	defer rows.Close()
 
 	var answers []models.AttemptAnswer
 	for rows.Next() {
 		var answer models.AttemptAnswer
-		err := rows.Scan(&answer.ID, &answer.AttemptID, &answer.QuestionID, &answer.ChoiceID, &answer.IsCorrect)
+		err := rows.Scan(&answer.ID, &answer.AttemptID, &answer.QuestionID, &answer.ChoiceID, &answer.IsCorrect, &answer.DwellMs, &answer.AnswerChanges)
 		if err != nil {
-			return nil, fmt.Errorf("failed to scan attempt answer: %v", err)
+			return nil, fmt.Errorf("failed to scan submitted answer: %v", err)
 		}
 		answers = append(answers, answer)
 	}
@@ -699,6 +940,42 @@ func (r *Repository) GetAttemptAnswers(ctx context.Context, attemptID uuid.UUID)
 	return answers, nil
 }
 
+// GetDomainResultsByUser returns per-domain answered and correct counts for
+// every submitted attempt of the user, oldest attempt first.
+func (r *Repository) GetDomainResultsByUser(ctx context.Context, userID uuid.UUID) ([]models.DomainResult, error) {
+	query := `
+		SELECT a.id, a.ended_at, q.domain,
+		       COUNT(*),
+		       COUNT(*) FILTER (WHERE aq.is_correct)
+		FROM (
+			SELECT attempt_id, question_id, BOOL_OR(COALESCE(is_correct, FALSE)) AS is_correct
+			FROM attempt_answers
+			GROUP BY attempt_id, question_id
+		) aq
+		JOIN attempts a ON a.id = aq.attempt_id
+		JOIN questions q ON q.id = aq.question_id
+		WHERE a.user_id = $1 AND a.ended_at IS NOT NULL
+		GROUP BY a.id, a.ended_at, q.domain
+		ORDER BY a.ended_at, q.domain`
+
+	rows, err := r.db.Pool.Query(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get domain results: %v", err)
+	}
+	defer rows.Close()
+
+	var results []models.DomainResult
+	for rows.Next() {
+		var result models.DomainResult
+		if err := rows.Scan(&result.AttemptID, &result.EndedAt, &result.Domain, &result.Answered, &result.Correct); err != nil {
+			return nil, fmt.Errorf("failed to scan domain result: %v", err)
+		}
+		results = append(results, result)
+	}
+
+	return results, nil
+}
+
 func (r *Repository) UpdateAttemptAnswerCorrectness(ctx context.Context, answerID uuid.UUID, isCorrect bool) error {
 	_, err := r.db.Pool.Exec(ctx,
 		"UPDATE attempt_answers SET is_correct = $1 WHERE id = $2",
@@ -740,6 +1017,9 @@ func (r *Repository) GetExamByName(ctx context.Context, name string) (*models.Ex
 }
 
 func (r *Repository) GetExamByID(ctx context.Context, examID uuid.UUID) (*models.Exam, error) {
+	ctx, span := tracing.Start(ctx, "repository.GetExamByID")
+	defer span.End()
+
 	var exam models.Exam
 	err := r.db.Pool.QueryRow(ctx,
 		"SELECT id, name, description, created_at FROM exams WHERE id = $1",
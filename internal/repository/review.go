@@ -0,0 +1,118 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"capm-exam-system/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// AddBookmark saves a question for the user. Bookmarking it again keeps the
+// original bookmark; added reports whether a new one was stored.
+func (r *Repository) AddBookmark(ctx context.Context, userID uuid.UUID, questionID int) (bool, error) {
+	tag, err := r.db.Pool.Exec(ctx,
+		`INSERT INTO bookmarks (user_id, question_id) VALUES ($1, $2)
+		 ON CONFLICT (user_id, question_id) DO NOTHING`,
+		userID, questionID)
+	if err != nil {
+		return false, fmt.Errorf("failed to add bookmark: %v", err)
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// RemoveBookmark reports whether there was a bookmark to remove.
+func (r *Repository) RemoveBookmark(ctx context.Context, userID uuid.UUID, questionID int) (bool, error) {
+	tag, err := r.db.Pool.Exec(ctx,
+		`DELETE FROM bookmarks WHERE user_id = $1 AND question_id = $2`,
+		userID, questionID)
+	if err != nil {
+		return false, fmt.Errorf("failed to remove bookmark: %v", err)
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// ListBookmarks returns the user's bookmarks, newest first.
+func (r *Repository) ListBookmarks(ctx context.Context, userID uuid.UUID) ([]models.Bookmark, error) {
+	rows, err := r.db.Pool.Query(ctx,
+		`SELECT b.question_id, q.prompt, q.domain, b.created_at
+		 FROM bookmarks b
+		 JOIN questions q ON q.id = b.question_id
+		 WHERE b.user_id = $1
+		 ORDER BY b.created_at DESC, b.question_id`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list bookmarks: %v", err)
+	}
+	defer rows.Close()
+
+	var bookmarks []models.Bookmark
+	for rows.Next() {
+		var bookmark models.Bookmark
+		if err := rows.Scan(&bookmark.QuestionID, &bookmark.Prompt, &bookmark.Domain, &bookmark.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan bookmark: %v", err)
+		}
+		bookmarks = append(bookmarks, bookmark)
+	}
+	return bookmarks, nil
+}
+
+// GetAttemptMissedQuestionIDs returns the questions of an attempt that were
+// answered wrongly or left unanswered, in serving order.
+func (r *Repository) GetAttemptMissedQuestionIDs(ctx context.Context, attemptID uuid.UUID) ([]int, error) {
+	rows, err := r.db.Pool.Query(ctx,
+		`SELECT aq.question_id
+		 FROM attempt_questions aq
+		 WHERE aq.attempt_id = $1
+		   AND NOT EXISTS (
+		       SELECT 1 FROM attempt_answers aa
+		       WHERE aa.attempt_id = aq.attempt_id AND aa.question_id = aq.question_id AND aa.is_correct
+		   )
+		 ORDER BY aq.position`, attemptID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get missed questions: %v", err)
+	}
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan missed question: %v", err)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// FindMissedQuestionIDs returns the questions the user answered wrongly or
+// left unanswered in attempts submitted since then, most recently missed
+// first.
+func (r *Repository) FindMissedQuestionIDs(ctx context.Context, userID uuid.UUID, since time.Time) ([]int, error) {
+	rows, err := r.db.Pool.Query(ctx,
+		`SELECT aq.question_id
+		 FROM attempt_questions aq
+		 JOIN attempts a ON a.id = aq.attempt_id
+		 WHERE a.user_id = $1 AND a.ended_at IS NOT NULL AND a.ended_at >= $2
+		   AND NOT EXISTS (
+		       SELECT 1 FROM attempt_answers aa
+		       WHERE aa.attempt_id = aq.attempt_id AND aa.question_id = aq.question_id AND aa.is_correct
+		   )
+		 GROUP BY aq.question_id
+		 ORDER BY MAX(a.ended_at) DESC, aq.question_id`, userID, since)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find missed questions: %v", err)
+	}
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan missed question: %v", err)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
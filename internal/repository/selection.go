@@ -0,0 +1,188 @@
+package repository
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+)
+
+// Selection records what an attempt's draw has used so far, so that later
+// picks avoid repeating a question, a template family or an enemy of a
+// question already chosen. One Selection is shared by every domain drawn for
+// the attempt.
+type Selection struct {
+	chosen   map[int]struct{}
+	families map[string]int
+	enemies  map[int][]int
+	blocked  map[int]struct{}
+	scale    map[int]float64
+	// allowed, when set, holds the only questions the draw may use
+	allowed map[int]struct{}
+
+	// FamilyRepeats and EnemyConflicts count picks that had to break a
+	// constraint because the domain had nothing else left. The draw is not
+	// failed for them; the caller reports them to operators.
+	FamilyRepeats  int
+	EnemyConflicts int
+}
+
+// NewSelection starts a draw. DrawQuestions fills in the enemy pairs from
+// the question bank.
+func NewSelection() *Selection {
+	return &Selection{
+		chosen:   make(map[int]struct{}),
+		families: make(map[string]int),
+		blocked:  make(map[int]struct{}),
+		scale:    make(map[int]float64),
+	}
+}
+
+// ScaleWeight multiplies a question's draw weight by factor. Factors for the
+// same question compound.
+func (s *Selection) ScaleWeight(questionID int, factor float64) {
+	if current, ok := s.scale[questionID]; ok {
+		factor *= current
+	}
+	s.scale[questionID] = factor
+}
+
+// Restrict limits the draw to the given questions.
+func (s *Selection) Restrict(questionIDs []int) {
+	s.allowed = make(map[int]struct{}, len(questionIDs))
+	for _, id := range questionIDs {
+		s.allowed[id] = struct{}{}
+	}
+}
+
+// usable reports whether q is still available to the draw.
+func (s *Selection) usable(q selectionCandidate) bool {
+	if _, used := s.chosen[q.ID]; used {
+		return false
+	}
+	if s.allowed != nil {
+		if _, ok := s.allowed[q.ID]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *Selection) weight(q selectionCandidate) float64 {
+	if factor, ok := s.scale[q.ID]; ok {
+		return q.Weight * factor
+	}
+	return q.Weight
+}
+
+func (s *Selection) add(q selectionCandidate) {
+	s.chosen[q.ID] = struct{}{}
+	if q.Family != "" {
+		s.families[q.Family]++
+	}
+	for _, enemy := range s.enemies[q.ID] {
+		s.blocked[enemy] = struct{}{}
+	}
+}
+
+func (s *Selection) familyUses(q selectionCandidate) int {
+	if q.Family == "" {
+		return 0
+	}
+	return s.families[q.Family]
+}
+
+type selectionCandidate struct {
+	ID     int
+	Weight float64
+	Family string
+}
+
+func (s *Selection) available(candidates []selectionCandidate) int {
+	available := 0
+	for _, q := range candidates {
+		if s.usable(q) {
+			available++
+		}
+	}
+	return available
+}
+
+type keyedCandidate struct {
+	selectionCandidate
+	key float64
+	tie float64
+}
+
+// pick draws count questions from candidates by weight in one pass, using
+// Efraimidis-Spirakis keys: each candidate gets log(u)/weight for a uniform
+// u, and taking candidates in descending key order is weighted sampling
+// without replacement. Zero-weight candidates sort last, in random order.
+//
+// Candidates are then taken in key order, skipping any that would repeat a
+// family before every family in the domain has been used as often, or that
+// is an enemy of an earlier pick. Skipped candidates are reconsidered on the
+// next round once families start repeating; enemies are let through only
+// when nothing else is left. Both relaxations are deliberate, since a short
+// domain should still yield a full attempt, and are counted on the Selection.
+func (s *Selection) pick(rng *rand.Rand, candidates []selectionCandidate, count int) []int {
+	keyed := make([]keyedCandidate, 0, len(candidates))
+	for _, q := range candidates {
+		if !s.usable(q) {
+			continue
+		}
+		// 1-Float64 is in (0, 1], so the log is finite
+		u := 1 - rng.Float64()
+		key := math.Inf(-1)
+		if weight := s.weight(q); weight > 0 {
+			key = math.Log(u) / weight
+		}
+		keyed = append(keyed, keyedCandidate{selectionCandidate: q, key: key, tie: u})
+	}
+	sort.Slice(keyed, func(i, j int) bool {
+		if keyed[i].key != keyed[j].key {
+			return keyed[i].key > keyed[j].key
+		}
+		return keyed[i].tie > keyed[j].tie
+	})
+
+	selected := make([]int, 0, count)
+	taken := make([]bool, len(keyed))
+	for _, allowEnemies := range []bool{false, true} {
+		for level := 0; len(selected) < count; level++ {
+			deferred := false
+			for i := range keyed {
+				if len(selected) == count {
+					break
+				}
+				if taken[i] {
+					continue
+				}
+				q := keyed[i].selectionCandidate
+				_, blocked := s.blocked[q.ID]
+				if blocked && !allowEnemies {
+					continue
+				}
+				uses := s.familyUses(q)
+				if uses > level {
+					deferred = true
+					continue
+				}
+
+				if uses > 0 {
+					s.FamilyRepeats++
+				}
+				if blocked {
+					s.EnemyConflicts++
+				}
+				s.add(q)
+				taken[i] = true
+				selected = append(selected, q.ID)
+			}
+			if !deferred {
+				break
+			}
+		}
+	}
+
+	return selected
+}
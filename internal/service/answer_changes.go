@@ -0,0 +1,189 @@
+package service
+
+import (
+	"context"
+	"sort"
+
+	"capm-exam-system/internal/models"
+
+	"github.com/google/uuid"
+)
+
+const (
+	questionTypeSingle = "single_select"
+	questionTypeMulti  = "multi_select"
+)
+
+// GetAttemptAnswerChanges reports first-instinct accuracy and the effect of
+// answer switching for one submitted attempt.
+func (s *Service) GetAttemptAnswerChanges(ctx context.Context, attemptID uuid.UUID) (*models.AnswerChangeReport, error) {
+	attempt, err := s.repo.GetAttempt(ctx, attemptID)
+	if err != nil {
+		return nil, err
+	}
+	if attempt == nil {
+		return nil, ErrAttemptNotFound
+	}
+	if attempt.EndedAt == nil {
+		return nil, ErrAttemptNotSubmitted
+	}
+
+	answers, err := s.repo.GetAttemptAnswers(ctx, attemptID)
+	if err != nil {
+		return nil, err
+	}
+
+	selections, err := s.repo.GetAnswerSelections(ctx, attemptID)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.answerChangeReport(ctx, answers, selections)
+}
+
+// GetUserAnswerChanges aggregates the answer-change report over every
+// submitted attempt of the user.
+func (s *Service) GetUserAnswerChanges(ctx context.Context, userID uuid.UUID) (*models.AnswerChangeReport, error) {
+	answers, err := s.repo.GetSubmittedAnswersByUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	selections, err := s.repo.GetAnswerSelectionsByUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.answerChangeReport(ctx, answers, selections)
+}
+
+type attemptQuestionKey struct {
+	attemptID  uuid.UUID
+	questionID int
+}
+
+func (s *Service) answerChangeReport(ctx context.Context, answers []models.AttemptAnswer, selections []models.AnswerSelection) (*models.AnswerChangeReport, error) {
+	finals := make(map[attemptQuestionKey][]int)
+	for _, answer := range answers {
+		if answer.ChoiceID == nil {
+			continue
+		}
+		key := attemptQuestionKey{attemptID: answer.AttemptID, questionID: answer.QuestionID}
+		finals[key] = append(finals[key], *answer.ChoiceID)
+	}
+
+	firsts := make(map[attemptQuestionKey][]int)
+	for _, sel := range selections {
+		key := attemptQuestionKey{attemptID: sel.AttemptID, questionID: sel.QuestionID}
+		if _, seen := firsts[key]; seen || len(sel.ChoiceIDs) == 0 {
+			continue
+		}
+		firsts[key] = sel.ChoiceIDs
+	}
+
+	questionIDs := make([]int, 0)
+	seenQuestion := make(map[int]struct{})
+	for key := range finals {
+		if _, ok := seenQuestion[key.questionID]; !ok {
+			seenQuestion[key.questionID] = struct{}{}
+			questionIDs = append(questionIDs, key.questionID)
+		}
+	}
+
+	questions, err := s.repo.GetQuestionsWithChoices(ctx, questionIDs)
+	if err != nil {
+		return nil, err
+	}
+	questionMap := make(map[int]models.QuestionWithChoices, len(questions))
+	for _, q := range questions {
+		questionMap[q.ID] = q
+	}
+
+	return buildAnswerChangeReport(questionMap, finals, firsts), nil
+}
+
+// buildAnswerChangeReport compares each graded answer with the first
+// selection made for the question. Questions without a recorded history count
+// as unchanged first instincts.
+func buildAnswerChangeReport(questionMap map[int]models.QuestionWithChoices, finals, firsts map[attemptQuestionKey][]int) *models.AnswerChangeReport {
+	summary := &models.AnswerChangeBreakdown{Key: "all"}
+	byDomain := make(map[string]*models.AnswerChangeBreakdown)
+	byType := make(map[string]*models.AnswerChangeBreakdown)
+
+	for key, final := range finals {
+		question, ok := questionMap[key.questionID]
+		if !ok {
+			continue
+		}
+
+		first, ok := firsts[key]
+		if !ok {
+			first = final
+		}
+
+		_, firstCorrect := gradeSelection(question, first)
+		_, finalCorrect := gradeSelection(question, final)
+		changed := !sameChoices(first, final)
+
+		questionType := questionTypeSingle
+		if question.IsMultiSelect {
+			questionType = questionTypeMulti
+		}
+
+		if byDomain[question.Domain] == nil {
+			byDomain[question.Domain] = &models.AnswerChangeBreakdown{Key: question.Domain}
+		}
+		if byType[questionType] == nil {
+			byType[questionType] = &models.AnswerChangeBreakdown{Key: questionType}
+		}
+
+		for _, b := range []*models.AnswerChangeBreakdown{summary, byDomain[question.Domain], byType[questionType]} {
+			tallyAnswerChange(b, firstCorrect, finalCorrect, changed)
+		}
+	}
+
+	report := &models.AnswerChangeReport{
+		Summary:        finishBreakdown(summary),
+		ByDomain:       sortedBreakdowns(byDomain),
+		ByQuestionType: sortedBreakdowns(byType),
+	}
+	return report
+}
+
+func tallyAnswerChange(b *models.AnswerChangeBreakdown, firstCorrect, finalCorrect, changed bool) {
+	b.Questions++
+	if firstCorrect {
+		b.FirstInstinctCorrect++
+	}
+	if !changed {
+		return
+	}
+
+	b.Changed++
+	switch {
+	case !firstCorrect && finalCorrect:
+		b.WrongToRight++
+		b.NetPoints++
+	case firstCorrect && !finalCorrect:
+		b.RightToWrong++
+		b.NetPoints--
+	case !firstCorrect && !finalCorrect:
+		b.WrongToWrong++
+	}
+}
+
+func finishBreakdown(b *models.AnswerChangeBreakdown) models.AnswerChangeBreakdown {
+	if b.Questions > 0 {
+		b.FirstInstinctRate = float64(b.FirstInstinctCorrect) / float64(b.Questions)
+	}
+	return *b
+}
+
+func sortedBreakdowns(groups map[string]*models.AnswerChangeBreakdown) []models.AnswerChangeBreakdown {
+	result := make([]models.AnswerChangeBreakdown, 0, len(groups))
+	for _, b := range groups {
+		result = append(result, finishBreakdown(b))
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Key < result[j].Key })
+	return result
+}
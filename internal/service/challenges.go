@@ -0,0 +1,232 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"sort"
+	"time"
+
+	"capm-exam-system/internal/models"
+	"capm-exam-system/internal/repository"
+
+	"github.com/google/uuid"
+)
+
+// challengeMACSize is how much of the HMAC-SHA256 a challenge token carries.
+const challengeMACSize = 16
+
+// CreateChallenge shares one of the user's attempts as a challenge: its
+// question set, order, choice order, sections and settings are frozen so
+// everyone who opens the link takes an identical attempt. Sharing an attempt
+// that already belongs to a challenge returns that challenge.
+func (s *Service) CreateChallenge(ctx context.Context, userID, attemptID uuid.UUID) (*models.ChallengeLink, error) {
+	attempt, err := s.repo.GetAttempt(ctx, attemptID)
+	if err != nil {
+		return nil, err
+	}
+	if attempt == nil {
+		return nil, ErrAttemptNotFound
+	}
+	if attempt.UserID != userID {
+		return nil, ErrAttemptForbidden
+	}
+
+	if attempt.ChallengeID != nil {
+		challenge, err := s.repo.GetChallenge(ctx, *attempt.ChallengeID)
+		if err != nil {
+			return nil, err
+		}
+		if challenge != nil {
+			return s.challengeLink(challenge), nil
+		}
+	}
+
+	questionIDs, err := s.repo.GetAttemptQuestionIDs(ctx, attemptID)
+	if err != nil {
+		return nil, err
+	}
+	if len(questionIDs) == 0 {
+		return nil, ErrNoQuestionSet
+	}
+
+	challenge, err := s.repo.CreateChallenge(ctx, &models.Challenge{
+		CreatorID:        userID,
+		ExamID:           attempt.ExamID,
+		QuestionIDs:      questionIDs,
+		Seed:             attempt.Seed,
+		Sectioned:        attempt.SectionStatus != sectionStatusNone,
+		TimeLimitSeconds: attempt.TimeLimitSeconds,
+		Feedback:         attempt.Feedback,
+	}, attemptID)
+	if err != nil {
+		return nil, err
+	}
+
+	s.audit(ctx, &userID, "challenge.created", "challenge", challenge.ID.String(), nil, map[string]interface{}{
+		"challenge":         challenge,
+		"source_attempt_id": attemptID,
+		"question_ids":      questionIDs,
+	})
+	return s.challengeLink(challenge), nil
+}
+
+// GetChallenge describes the challenge behind a link and compares the
+// scores of everyone who has submitted it, overall and per domain.
+func (s *Service) GetChallenge(ctx context.Context, token string) (*models.ChallengeSummary, error) {
+	challenge, err := s.challengeFromToken(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+
+	exam, err := s.repo.GetExamByID(ctx, challenge.ExamID)
+	if err != nil {
+		return nil, err
+	}
+	if exam == nil {
+		return nil, ErrChallengeNotFound
+	}
+	creator, err := s.repo.GetUserByID(ctx, challenge.CreatorID)
+	if err != nil {
+		return nil, err
+	}
+
+	summary := &models.ChallengeSummary{
+		Token:            token,
+		ExamName:         exam.Name,
+		AttemptType:      repository.AttemptType(exam.Name, len(challenge.QuestionIDs)),
+		QuestionCount:    len(challenge.QuestionIDs),
+		TimeLimitSeconds: challenge.TimeLimitSeconds,
+		Feedback:         challenge.Feedback,
+		CreatedAt:        challenge.CreatedAt,
+		Domains:          []string{},
+		Participants:     []models.ChallengeParticipant{},
+	}
+	if creator != nil {
+		summary.CreatorName = creator.Name
+	}
+
+	results, err := s.repo.GetChallengeDomainResults(ctx, challenge.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	domains := make(map[string]struct{})
+	index := make(map[uuid.UUID]int)
+	for _, result := range results {
+		if _, ok := domains[result.Domain]; !ok {
+			domains[result.Domain] = struct{}{}
+			summary.Domains = append(summary.Domains, result.Domain)
+		}
+		i, ok := index[result.AttemptID]
+		if !ok {
+			i = len(summary.Participants)
+			index[result.AttemptID] = i
+			summary.Participants = append(summary.Participants, models.ChallengeParticipant{
+				AttemptID:  result.AttemptID,
+				Name:       result.Name,
+				Score:      result.Score,
+				MaxScore:   result.MaxScore,
+				Percentage: percentage(result.Score, result.MaxScore),
+				EndedAt:    result.EndedAt,
+				Domains:    []models.ChallengeDomainScore{},
+			})
+		}
+		summary.Participants[i].Domains = append(summary.Participants[i].Domains, models.ChallengeDomainScore{
+			Domain:     result.Domain,
+			Correct:    result.Correct,
+			Total:      result.Total,
+			Percentage: percentage(result.Correct, result.Total),
+		})
+	}
+
+	sort.Strings(summary.Domains)
+	sort.SliceStable(summary.Participants, func(i, j int) bool {
+		return summary.Participants[i].Percentage > summary.Participants[j].Percentage
+	})
+	return summary, nil
+}
+
+// StartChallenge starts the challenge's frozen attempt for the user.
+func (s *Service) StartChallenge(ctx context.Context, userID uuid.UUID, token string) (*models.Attempt, error) {
+	challenge, err := s.challengeFromToken(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+
+	exam, err := s.repo.GetExamByID(ctx, challenge.ExamID)
+	if err != nil {
+		return nil, err
+	}
+	if exam == nil {
+		return nil, ErrChallengeNotFound
+	}
+
+	challengeID := challenge.ID
+	spec := attemptSpec{
+		feedback: challenge.Feedback,
+		draw: func(ctx context.Context, attempt *models.Attempt, exam *models.Exam) ([]int, error) {
+			return append([]int(nil), challenge.QuestionIDs...), nil
+		},
+		seed:         challenge.Seed,
+		keepSections: challenge.Sectioned,
+		challengeID:  &challengeID,
+	}
+	if challenge.TimeLimitSeconds != nil {
+		spec.timeLimit = time.Duration(*challenge.TimeLimitSeconds) * time.Second
+	}
+	return s.createAttempt(ctx, userID, exam, len(challenge.QuestionIDs), spec)
+}
+
+func (s *Service) challengeFromToken(ctx context.Context, token string) (*models.Challenge, error) {
+	challengeID, ok := s.parseChallengeToken(token)
+	if !ok {
+		return nil, ErrChallengeNotFound
+	}
+	challenge, err := s.repo.GetChallenge(ctx, challengeID)
+	if err != nil {
+		return nil, err
+	}
+	if challenge == nil {
+		return nil, ErrChallengeNotFound
+	}
+	return challenge, nil
+}
+
+func (s *Service) challengeLink(challenge *models.Challenge) *models.ChallengeLink {
+	token := s.challengeToken(challenge.ID)
+	return &models.ChallengeLink{Challenge: *challenge, Token: token, URL: "/challenge/" + token}
+}
+
+// challengeToken is the challenge ID followed by a truncated HMAC of it, so
+// links cannot be forged or enumerated without the secret.
+func (s *Service) challengeToken(challengeID uuid.UUID) string {
+	raw := append(challengeID[:], s.challengeMAC(challengeID)...)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+func (s *Service) parseChallengeToken(token string) (uuid.UUID, bool) {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil || len(raw) != len(uuid.UUID{})+challengeMACSize {
+		return uuid.Nil, false
+	}
+	challengeID, err := uuid.FromBytes(raw[:len(uuid.UUID{})])
+	if err != nil || !hmac.Equal(raw[len(uuid.UUID{}):], s.challengeMAC(challengeID)) {
+		return uuid.Nil, false
+	}
+	return challengeID, true
+}
+
+func (s *Service) challengeMAC(challengeID uuid.UUID) []byte {
+	mac := hmac.New(sha256.New, []byte(s.config.ChallengeSecret))
+	mac.Write(challengeID[:])
+	return mac.Sum(nil)[:challengeMACSize]
+}
+
+func percentage(part, whole int) float64 {
+	if whole == 0 {
+		return 0
+	}
+	return float64(part) / float64(whole) * 100
+}
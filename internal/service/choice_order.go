@@ -0,0 +1,43 @@
+package service
+
+import (
+	"math/rand"
+
+	"capm-exam-system/internal/models"
+)
+
+var choiceLabels = []string{"A", "B", "C", "D", "E", "F", "G", "H"}
+
+// orderChoicesForAttempt shuffles each question's choices for the attempt and
+// relabels them so A, B, C... follow the displayed order. The order depends
+// only on the attempt seed and the question, so the questions, the submit
+// response, the results page and the PDF report all show the same labels.
+// Questions that opted out of shuffling keep their authored order and labels.
+func orderChoicesForAttempt(attempt *models.Attempt, questions []models.QuestionWithChoices) {
+	for i := range questions {
+		question := &questions[i]
+		if !question.ShuffleChoices || len(question.Choices) < 2 {
+			continue
+		}
+
+		// Choices arrive in authored (label) order; copy before shuffling so
+		// the caller's slice is not reordered behind its back.
+		choices := append([]models.Choice(nil), question.Choices...)
+		rng := rand.New(rand.NewSource(choiceSeed(attempt.Seed, question.ID)))
+		rng.Shuffle(len(choices), func(a, b int) {
+			choices[a], choices[b] = choices[b], choices[a]
+		})
+		for j := range choices {
+			if j < len(choiceLabels) {
+				choices[j].Label = choiceLabels[j]
+			}
+		}
+		question.Choices = choices
+	}
+}
+
+// choiceSeed mixes the question ID into the attempt seed so two questions
+// with the same number of choices are not shuffled identically.
+func choiceSeed(attemptSeed int64, questionID int) int64 {
+	return attemptSeed ^ int64(questionID)*0x5851F42D4C957F2D
+}
@@ -0,0 +1,378 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"sort"
+	"strings"
+	"time"
+
+	"capm-exam-system/internal/models"
+
+	"github.com/google/uuid"
+)
+
+const (
+	inviteCodeLength = 8
+	// inviteCodeAlphabet leaves out characters that are easy to confuse when
+	// a code is read out in class (0/O, 1/I/L).
+	inviteCodeAlphabet = "ABCDEFGHJKMNPQRSTUVWXYZ23456789"
+	// inviteCodeByteLimit is the largest multiple of the alphabet size that
+	// fits in a byte; random bytes at or above it are discarded so every
+	// symbol is equally likely.
+	inviteCodeByteLimit = 256 / len(inviteCodeAlphabet) * len(inviteCodeAlphabet)
+	// inviteCodeAttempts is how many fresh codes are tried when a code is
+	// already taken.
+	inviteCodeAttempts = 5
+
+	cohortMissedQuestionLimit = 10
+
+	// A learner is at risk when the average of their latest attempts is
+	// below the pass mark, when they have overdue assignments, or when they
+	// have not submitted anything for a while.
+	atRiskRecentAttempts = 3
+	atRiskInactivity     = 14 * 24 * time.Hour
+
+	assignmentStatusCompleted = "completed"
+	assignmentStatusLate      = "completed_late"
+	assignmentStatusPending   = "pending"
+	assignmentStatusOverdue   = "overdue"
+)
+
+// assignmentKinds maps the assignable kinds to the attempt type a member has
+// to submit to complete them.
+var assignmentKinds = map[string]string{
+	"quiz": "Short Quiz",
+	"exam": "Mock Exam",
+	"hard": "Hard Drill",
+	"pmp":  "PMP Mock Exam",
+}
+
+func (s *Service) CreateCohort(ctx context.Context, instructorID uuid.UUID, name, description string) (*models.Cohort, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, ErrInvalidCohort
+	}
+
+	var cohort *models.Cohort
+	for i := 0; cohort == nil; i++ {
+		if i == inviteCodeAttempts {
+			return nil, ErrInviteCodeExhausted
+		}
+		code, err := newInviteCode()
+		if err != nil {
+			return nil, err
+		}
+		cohort, err = s.repo.CreateCohort(ctx, name, strings.TrimSpace(description), instructorID, code)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	s.audit(ctx, &instructorID, "cohort.created", "cohort", cohort.ID.String(), nil, cohort)
+	return cohort, nil
+}
+
+// JoinCohort enrols the user in the cohort that owns the invite code.
+func (s *Service) JoinCohort(ctx context.Context, userID uuid.UUID, inviteCode string) (*models.Cohort, error) {
+	cohort, err := s.repo.GetCohortByInviteCode(ctx, strings.ToUpper(strings.TrimSpace(inviteCode)))
+	if err != nil {
+		return nil, err
+	}
+	if cohort == nil {
+		return nil, ErrInviteCodeInvalid
+	}
+
+	if err := s.repo.AddCohortMember(ctx, cohort.ID, userID); err != nil {
+		return nil, err
+	}
+	s.audit(ctx, &userID, "cohort.joined", "cohort", cohort.ID.String(), nil, map[string]interface{}{"user_id": userID})
+
+	cohort.InviteCode = ""
+	return cohort, nil
+}
+
+// GetUserCohorts lists the cohorts the user teaches or belongs to. Invite
+// codes are only shown to the instructor.
+func (s *Service) GetUserCohorts(ctx context.Context, userID uuid.UUID) ([]models.Cohort, error) {
+	cohorts, err := s.repo.GetCohortsForUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range cohorts {
+		if cohorts[i].InstructorID != userID {
+			cohorts[i].InviteCode = ""
+		}
+	}
+	return cohorts, nil
+}
+
+func (s *Service) CreateCohortAssignment(ctx context.Context, cohortID, instructorID uuid.UUID, kind, title string, dueAt *time.Time) (*models.CohortAssignment, error) {
+	if _, err := s.instructedCohort(ctx, cohortID, instructorID); err != nil {
+		return nil, err
+	}
+
+	attemptType, ok := assignmentKinds[kind]
+	if !ok {
+		return nil, ErrInvalidAssignment
+	}
+	title = strings.TrimSpace(title)
+	if title == "" {
+		title = attemptType
+	}
+	if dueAt != nil {
+		due := dueAt.UTC()
+		dueAt = &due
+	}
+
+	assignment, err := s.repo.CreateCohortAssignment(ctx, cohortID, kind, title, dueAt)
+	if err != nil {
+		return nil, err
+	}
+
+	s.audit(ctx, &instructorID, "cohort.assignment_created", "cohort", cohortID.String(), nil, assignment)
+	return assignment, nil
+}
+
+// GetCohortAssignments lists a cohort's assignments for its instructor or
+// one of its members.
+func (s *Service) GetCohortAssignments(ctx context.Context, cohortID, userID uuid.UUID) ([]models.CohortAssignment, error) {
+	cohort, err := s.repo.GetCohort(ctx, cohortID)
+	if err != nil {
+		return nil, err
+	}
+	if cohort == nil {
+		return nil, ErrCohortNotFound
+	}
+
+	if cohort.InstructorID != userID {
+		member, err := s.repo.IsCohortMember(ctx, cohortID, userID)
+		if err != nil {
+			return nil, err
+		}
+		if !member {
+			return nil, ErrCohortForbidden
+		}
+	}
+
+	return s.repo.GetCohortAssignments(ctx, cohortID)
+}
+
+// GetCohortReport builds the instructor view of a cohort: assignment
+// completion, domain averages, the most missed questions and learners at
+// risk. Only attempts submitted after a member joined are counted.
+func (s *Service) GetCohortReport(ctx context.Context, cohortID, instructorID uuid.UUID) (*models.CohortReport, error) {
+	cohort, err := s.instructedCohort(ctx, cohortID, instructorID)
+	if err != nil {
+		return nil, err
+	}
+
+	members, err := s.repo.GetCohortMembers(ctx, cohortID)
+	if err != nil {
+		return nil, err
+	}
+
+	assignments, err := s.repo.GetCohortAssignments(ctx, cohortID)
+	if err != nil {
+		return nil, err
+	}
+
+	attempts, err := s.repo.GetCohortAttempts(ctx, cohortID)
+	if err != nil {
+		return nil, err
+	}
+
+	domainResults, err := s.repo.GetCohortDomainResults(ctx, cohortID)
+	if err != nil {
+		return nil, err
+	}
+
+	missed, err := s.repo.GetCohortMissedQuestions(ctx, cohortID, cohortMissedQuestionLimit)
+	if err != nil {
+		return nil, err
+	}
+	if missed == nil {
+		missed = []models.MissedQuestion{}
+	}
+	if members == nil {
+		members = []models.CohortMember{}
+	}
+
+	now := time.Now().UTC()
+	completions := assignmentCompletions(assignments, members, attempts, now)
+
+	return &models.CohortReport{
+		Cohort:         *cohort,
+		Members:        members,
+		Assignments:    completions,
+		DomainAverages: cohortDomainAverages(domainResults),
+		MostMissed:     missed,
+		AtRisk:         atRiskLearners(members, attempts, completions, now, s.config.PassMark),
+	}, nil
+}
+
+func (s *Service) instructedCohort(ctx context.Context, cohortID, instructorID uuid.UUID) (*models.Cohort, error) {
+	cohort, err := s.repo.GetCohort(ctx, cohortID)
+	if err != nil {
+		return nil, err
+	}
+	if cohort == nil {
+		return nil, ErrCohortNotFound
+	}
+	if cohort.InstructorID != instructorID {
+		return nil, ErrCohortForbidden
+	}
+	return cohort, nil
+}
+
+// newInviteCode returns a random code drawn uniformly from the invite code
+// alphabet.
+func newInviteCode() (string, error) {
+	code := make([]byte, 0, inviteCodeLength)
+	buf := make([]byte, inviteCodeLength)
+	for len(code) < inviteCodeLength {
+		if _, err := rand.Read(buf); err != nil {
+			return "", err
+		}
+		for _, b := range buf {
+			if int(b) >= inviteCodeByteLimit || len(code) == inviteCodeLength {
+				continue
+			}
+			code = append(code, inviteCodeAlphabet[int(b)%len(inviteCodeAlphabet)])
+		}
+	}
+	return string(code), nil
+}
+
+// assignmentCompletions matches each assignment to the first attempt of the
+// right type each member submitted after it was set. Attempts finished after
+// the due date count as late.
+func assignmentCompletions(assignments []models.CohortAssignment, members []models.CohortMember, attempts []models.CohortAttempt, now time.Time) []models.AssignmentCompletion {
+	completions := make([]models.AssignmentCompletion, 0, len(assignments))
+	for _, assignment := range assignments {
+		attemptType := assignmentKinds[assignment.Kind]
+		completion := models.AssignmentCompletion{
+			Assignment: assignment,
+			Members:    make([]models.AssignmentStatus, 0, len(members)),
+		}
+
+		for _, member := range members {
+			status := models.AssignmentStatus{UserID: member.UserID, Status: assignmentStatusPending}
+			if assignment.DueAt != nil && now.After(*assignment.DueAt) {
+				status.Status = assignmentStatusOverdue
+			}
+
+			for _, attempt := range attempts {
+				if attempt.UserID != member.UserID || attempt.AttemptType != attemptType || attempt.EndedAt.Before(assignment.CreatedAt) {
+					continue
+				}
+				attemptID := attempt.AttemptID
+				endedAt := attempt.EndedAt
+				percentage := float64(attempt.Score) / float64(attempt.MaxScore) * 100
+				status.AttemptID = &attemptID
+				status.CompletedAt = &endedAt
+				status.Percentage = &percentage
+				status.Status = assignmentStatusCompleted
+				if assignment.DueAt != nil && endedAt.After(*assignment.DueAt) {
+					status.Status = assignmentStatusLate
+				}
+				completion.Completed++
+				break
+			}
+
+			completion.Members = append(completion.Members, status)
+		}
+
+		completions = append(completions, completion)
+	}
+	return completions
+}
+
+// cohortDomainAverages averages member accuracy per domain so that one very
+// active learner does not dominate the cohort figure.
+func cohortDomainAverages(results []models.CohortDomainResult) []models.CohortDomainAverage {
+	byDomain := make(map[string]*models.CohortDomainAverage)
+	for _, result := range results {
+		if result.Answered == 0 {
+			continue
+		}
+		avg, ok := byDomain[result.Domain]
+		if !ok {
+			avg = &models.CohortDomainAverage{Domain: result.Domain}
+			byDomain[result.Domain] = avg
+		}
+		avg.Members++
+		avg.Answered += result.Answered
+		avg.AverageAccuracy += float64(result.Correct) / float64(result.Answered)
+	}
+
+	averages := make([]models.CohortDomainAverage, 0, len(byDomain))
+	for _, avg := range byDomain {
+		avg.AverageAccuracy /= float64(avg.Members)
+		averages = append(averages, *avg)
+	}
+	sort.Slice(averages, func(i, j int) bool { return averages[i].Domain < averages[j].Domain })
+	return averages
+}
+
+func atRiskLearners(members []models.CohortMember, attempts []models.CohortAttempt, completions []models.AssignmentCompletion, now time.Time, passMark float64) []models.AtRiskLearner {
+	byMember := make(map[uuid.UUID][]models.CohortAttempt)
+	for _, attempt := range attempts {
+		byMember[attempt.UserID] = append(byMember[attempt.UserID], attempt)
+	}
+
+	overdue := make(map[uuid.UUID]int)
+	for _, completion := range completions {
+		for _, status := range completion.Members {
+			if status.Status == assignmentStatusOverdue {
+				overdue[status.UserID]++
+			}
+		}
+	}
+
+	learners := make([]models.AtRiskLearner, 0)
+	for _, member := range members {
+		learner := models.AtRiskLearner{
+			UserID:  member.UserID,
+			Name:    member.Name,
+			Email:   member.Email,
+			Overdue: overdue[member.UserID],
+			Reasons: []string{},
+		}
+
+		memberAttempts := byMember[member.UserID]
+		if len(memberAttempts) > 0 {
+			recent := memberAttempts
+			if len(recent) > atRiskRecentAttempts {
+				recent = recent[len(recent)-atRiskRecentAttempts:]
+			}
+			sum := 0.0
+			for _, attempt := range recent {
+				sum += float64(attempt.Score) / float64(attempt.MaxScore) * 100
+			}
+			average := sum / float64(len(recent))
+			learner.RecentAverage = &average
+			if average < passMark*100 {
+				learner.Reasons = append(learner.Reasons, "recent average below pass mark")
+			}
+		}
+
+		lastActive := member.JoinedAt
+		if len(memberAttempts) > 0 {
+			lastActive = memberAttempts[len(memberAttempts)-1].EndedAt
+		}
+		if now.Sub(lastActive) > atRiskInactivity {
+			learner.Reasons = append(learner.Reasons, "no submitted attempts in the last 14 days")
+		}
+
+		if learner.Overdue > 0 {
+			learner.Reasons = append(learner.Reasons, "overdue assignments")
+		}
+
+		if len(learner.Reasons) > 0 {
+			learners = append(learners, learner)
+		}
+	}
+	return learners
+}
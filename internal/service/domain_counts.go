@@ -1,15 +1,29 @@
 package service
 
-import "capm-exam-system/internal/models"
+import (
+	"sort"
+	"time"
+
+	"capm-exam-system/internal/models"
+)
 
 type domainQuota struct {
 	domain string
 	count  int
 }
 
+// sectionSpec is one timed block of an exam. Sections partition the drawn
+// question list in order, so their counts must add up to the blueprint total.
+type sectionSpec struct {
+	questionCount int
+	timeLimit     time.Duration
+}
+
 type attemptBlueprint struct {
 	domainCounts []domainQuota
 	hardCount    int
+	sections     []sectionSpec
+	breakLimit   time.Duration
 }
 
 var examBlueprint = attemptBlueprint{
@@ -20,6 +34,11 @@ var examBlueprint = attemptBlueprint{
 		{domain: "Business Analysis", count: 35},
 	},
 	hardCount: 20,
+	sections: []sectionSpec{
+		{questionCount: 75, timeLimit: 90 * time.Minute},
+		{questionCount: 75, timeLimit: 90 * time.Minute},
+	},
+	breakLimit: 10 * time.Minute,
 }
 
 var pmpBlueprint = attemptBlueprint{
@@ -28,6 +47,12 @@ var pmpBlueprint = attemptBlueprint{
 		{domain: "Process", count: 75},
 		{domain: "Business Environment", count: 12},
 	},
+	sections: []sectionSpec{
+		{questionCount: 50, timeLimit: 64 * time.Minute},
+		{questionCount: 50, timeLimit: 64 * time.Minute},
+		{questionCount: 50, timeLimit: 64 * time.Minute},
+	},
+	breakLimit: 10 * time.Minute,
 }
 
 var quizBlueprint = attemptBlueprint{
@@ -40,20 +65,101 @@ var quizBlueprint = attemptBlueprint{
 	hardCount: 2,
 }
 
+// minDomainQuota is the fewest questions a scaled blueprint gives each
+// domain, as long as the exam has room for one per domain.
+const minDomainQuota = 1
+
 func blueprintForExam(examName string, questionCount int) attemptBlueprint {
 	switch examName {
 	case defaultExamName:
-		if questionCount <= 20 {
+		if questionCount == sumQuota(quizBlueprint) {
 			return quizBlueprint
 		}
-		return examBlueprint
+		return scaleBlueprint(examBlueprint, questionCount)
 	case pmpExamName:
-		return pmpBlueprint
+		return scaleBlueprint(pmpBlueprint, questionCount)
 	case hardExamName:
 		return attemptBlueprint{hardCount: questionCount}
 	default:
-		return attemptBlueprint{domainCounts: []domainQuota{{domain: "Project Management Fundamentals", count: questionCount}}}
+		return scaleBlueprint(examBlueprint, questionCount)
+	}
+}
+
+// scaleBlueprint resizes a blueprint to questionCount, keeping the domain
+// proportions. Every domain, hard questions included, first gets
+// minDomainQuota questions; the rest are shared in proportion to the
+// original counts with largest-remainder rounding, ties going to the domain
+// listed first. Sections and breaks only apply at the original length.
+func scaleBlueprint(base attemptBlueprint, questionCount int) attemptBlueprint {
+	if sumQuota(base) == questionCount {
+		return base
+	}
+
+	// The hard question quota is scaled as one more domain
+	weights := make([]int, 0, len(base.domainCounts)+1)
+	for _, dq := range base.domainCounts {
+		weights = append(weights, dq.count)
+	}
+	if base.hardCount > 0 {
+		weights = append(weights, base.hardCount)
+	}
+	counts := largestRemainder(weights, questionCount, minDomainQuota)
+
+	scaled := attemptBlueprint{domainCounts: make([]domainQuota, len(base.domainCounts))}
+	for i, dq := range base.domainCounts {
+		scaled.domainCounts[i] = domainQuota{domain: dq.domain, count: counts[i]}
+	}
+	if base.hardCount > 0 {
+		scaled.hardCount = counts[len(counts)-1]
+	}
+	return scaled
+}
+
+// largestRemainder splits total across weights: each share gets minimum
+// first when total allows it, then the remainder is divided in proportion to
+// the weights, rounding down and handing the leftover units to the largest
+// fractional parts.
+func largestRemainder(weights []int, total, minimum int) []int {
+	counts := make([]int, len(weights))
+	if len(weights) == 0 || total <= 0 {
+		return counts
+	}
+	if minimum*len(weights) > total {
+		minimum = 0
+	}
+
+	weightSum := 0
+	for i, w := range weights {
+		counts[i] = minimum
+		weightSum += w
+	}
+	remaining := total - minimum*len(weights)
+	if weightSum == 0 {
+		counts[0] += remaining
+		return counts
+	}
+
+	remainders := make([]int, len(weights))
+	assigned := 0
+	for i, w := range weights {
+		share := remaining * w
+		counts[i] += share / weightSum
+		remainders[i] = share % weightSum
+		assigned += share / weightSum
+	}
+
+	order := make([]int, len(weights))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return remainders[order[a]] > remainders[order[b]]
+	})
+	for i := 0; assigned < remaining; i++ {
+		counts[order[i%len(order)]]++
+		assigned++
 	}
+	return counts
 }
 
 func sumQuota(quota attemptBlueprint) int {
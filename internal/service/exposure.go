@@ -0,0 +1,101 @@
+package service
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"capm-exam-system/internal/models"
+	"capm-exam-system/internal/repository"
+)
+
+// exposureReportItems caps the items listed per exam form.
+const exposureReportItems = 50
+
+// applyExposure lowers the draw weight of questions the learner saw
+// recently and of questions served in more than the configured share of the
+// exam's recent attempts. It depends on the clock, so it only applies to the
+// draw of a new attempt, never to rebuilding an existing attempt's set.
+func (s *Service) applyExposure(ctx context.Context, attempt *models.Attempt, sel *repository.Selection) error {
+	cfg := s.config.Exposure
+	now := time.Now().UTC()
+
+	if cfg.RecentWeight < 1 && (cfg.RecentAttempts > 0 || cfg.RecentWindow > 0) {
+		since := now
+		if cfg.RecentWindow > 0 {
+			since = now.Add(-cfg.RecentWindow)
+		}
+		recent, err := s.repo.GetRecentQuestionIDs(ctx, attempt.UserID, attempt.ID, cfg.RecentAttempts, since)
+		if err != nil {
+			return err
+		}
+		for _, id := range recent {
+			sel.ScaleWeight(id, cfg.RecentWeight)
+		}
+	}
+
+	attempts, served, err := s.repo.GetExamExposure(ctx, attempt.ExamID, attempt.MaxScore, now.Add(-cfg.RateWindow))
+	if err != nil {
+		return err
+	}
+	if attempts == 0 || attempts < cfg.RateMinAttempts {
+		return nil
+	}
+	for id, count := range served {
+		if rate := float64(count) / float64(attempts); rate > cfg.MaxRate {
+			sel.ScaleWeight(id, cfg.MaxRate/rate)
+		}
+	}
+	return nil
+}
+
+// GetExposureReport lists the most served questions of every exam form
+// started within the exposure rate window.
+func (s *Service) GetExposureReport(ctx context.Context) (*models.ExposureReport, error) {
+	cfg := s.config.Exposure
+	now := time.Now().UTC()
+	since := now.Add(-cfg.RateWindow)
+
+	forms, err := s.repo.ListExamForms(ctx, since)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &models.ExposureReport{
+		GeneratedAt: now,
+		Since:       since,
+		MaxRate:     cfg.MaxRate,
+		Exams:       make([]models.ExamExposure, 0, len(forms)),
+	}
+	for _, form := range forms {
+		attempts, served, err := s.repo.GetExamExposure(ctx, form.ExamID, form.QuestionCount, since)
+		if err != nil {
+			return nil, err
+		}
+		form.Attempts = attempts
+		form.Items = make([]models.ItemExposure, 0, len(served))
+		for id, count := range served {
+			rate := 0.0
+			if attempts > 0 {
+				rate = float64(count) / float64(attempts)
+			}
+			form.Items = append(form.Items, models.ItemExposure{
+				QuestionID:  id,
+				Served:      count,
+				Rate:        rate,
+				OverExposed: rate > cfg.MaxRate && attempts >= cfg.RateMinAttempts,
+			})
+		}
+		sort.Slice(form.Items, func(i, j int) bool {
+			if form.Items[i].Served != form.Items[j].Served {
+				return form.Items[i].Served > form.Items[j].Served
+			}
+			return form.Items[i].QuestionID < form.Items[j].QuestionID
+		})
+		if len(form.Items) > exposureReportItems {
+			form.Items = form.Items[:exposureReportItems]
+		}
+		report.Exams = append(report.Exams, form)
+	}
+	return report, nil
+}
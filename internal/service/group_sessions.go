@@ -0,0 +1,417 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"time"
+
+	"capm-exam-system/internal/models"
+
+	"github.com/google/uuid"
+)
+
+const (
+	groupSessionWaiting = "waiting"
+	groupSessionRunning = "running"
+	groupSessionEnded   = "ended"
+
+	maxGroupSessionMinutes   = 300
+	maxGroupSessionExtension = 60
+)
+
+// groupSessionKind is the exam a group session runs and its default length.
+// Kinds are the assignable kinds of cohorts.
+type groupSessionKind struct {
+	examName    string
+	description string
+	// questions of zero means the configured hard drill length
+	questions      int
+	defaultMinutes int
+}
+
+var groupSessionKinds = map[string]groupSessionKind{
+	"quiz": {examName: defaultExamName, description: "150-question CAPM certification practice exam", questions: 15, defaultMinutes: 20},
+	"exam": {examName: defaultExamName, description: "150-question CAPM certification practice exam", questions: 150, defaultMinutes: 180},
+	"hard": {examName: hardExamName, description: "Advanced CAPM scenario drill", defaultMinutes: 30},
+	"pmp":  {examName: pmpExamName, description: "150-question PMP scenario exam", questions: 150, defaultMinutes: 230},
+}
+
+// CreateGroupSession opens a session participants can join with its code.
+// The clock starts when the instructor starts the session; a duration of
+// zero uses the kind's default.
+func (s *Service) CreateGroupSession(ctx context.Context, instructorID uuid.UUID, title, kind string, durationMinutes int) (*models.GroupSession, error) {
+	sessionKind, ok := groupSessionKinds[kind]
+	if !ok || durationMinutes < 0 || durationMinutes > maxGroupSessionMinutes {
+		return nil, ErrInvalidGroupSession
+	}
+	if durationMinutes == 0 {
+		durationMinutes = sessionKind.defaultMinutes
+	}
+	title = strings.TrimSpace(title)
+	if title == "" {
+		title = assignmentKinds[kind]
+	}
+
+	var session *models.GroupSession
+	for i := 0; session == nil; i++ {
+		if i == inviteCodeAttempts {
+			return nil, ErrInviteCodeExhausted
+		}
+		code, err := newInviteCode()
+		if err != nil {
+			return nil, err
+		}
+		session, err = s.repo.CreateGroupSession(ctx, instructorID, title, kind, code, durationMinutes*60)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	s.audit(ctx, &instructorID, "group_session.created", "group_session", session.ID.String(), nil, session)
+	return session, nil
+}
+
+// JoinGroupSession adds the user to the session that owns the join code. A
+// participant who joins a running session starts straight away, with the
+// time that is left.
+func (s *Service) JoinGroupSession(ctx context.Context, userID uuid.UUID, joinCode string) (*models.GroupSessionState, error) {
+	session, err := s.repo.GetGroupSessionByJoinCode(ctx, strings.ToUpper(strings.TrimSpace(joinCode)))
+	if err != nil {
+		return nil, err
+	}
+	if session == nil {
+		return nil, ErrJoinCodeInvalid
+	}
+	if session.Status == groupSessionEnded || (session.EndsAt != nil && !time.Now().Before(*session.EndsAt)) {
+		return nil, ErrGroupSessionEnded
+	}
+
+	if err := s.repo.AddGroupSessionParticipant(ctx, session.ID, userID); err != nil {
+		return nil, err
+	}
+	s.audit(ctx, &userID, "group_session.joined", "group_session", session.ID.String(), nil, map[string]interface{}{"user_id": userID})
+
+	return s.GetGroupSessionState(ctx, session.ID, userID)
+}
+
+// StartGroupSession starts the clock and an attempt for everyone who has
+// joined. A participant whose attempt could not be started gets one the
+// next time their event stream polls.
+func (s *Service) StartGroupSession(ctx context.Context, sessionID, instructorID uuid.UUID) (*models.GroupSession, error) {
+	if _, err := s.instructedGroupSession(ctx, sessionID, instructorID); err != nil {
+		return nil, err
+	}
+
+	session, err := s.repo.StartGroupSession(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	if session == nil {
+		return nil, ErrGroupSessionStarted
+	}
+
+	userIDs, err := s.repo.GetWaitingParticipantIDs(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	started := 0
+	for _, userID := range userIDs {
+		if _, err := s.startGroupSessionAttempt(ctx, session, userID); err != nil {
+			slog.ErrorContext(ctx, "failed to start group session attempt", "session_id", sessionID, "user_id", userID, "error", err)
+			continue
+		}
+		started++
+	}
+
+	s.audit(ctx, &instructorID, "group_session.started", "group_session", sessionID.String(), nil, map[string]interface{}{
+		"session":  session,
+		"attempts": started,
+	})
+	return session, nil
+}
+
+// ExtendGroupSession gives everyone in a running session more time.
+func (s *Service) ExtendGroupSession(ctx context.Context, sessionID, instructorID uuid.UUID, minutes int) (*models.GroupSession, error) {
+	if minutes < 1 || minutes > maxGroupSessionExtension {
+		return nil, ErrInvalidExtension
+	}
+	before, err := s.instructedGroupSession(ctx, sessionID, instructorID)
+	if err != nil {
+		return nil, err
+	}
+
+	session, err := s.repo.ExtendGroupSession(ctx, sessionID, minutes)
+	if err != nil {
+		return nil, err
+	}
+	if session == nil {
+		return nil, ErrGroupSessionNotRunning
+	}
+
+	s.audit(ctx, &instructorID, "group_session.extended", "group_session", sessionID.String(), before, session)
+	return session, nil
+}
+
+// EndGroupSession stops a session early. A session that has not started is
+// cancelled; a running one has its time cut to now, so every participant's
+// page submits, and the attempts still open after the grace period are
+// submitted with their recorded answers.
+func (s *Service) EndGroupSession(ctx context.Context, sessionID, instructorID uuid.UUID) (*models.GroupSession, error) {
+	before, err := s.instructedGroupSession(ctx, sessionID, instructorID)
+	if err != nil {
+		return nil, err
+	}
+
+	switch before.Status {
+	case groupSessionWaiting:
+		if _, err := s.repo.CloseGroupSession(ctx, sessionID); err != nil {
+			return nil, err
+		}
+	case groupSessionRunning:
+		if _, err := s.repo.CutGroupSession(ctx, sessionID); err != nil {
+			return nil, err
+		}
+	default:
+		return before, nil
+	}
+
+	session, err := s.repo.GetGroupSession(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	if session == nil {
+		return nil, ErrGroupSessionNotFound
+	}
+
+	s.audit(ctx, &instructorID, "group_session.ended", "group_session", sessionID.String(), before, session)
+	return session, nil
+}
+
+// GetGroupSessionState reports the session clock and the participant's
+// attempt, starting the attempt if the session runs without one.
+func (s *Service) GetGroupSessionState(ctx context.Context, sessionID, userID uuid.UUID) (*models.GroupSessionState, error) {
+	session, err := s.repo.GetGroupSession(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	if session == nil {
+		return nil, ErrGroupSessionNotFound
+	}
+	attemptID, joined, err := s.repo.GetGroupSessionParticipant(ctx, sessionID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !joined {
+		return nil, ErrGroupSessionForbidden
+	}
+
+	now := time.Now().UTC()
+	if attemptID == nil && session.Status == groupSessionRunning && session.EndsAt != nil && now.Before(*session.EndsAt) {
+		attemptID, err = s.startGroupSessionAttempt(ctx, session, userID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	state := &models.GroupSessionState{
+		Session:    *session,
+		ServerTime: now,
+		AttemptID:  attemptID,
+	}
+	state.Session.JoinCode = ""
+	if attemptID != nil {
+		attempt, err := s.repo.GetAttempt(ctx, *attemptID)
+		if err != nil {
+			return nil, err
+		}
+		state.Submitted = attempt != nil && attempt.EndedAt != nil
+	}
+	return state, nil
+}
+
+// GetGroupSessionProgress reports every participant's progress to the
+// instructor.
+func (s *Service) GetGroupSessionProgress(ctx context.Context, sessionID, instructorID uuid.UUID) (*models.GroupSessionProgress, error) {
+	session, err := s.instructedGroupSession(ctx, sessionID, instructorID)
+	if err != nil {
+		return nil, err
+	}
+
+	participants, err := s.repo.GetGroupSessionParticipants(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	progress := &models.GroupSessionProgress{
+		Session:      *session,
+		ServerTime:   now,
+		Participants: make([]models.GroupSessionParticipant, 0, len(participants)),
+	}
+	if session.Status == groupSessionRunning && session.EndsAt != nil {
+		progress.TimeLeftSeconds = secondsUntil(*session.EndsAt, now)
+	}
+	for _, participant := range participants {
+		if participant.Submitted {
+			progress.Submitted++
+		} else if participant.EndsAt != nil {
+			participant.TimeLeftSeconds = secondsUntil(*participant.EndsAt, now)
+		}
+		progress.Participants = append(progress.Participants, participant)
+	}
+	return progress, nil
+}
+
+// RunGroupSessions ends the sessions whose time is up until ctx is
+// cancelled, checking every interval. Participants' pages submit on their
+// own at the deadline; attempts still open once the grace period has passed
+// are submitted with the answers recorded for them.
+func (s *Service) RunGroupSessions(ctx context.Context, interval time.Duration) {
+	ctx = WithActor(ctx, auditActorGroupSessions)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := s.FinishDueGroupSessions(ctx); err != nil && ctx.Err() == nil {
+			slog.ErrorContext(ctx, "failed to finish group sessions", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// FinishDueGroupSessions submits the open attempts of every session whose
+// time ran out more than the grace period ago and marks it ended. A session
+// with an attempt that could not be submitted is retried on the next run.
+func (s *Service) FinishDueGroupSessions(ctx context.Context) error {
+	sessions, err := s.repo.GetDueGroupSessions(ctx, time.Now().UTC().Add(-sectionSubmitGrace))
+	if err != nil {
+		return err
+	}
+
+	for _, session := range sessions {
+		attemptIDs, err := s.repo.GetOpenGroupSessionAttemptIDs(ctx, session.ID)
+		if err != nil {
+			return err
+		}
+
+		failed := false
+		for _, attemptID := range attemptIDs {
+			if err := s.submitRecordedSelections(ctx, attemptID); err != nil {
+				if ctx.Err() != nil {
+					return ctx.Err()
+				}
+				slog.ErrorContext(ctx, "failed to submit group session attempt", "session_id", session.ID, "attempt_id", attemptID, "error", err)
+				failed = true
+			}
+		}
+		if failed {
+			continue
+		}
+
+		closed, err := s.repo.CloseGroupSession(ctx, session.ID)
+		if err != nil {
+			return err
+		}
+		if closed {
+			s.audit(ctx, nil, "group_session.ended", "group_session", session.ID.String(), session, map[string]interface{}{
+				"status":              groupSessionEnded,
+				"submitted_by_server": len(attemptIDs),
+			})
+		}
+	}
+	return nil
+}
+
+// submitRecordedSelections submits an attempt with the latest selection
+// recorded for each question.
+func (s *Service) submitRecordedSelections(ctx context.Context, attemptID uuid.UUID) error {
+	selections, err := s.repo.GetAnswerSelections(ctx, attemptID)
+	if err != nil {
+		return err
+	}
+
+	submission := models.ExamSubmission{Answers: recordedAnswers(selections, time.Now().UTC())}
+
+	// The participant's page may submit at the same moment
+	if _, err := s.SubmitExam(ctx, attemptID, submission); err != nil && !errors.Is(err, ErrAttemptAlreadyClosed) {
+		return err
+	}
+	return nil
+}
+
+// startGroupSessionAttempt starts the participant's attempt with the time
+// left in the session and returns its ID. When a concurrent request started
+// one first, that one is kept.
+func (s *Service) startGroupSessionAttempt(ctx context.Context, session *models.GroupSession, userID uuid.UUID) (*uuid.UUID, error) {
+	if session.EndsAt == nil {
+		return nil, ErrGroupSessionNotRunning
+	}
+	remaining := time.Until(*session.EndsAt)
+	if remaining <= 0 {
+		return nil, ErrGroupSessionEnded
+	}
+
+	sessionKind := groupSessionKinds[session.Kind]
+	exam, err := s.repo.GetExamByName(ctx, sessionKind.examName)
+	if err != nil {
+		return nil, err
+	}
+	if exam == nil {
+		exam, err = s.repo.CreateExam(ctx, sessionKind.examName, sessionKind.description)
+		if err != nil {
+			return nil, err
+		}
+	}
+	questionCount := sessionKind.questions
+	if questionCount == 0 {
+		questionCount = s.config.HardDrillLength
+	}
+
+	attempt, err := s.createAttempt(ctx, userID, exam, questionCount, attemptSpec{timeLimit: remaining})
+	if err != nil {
+		return nil, err
+	}
+
+	linked, err := s.repo.LinkGroupSessionAttempt(ctx, session.ID, userID, attempt.ID)
+	if err != nil {
+		return nil, err
+	}
+	if !linked {
+		if err := s.repo.DeleteAttempt(ctx, attempt.ID); err != nil {
+			slog.ErrorContext(ctx, "failed to remove duplicate group session attempt", "attempt_id", attempt.ID, "error", err)
+		}
+		attemptID, _, err := s.repo.GetGroupSessionParticipant(ctx, session.ID, userID)
+		return attemptID, err
+	}
+	return &attempt.ID, nil
+}
+
+func (s *Service) instructedGroupSession(ctx context.Context, sessionID, instructorID uuid.UUID) (*models.GroupSession, error) {
+	session, err := s.repo.GetGroupSession(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	if session == nil {
+		return nil, ErrGroupSessionNotFound
+	}
+	if session.InstructorID != instructorID {
+		return nil, ErrGroupSessionForbidden
+	}
+	return session, nil
+}
+
+// secondsUntil rounds the time left before deadline up to whole seconds.
+func secondsUntil(deadline, now time.Time) int {
+	remaining := deadline.Sub(now)
+	if remaining <= 0 {
+		return 0
+	}
+	return int((remaining + time.Second - 1) / time.Second)
+}
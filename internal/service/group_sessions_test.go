@@ -0,0 +1,44 @@
+package service
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"capm-exam-system/internal/models"
+)
+
+func TestSubmitAfterCut(t *testing.T) {
+	started := time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+	limit := 3600
+	attempt := &models.Attempt{StartedAt: started, TimeLimitSeconds: &limit}
+
+	selections := []models.AnswerSelection{
+		{QuestionID: 1, ChoiceIDs: []int{11}, SelectedAt: started.Add(2 * time.Minute)},
+		{QuestionID: 2, ChoiceIDs: []int{21}, SelectedAt: started.Add(4 * time.Minute)},
+		{QuestionID: 1, ChoiceIDs: []int{12}, SelectedAt: started.Add(9 * time.Minute)},
+		{QuestionID: 2, ChoiceIDs: []int{}, SelectedAt: started.Add(10*time.Minute + 20*time.Second)},
+		{QuestionID: 3, ChoiceIDs: []int{31}, SelectedAt: started.Add(12 * time.Minute)},
+	}
+
+	submitAt := started.Add(15 * time.Minute)
+	if attemptExpired(attempt, submitAt) {
+		t.Fatalf("attempt expired at %v before the cut", submitAt)
+	}
+
+	// The instructor cuts the session ten minutes in
+	limit = 600
+	if !attemptExpired(attempt, submitAt) {
+		t.Fatalf("attempt still open at %v after the cut", submitAt)
+	}
+	if attemptExpired(attempt, started.Add(10*time.Minute+sectionSubmitGrace)) {
+		t.Fatal("attempt expired within the grace period")
+	}
+
+	deadline, _ := attemptDeadline(attempt)
+	got := recordedAnswers(selections, deadline.Add(sectionSubmitGrace))
+	want := []models.AnswerSubmission{{QuestionID: 1, ChoiceIDs: []int{12}}}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("graded answers = %v, want %v", got, want)
+	}
+}
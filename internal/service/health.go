@@ -0,0 +1,72 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"capm-exam-system/internal/database"
+	"capm-exam-system/internal/models"
+)
+
+const (
+	healthStatusOK          = "ok"
+	healthStatusUnavailable = "unavailable"
+
+	// inProgressWindow bounds which open attempts count as in progress;
+	// older ones were abandoned.
+	inProgressWindow = 24 * time.Hour
+)
+
+// CheckHealth reports whether the database answers.
+func (s *Service) CheckHealth(ctx context.Context) *models.HealthReport {
+	report := &models.HealthReport{Status: healthStatusOK, Checks: map[string]string{}}
+	s.checkDatabase(ctx, report)
+	return report
+}
+
+// CheckReadiness additionally requires the database schema to be migrated
+// to the version this build expects.
+func (s *Service) CheckReadiness(ctx context.Context) *models.HealthReport {
+	report := &models.HealthReport{
+		Status:                healthStatusOK,
+		Checks:                map[string]string{},
+		ExpectedSchemaVersion: database.SchemaVersion,
+	}
+	if !s.checkDatabase(ctx, report) {
+		return report
+	}
+
+	version, err := s.repo.GetSchemaVersion(ctx)
+	if err != nil {
+		slog.ErrorContext(ctx, "readiness: schema version check failed", "error", err)
+		report.Status = healthStatusUnavailable
+		report.Checks["schema"] = "unknown"
+		return report
+	}
+	report.SchemaVersion = version
+	if version < database.SchemaVersion {
+		report.Status = healthStatusUnavailable
+		report.Checks["schema"] = "outdated"
+		return report
+	}
+	report.Checks["schema"] = healthStatusOK
+	return report
+}
+
+func (s *Service) checkDatabase(ctx context.Context, report *models.HealthReport) bool {
+	if err := s.repo.Ping(ctx); err != nil {
+		slog.ErrorContext(ctx, "health: database ping failed", "error", err)
+		report.Status = healthStatusUnavailable
+		report.Checks["database"] = "unreachable"
+		return false
+	}
+	report.Checks["database"] = healthStatusOK
+	return true
+}
+
+// CountAttemptsInProgress counts open attempts started within the last day,
+// by attempt type.
+func (s *Service) CountAttemptsInProgress(ctx context.Context) (map[string]int, error) {
+	return s.repo.CountOpenAttempts(ctx, inProgressWindow)
+}
@@ -0,0 +1,212 @@
+package service
+
+import (
+	"context"
+	"math"
+	"sort"
+	"time"
+
+	"capm-exam-system/internal/models"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// itemMinResponses is the number of answers a question needs before its
+	// flags and discrimination index are reported.
+	itemMinResponses = 10
+	// discriminationGroupShare is the classic 27% upper and lower groups.
+	discriminationGroupShare = 0.27
+
+	itemFlagLikelyWrongKey = "likely_wrong_key"
+	itemFlagNobodyMisses   = "nobody_misses"
+)
+
+// ItemAnalysisFilter narrows the item analysis report.
+type ItemAnalysisFilter struct {
+	Domain      string
+	FlaggedOnly bool
+}
+
+// GetItemAnalysis reports how every served question performs across
+// submitted attempts.
+func (s *Service) GetItemAnalysis(ctx context.Context, filter ItemAnalysisFilter) (*models.ItemAnalysisReport, error) {
+	responses, err := s.repo.GetItemResponses(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	questionIDs := make([]int, 0)
+	seen := make(map[int]struct{})
+	for _, resp := range responses {
+		if _, ok := seen[resp.QuestionID]; !ok {
+			seen[resp.QuestionID] = struct{}{}
+			questionIDs = append(questionIDs, resp.QuestionID)
+		}
+	}
+
+	questions, err := s.repo.GetQuestionsWithChoices(ctx, questionIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	report := buildItemAnalysis(questions, responses)
+	if filter.Domain != "" || filter.FlaggedOnly {
+		items := make([]models.ItemAnalysis, 0, len(report.Items))
+		for _, item := range report.Items {
+			if filter.Domain != "" && item.Domain != filter.Domain {
+				continue
+			}
+			if filter.FlaggedOnly && len(item.Flags) == 0 {
+				continue
+			}
+			items = append(items, item)
+		}
+		report.Items = items
+	}
+
+	return report, nil
+}
+
+func buildItemAnalysis(questions []models.QuestionWithChoices, responses []models.ItemResponse) *models.ItemAnalysisReport {
+	byQuestion := make(map[int][]models.ItemResponse)
+	attempts := make(map[uuid.UUID]struct{})
+	for _, resp := range responses {
+		byQuestion[resp.QuestionID] = append(byQuestion[resp.QuestionID], resp)
+		attempts[resp.AttemptID] = struct{}{}
+	}
+
+	sort.Slice(questions, func(i, j int) bool { return questions[i].ID < questions[j].ID })
+
+	items := make([]models.ItemAnalysis, 0, len(questions))
+	for _, question := range questions {
+		items = append(items, analyseItem(question, byQuestion[question.ID]))
+	}
+
+	return &models.ItemAnalysisReport{
+		GeneratedAt: time.Now().UTC(),
+		Attempts:    len(attempts),
+		Items:       items,
+	}
+}
+
+func analyseItem(question models.QuestionWithChoices, responses []models.ItemResponse) models.ItemAnalysis {
+	item := models.ItemAnalysis{
+		QuestionID:    question.ID,
+		Domain:        question.Domain,
+		Prompt:        question.Prompt,
+		IsMultiSelect: question.IsMultiSelect,
+		Served:        len(responses),
+		Options:       make([]models.OptionStat, 0, len(question.Choices)),
+		Flags:         []string{},
+	}
+
+	chosen := make(map[int]int)
+	seconds := make([]float64, 0, len(responses))
+	for _, resp := range responses {
+		if len(resp.ChoiceIDs) > 0 {
+			item.Answered++
+		}
+		if resp.IsCorrect {
+			item.Correct++
+		}
+		for _, id := range resp.ChoiceIDs {
+			chosen[id]++
+		}
+		if resp.DwellMs != nil {
+			seconds = append(seconds, float64(*resp.DwellMs)/1000)
+		}
+	}
+
+	if item.Served > 0 {
+		item.PercentCorrect = float64(item.Correct) / float64(item.Served) * 100
+	}
+
+	for _, choice := range question.Choices {
+		option := models.OptionStat{
+			ChoiceID:  choice.ID,
+			Label:     choice.Label,
+			Text:      choice.Text,
+			IsCorrect: choice.IsCorrect,
+			Chosen:    chosen[choice.ID],
+		}
+		if item.Answered > 0 {
+			option.Share = float64(option.Chosen) / float64(item.Answered)
+		}
+		item.Options = append(item.Options, option)
+	}
+
+	if len(seconds) > 0 {
+		sort.Float64s(seconds)
+		sum := 0.0
+		for _, v := range seconds {
+			sum += v
+		}
+		average := sum / float64(len(seconds))
+		median := seconds[len(seconds)/2]
+		if len(seconds)%2 == 0 {
+			median = (seconds[len(seconds)/2-1] + seconds[len(seconds)/2]) / 2
+		}
+		item.AverageSeconds = &average
+		item.MedianSeconds = &median
+	}
+
+	item.Discrimination = discriminationIndex(responses)
+
+	if item.Answered >= itemMinResponses {
+		if distractorBeatsKey(item.Options) {
+			item.Flags = append(item.Flags, itemFlagLikelyWrongKey)
+		}
+		if item.Correct == item.Served {
+			item.Flags = append(item.Flags, itemFlagNobodyMisses)
+		}
+	}
+
+	return item
+}
+
+// discriminationIndex is the share of the top 27% of attempts (by total
+// score) that got the question right minus the share of the bottom 27%.
+func discriminationIndex(responses []models.ItemResponse) *float64 {
+	if len(responses) < itemMinResponses {
+		return nil
+	}
+
+	sorted := append([]models.ItemResponse(nil), responses...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].AttemptScore > sorted[j].AttemptScore })
+
+	group := int(math.Round(float64(len(sorted)) * discriminationGroupShare))
+	if group < 1 {
+		group = 1
+	}
+
+	upper, lower := 0, 0
+	for i := 0; i < group; i++ {
+		if sorted[i].IsCorrect {
+			upper++
+		}
+		if sorted[len(sorted)-1-i].IsCorrect {
+			lower++
+		}
+	}
+
+	index := float64(upper-lower) / float64(group)
+	return &index
+}
+
+// distractorBeatsKey reports whether some wrong option was picked more often
+// than the least picked correct option.
+func distractorBeatsKey(options []models.OptionStat) bool {
+	keyMin := -1
+	distractorMax := 0
+	for _, option := range options {
+		if option.IsCorrect {
+			if keyMin < 0 || option.Chosen < keyMin {
+				keyMin = option.Chosen
+			}
+		} else if option.Chosen > distractorMax {
+			distractorMax = option.Chosen
+		}
+	}
+	return keyMin >= 0 && distractorMax > keyMin
+}
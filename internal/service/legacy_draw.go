@@ -0,0 +1,98 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+
+	"capm-exam-system/internal/models"
+)
+
+// legacyQuestionIDs rebuilds the question set of an attempt started before
+// sets were stored. It replays the draw those attempts were made with, domain
+// by domain from the seed with popularity weights, and must keep doing so:
+// any other sampler would show and grade questions the learner never saw.
+func (s *Service) legacyQuestionIDs(ctx context.Context, attempt *models.Attempt, exam *models.Exam) ([]int, error) {
+	blueprint := legacyBlueprint(exam.Name, attempt.MaxScore)
+
+	if exam.Name == hardExamName {
+		return s.repo.GetLegacyQuestionsByDomain(ctx, attempt.MaxScore, attempt.Seed, hardDomainName, nil)
+	}
+
+	if sumQuota(blueprint) != attempt.MaxScore {
+		return nil, fmt.Errorf("allocator mismatch: expected %d, got quota %d", attempt.MaxScore, sumQuota(blueprint))
+	}
+
+	selected := make([]int, 0, attempt.MaxScore)
+	selectedSet := make(map[int]struct{}, attempt.MaxScore)
+	seed := attempt.Seed
+
+	// Hard questions were drawn first; each domain got the next seed
+	if blueprint.hardCount > 0 {
+		hardIDs, err := s.repo.GetLegacyQuestionsByDomain(ctx, blueprint.hardCount, seed, hardDomainName, nil)
+		if err != nil {
+			return nil, err
+		}
+		for _, id := range hardIDs {
+			if _, exists := selectedSet[id]; exists {
+				continue
+			}
+			selected = append(selected, id)
+			selectedSet[id] = struct{}{}
+		}
+		seed++
+	}
+
+	for _, dq := range blueprint.domainCounts {
+		if dq.count == 0 || dq.domain == hardDomainName {
+			continue
+		}
+
+		exclude := make([]int, 0, len(selectedSet))
+		for id := range selectedSet {
+			exclude = append(exclude, id)
+		}
+
+		domainIDs, err := s.repo.GetLegacyQuestionsByDomain(ctx, dq.count, seed, dq.domain, exclude)
+		if err != nil {
+			return nil, err
+		}
+		for _, id := range domainIDs {
+			if _, exists := selectedSet[id]; exists {
+				continue
+			}
+			selected = append(selected, id)
+			selectedSet[id] = struct{}{}
+		}
+		seed++
+	}
+
+	if len(selected) != attempt.MaxScore {
+		return nil, fmt.Errorf("selected %d unique questions, expected %d", len(selected), attempt.MaxScore)
+	}
+
+	rng := rand.New(rand.NewSource(attempt.Seed))
+	rng.Shuffle(len(selected), func(i, j int) {
+		selected[i], selected[j] = selected[j], selected[i]
+	})
+
+	return selected, nil
+}
+
+// legacyBlueprint is the blueprint an attempt started before question sets
+// were stored was drawn with. It predates custom lengths.
+func legacyBlueprint(examName string, questionCount int) attemptBlueprint {
+	switch examName {
+	case defaultExamName:
+		if questionCount <= 20 {
+			return quizBlueprint
+		}
+		return examBlueprint
+	case pmpExamName:
+		return pmpBlueprint
+	case hardExamName:
+		return attemptBlueprint{hardCount: questionCount}
+	default:
+		return attemptBlueprint{domainCounts: []domainQuota{{domain: "Project Management Fundamentals", count: questionCount}}}
+	}
+}
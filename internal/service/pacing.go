@@ -0,0 +1,384 @@
+package service
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"capm-exam-system/internal/models"
+
+	"github.com/google/uuid"
+)
+
+const (
+	eventTypeView   = "view"
+	eventTypeLeave  = "leave"
+	eventTypeAnswer = "answer"
+
+	maxEventsPerBatch = 500
+	maxEventDwell     = 2 * time.Hour
+
+	// defaultSecondsPerQuestion is the CAPM pace (3 hours for 150 questions),
+	// used for attempts whose blueprint has no timed sections.
+	defaultSecondsPerQuestion = 72.0
+	slowestQuestionCount      = 5
+	// paceTolerance is how far from the expected time a quarter may be and
+	// still count as on pace.
+	paceTolerance = 0.1
+)
+
+// RecordAttemptEvents stores a batch of per-question events for an open
+// attempt. Events for questions outside the attempt or of unknown types are
+// dropped; timestamps are clamped to the attempt's lifetime, between its
+// start and now, and missing ones are set to now.
+func (s *Service) RecordAttemptEvents(ctx context.Context, attemptID uuid.UUID, events []models.AttemptEvent) error {
+	if len(events) > maxEventsPerBatch {
+		return ErrEventBatchTooLarge
+	}
+
+	attempt, err := s.repo.GetAttempt(ctx, attemptID)
+	if err != nil {
+		return err
+	}
+	if attempt == nil {
+		return ErrAttemptNotFound
+	}
+	if attempt.EndedAt != nil {
+		return ErrAttemptAlreadyClosed
+	}
+	if attemptExpired(attempt, time.Now()) {
+		return ErrTimeExpired
+	}
+
+	exam, err := s.repo.GetExamByID(ctx, attempt.ExamID)
+	if err != nil {
+		return err
+	}
+	if exam == nil {
+		return ErrExamNotFound
+	}
+
+	questionIDs, err := s.pickQuestionIDs(ctx, attempt, exam)
+	if err != nil {
+		return err
+	}
+	inAttempt := make(map[int]struct{}, len(questionIDs))
+	for _, id := range questionIDs {
+		inAttempt[id] = struct{}{}
+	}
+
+	// Selections may only change while the question's section is open
+	answerable := inAttempt
+	if blueprint := blueprintForExam(exam.Name, attempt.MaxScore); isSectioned(attempt, blueprint) {
+		answerable = make(map[int]struct{})
+		if attempt.SectionStatus == sectionStatusOpen {
+			start, end := sectionBounds(blueprint, attempt.CurrentSection)
+			for _, id := range questionIDs[start:end] {
+				answerable[id] = struct{}{}
+			}
+		}
+	}
+
+	now := time.Now().UTC()
+	accepted := make([]models.AttemptEvent, 0, len(events))
+	selections := make([]models.AnswerSelection, 0)
+	for _, event := range events {
+		if _, ok := inAttempt[event.QuestionID]; !ok {
+			continue
+		}
+
+		switch {
+		case event.OccurredAt.IsZero() || event.OccurredAt.After(now):
+			event.OccurredAt = now
+		case event.OccurredAt.Before(attempt.StartedAt):
+			event.OccurredAt = attempt.StartedAt
+		}
+		event.OccurredAt = event.OccurredAt.UTC()
+
+		switch event.Type {
+		case eventTypeView:
+			event.DwellMs = 0
+		case eventTypeLeave:
+			if event.DwellMs < 0 {
+				event.DwellMs = 0
+			}
+			if event.DwellMs > int(maxEventDwell.Milliseconds()) {
+				event.DwellMs = int(maxEventDwell.Milliseconds())
+			}
+		case eventTypeAnswer:
+			if _, ok := answerable[event.QuestionID]; !ok {
+				continue
+			}
+			choiceIDs := event.ChoiceIDs
+			if choiceIDs == nil {
+				choiceIDs = []int{}
+			}
+			selections = append(selections, models.AnswerSelection{
+				AttemptID:  attemptID,
+				QuestionID: event.QuestionID,
+				ChoiceIDs:  choiceIDs,
+				SelectedAt: event.OccurredAt,
+			})
+			continue
+		default:
+			continue
+		}
+		event.ChoiceIDs = nil
+
+		accepted = append(accepted, event)
+	}
+
+	if err := s.repo.CreateAttemptEvents(ctx, attemptID, accepted); err != nil {
+		return err
+	}
+	if err := s.repo.CreateAnswerSelections(ctx, selections); err != nil {
+		return err
+	}
+
+	// View and dwell events are telemetry; only answer changes are audited.
+	if len(selections) > 0 {
+		s.audit(ctx, &attempt.UserID, "attempt.answers_selected", "attempt", attemptID.String(), nil,
+			map[string]interface{}{"selections": selections})
+	}
+	return nil
+}
+
+// recordedAnswers returns the latest selection recorded for each question up
+// to until, in the order the questions were first answered. Questions whose
+// latest selection is empty are left out.
+func recordedAnswers(selections []models.AnswerSelection, until time.Time) []models.AnswerSubmission {
+	latest := make(map[int][]int)
+	order := make([]int, 0, len(selections))
+	for _, selection := range selections {
+		if selection.SelectedAt.After(until) {
+			continue
+		}
+		if _, seen := latest[selection.QuestionID]; !seen {
+			order = append(order, selection.QuestionID)
+		}
+		latest[selection.QuestionID] = selection.ChoiceIDs
+	}
+
+	answers := make([]models.AnswerSubmission, 0, len(order))
+	for _, questionID := range order {
+		if len(latest[questionID]) > 0 {
+			answers = append(answers, models.AnswerSubmission{QuestionID: questionID, ChoiceIDs: latest[questionID]})
+		}
+	}
+	return answers
+}
+
+// missingSelections returns the submitted answers that the selection history
+// does not already end with.
+func (s *Service) missingSelections(ctx context.Context, attemptID uuid.UUID, answersByQuestion map[int][]int) ([]models.AnswerSelection, error) {
+	if len(answersByQuestion) == 0 {
+		return nil, nil
+	}
+
+	history, err := s.repo.GetAnswerSelections(ctx, attemptID)
+	if err != nil {
+		return nil, err
+	}
+
+	last := make(map[int][]int, len(history))
+	for _, sel := range history {
+		last[sel.QuestionID] = sel.ChoiceIDs
+	}
+
+	now := time.Now().UTC()
+	missing := make([]models.AnswerSelection, 0)
+	for qID, choiceIDs := range answersByQuestion {
+		if prev, ok := last[qID]; ok && sameChoices(prev, choiceIDs) {
+			continue
+		}
+		missing = append(missing, models.AnswerSelection{
+			AttemptID:  attemptID,
+			QuestionID: qID,
+			ChoiceIDs:  choiceIDs,
+			SelectedAt: now,
+		})
+	}
+
+	return missing, nil
+}
+
+func sameChoices(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	set := make(map[int]struct{}, len(a))
+	for _, id := range a {
+		set[id] = struct{}{}
+	}
+	for _, id := range b {
+		if _, ok := set[id]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// attachPacing adds pacing analytics to a graded result when the attempt has
+// recorded events.
+func (s *Service) attachPacing(ctx context.Context, result *models.ExamResult, blueprint attemptBlueprint) error {
+	events, err := s.repo.GetAttemptEvents(ctx, result.AttemptID)
+	if err != nil {
+		return err
+	}
+	if len(events) == 0 {
+		return nil
+	}
+
+	selections, err := s.repo.GetAnswerSelections(ctx, result.AttemptID)
+	if err != nil {
+		return err
+	}
+
+	result.Pacing = buildPacing(result.Results, events, selectionHistory(selections), expectedSecondsPerQuestion(blueprint, result.MaxScore))
+	return nil
+}
+
+// selectionHistory groups selections by question, oldest first.
+func selectionHistory(selections []models.AnswerSelection) map[int][][]int {
+	history := make(map[int][][]int)
+	for _, sel := range selections {
+		history[sel.QuestionID] = append(history[sel.QuestionID], sel.ChoiceIDs)
+	}
+	return history
+}
+
+func expectedSecondsPerQuestion(blueprint attemptBlueprint, questionCount int) float64 {
+	if !hasSections(blueprint, questionCount) {
+		return defaultSecondsPerQuestion
+	}
+
+	var total time.Duration
+	for _, section := range blueprint.sections {
+		if section.timeLimit <= 0 {
+			return defaultSecondsPerQuestion
+		}
+		total += section.timeLimit
+	}
+	return total.Seconds() / float64(questionCount)
+}
+
+func buildPacing(results []models.QuestionResult, events []models.AttemptEvent, selections map[int][][]int, secondsPerQuestion float64) *models.PacingAnalytics {
+	dwell := make(map[int]float64)
+	for _, event := range events {
+		if event.Type == eventTypeLeave {
+			dwell[event.QuestionID] += float64(event.DwellMs) / 1000
+		}
+	}
+
+	pacing := &models.PacingAnalytics{
+		ExpectedSecondsPerQuestion: secondsPerQuestion,
+		TimePerDomain:              []models.DomainTime{},
+		SlowestQuestions:           []models.QuestionTime{},
+		Quarters:                   []models.QuarterPace{},
+		RightToWrong:               []models.AnswerChange{},
+	}
+
+	domainIndex := make(map[string]int)
+	timings := make([]models.QuestionTime, 0, len(results))
+	for i, r := range results {
+		seconds := dwell[r.Question.ID]
+		pacing.TotalSeconds += seconds
+
+		idx, ok := domainIndex[r.Question.Domain]
+		if !ok {
+			idx = len(pacing.TimePerDomain)
+			domainIndex[r.Question.Domain] = idx
+			pacing.TimePerDomain = append(pacing.TimePerDomain, models.DomainTime{Domain: r.Question.Domain})
+		}
+		pacing.TimePerDomain[idx].Questions++
+		pacing.TimePerDomain[idx].TotalSeconds += seconds
+
+		timings = append(timings, models.QuestionTime{
+			QuestionID: r.Question.ID,
+			Position:   i + 1,
+			Domain:     r.Question.Domain,
+			Seconds:    seconds,
+			IsCorrect:  r.IsCorrect,
+		})
+
+		if change, ok := rightToWrongChange(r, selections[r.Question.ID]); ok {
+			change.Position = i + 1
+			pacing.RightToWrong = append(pacing.RightToWrong, change)
+		}
+	}
+
+	for i := range pacing.TimePerDomain {
+		if pacing.TimePerDomain[i].Questions > 0 {
+			pacing.TimePerDomain[i].AverageSeconds = pacing.TimePerDomain[i].TotalSeconds / float64(pacing.TimePerDomain[i].Questions)
+		}
+	}
+
+	sorted := append([]models.QuestionTime(nil), timings...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Seconds > sorted[j].Seconds })
+	for _, t := range sorted {
+		if len(pacing.SlowestQuestions) == slowestQuestionCount || t.Seconds == 0 {
+			break
+		}
+		pacing.SlowestQuestions = append(pacing.SlowestQuestions, t)
+	}
+
+	pacing.Quarters = quarterPaces(timings, secondsPerQuestion)
+
+	return pacing
+}
+
+// quarterPaces compares the cumulative time spent on the questions of each
+// quarter (and everything before it) with the time allowed for them.
+func quarterPaces(timings []models.QuestionTime, secondsPerQuestion float64) []models.QuarterPace {
+	quarters := make([]models.QuarterPace, 0, 4)
+	if len(timings) == 0 {
+		return quarters
+	}
+
+	elapsed := 0.0
+	position := 0
+	for q := 1; q <= 4; q++ {
+		through := (len(timings)*q + 3) / 4
+		for ; position < through; position++ {
+			elapsed += timings[position].Seconds
+		}
+
+		expected := float64(through) * secondsPerQuestion
+		status := "on_pace"
+		switch {
+		case elapsed < expected*(1-paceTolerance):
+			status = "ahead"
+		case elapsed > expected*(1+paceTolerance):
+			status = "behind"
+		}
+
+		quarters = append(quarters, models.QuarterPace{
+			Quarter:         q,
+			ThroughPosition: through,
+			ElapsedSeconds:  elapsed,
+			ExpectedSeconds: expected,
+			Status:          status,
+		})
+	}
+	return quarters
+}
+
+// rightToWrongChange reports the last correct selection a candidate abandoned
+// when their final answer to the question is wrong.
+func rightToWrongChange(result models.QuestionResult, history [][]int) (models.AnswerChange, bool) {
+	if result.IsCorrect || len(history) < 2 {
+		return models.AnswerChange{}, false
+	}
+
+	for i := len(history) - 2; i >= 0; i-- {
+		if _, correct := gradeSelection(result.Question, history[i]); correct {
+			return models.AnswerChange{
+				QuestionID:    result.Question.ID,
+				Domain:        result.Question.Domain,
+				FromChoiceIDs: history[i],
+				ToChoiceIDs:   result.UserChoiceIDs,
+			}, true
+		}
+	}
+	return models.AnswerChange{}, false
+}
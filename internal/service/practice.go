@@ -0,0 +1,380 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"strings"
+	"time"
+
+	"capm-exam-system/internal/models"
+	"capm-exam-system/internal/repository"
+	"capm-exam-system/internal/tracing"
+
+	"github.com/google/uuid"
+)
+
+const (
+	practiceExamName = "Custom Practice Quiz"
+
+	maxPracticeQuestions = 100
+	maxPracticeMinutes   = 240
+
+	// Difficulty bands by share of correct responses, once a question has
+	// itemMinResponses of them.
+	practiceEasyRate = 0.75
+	practiceHardRate = 0.45
+
+	feedbackEnd       = "end"
+	feedbackImmediate = "immediate"
+)
+
+// ListPracticeDomains lists the domains a practice quiz can draw from, drill
+// domains included.
+func (s *Service) ListPracticeDomains(ctx context.Context) ([]models.PracticeDomain, error) {
+	domains, err := s.repo.ListPracticeDomains(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if domains == nil {
+		domains = []models.PracticeDomain{}
+	}
+	for i := range domains {
+		domains[i].Drill = isDrillDomain(domains[i].Domain)
+	}
+	return domains, nil
+}
+
+func isDrillDomain(domain string) bool {
+	return domain == hardDomainName || strings.HasSuffix(domain, " Drill")
+}
+
+// StartPracticeQuiz starts a quiz built from the learner's options. It is an
+// ordinary attempt, so history, results and the PDF report work as for any
+// other.
+func (s *Service) StartPracticeQuiz(ctx context.Context, userID uuid.UUID, opts models.PracticeQuizOptions) (*models.Attempt, error) {
+	opts, err := s.normalizePracticeOptions(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	exam, err := s.repo.GetExamByName(ctx, practiceExamName)
+	if err != nil {
+		return nil, err
+	}
+	if exam == nil {
+		exam, err = s.repo.CreateExam(ctx, practiceExamName, "Learner-built practice quiz")
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return s.createAttempt(ctx, userID, exam, opts.QuestionCount, attemptSpec{
+		timeLimit: time.Duration(opts.TimeLimitMinutes) * time.Minute,
+		feedback:  opts.Feedback,
+		draw: func(ctx context.Context, attempt *models.Attempt, exam *models.Exam) ([]int, error) {
+			return s.drawPracticeQuestionIDs(ctx, attempt, exam, opts)
+		},
+	})
+}
+
+// normalizePracticeOptions validates the options, fills in defaults and
+// drops repeated domains.
+func (s *Service) normalizePracticeOptions(ctx context.Context, opts models.PracticeQuizOptions) (models.PracticeQuizOptions, error) {
+	if opts.QuestionCount < 1 || opts.QuestionCount > maxPracticeQuestions ||
+		opts.TimeLimitMinutes < 0 || opts.TimeLimitMinutes > maxPracticeMinutes {
+		return opts, ErrInvalidPracticeQuiz
+	}
+	switch opts.QuestionType {
+	case "", "any":
+		opts.QuestionType = ""
+	case "single", "multi":
+	default:
+		return opts, ErrInvalidPracticeQuiz
+	}
+	switch opts.Difficulty {
+	case "", "any":
+		opts.Difficulty = ""
+	case "easy", "medium", "hard":
+	default:
+		return opts, ErrInvalidPracticeQuiz
+	}
+	switch opts.Source {
+	case "", "all":
+		opts.Source = ""
+	case "unseen", "missed":
+	default:
+		return opts, ErrInvalidPracticeQuiz
+	}
+	switch opts.Feedback {
+	case "":
+		opts.Feedback = feedbackEnd
+	case feedbackEnd, feedbackImmediate:
+	default:
+		return opts, ErrInvalidPracticeQuiz
+	}
+
+	available, err := s.repo.ListPracticeDomains(ctx)
+	if err != nil {
+		return opts, err
+	}
+	known := make(map[string]struct{}, len(available))
+	for _, d := range available {
+		known[d.Domain] = struct{}{}
+	}
+
+	domains := make([]string, 0, len(opts.Domains))
+	seen := make(map[string]struct{}, len(opts.Domains))
+	for _, domain := range opts.Domains {
+		domain = strings.TrimSpace(domain)
+		if _, ok := known[domain]; !ok {
+			return opts, ErrInvalidPracticeQuiz
+		}
+		if _, dup := seen[domain]; dup {
+			continue
+		}
+		seen[domain] = struct{}{}
+		domains = append(domains, domain)
+	}
+	if len(domains) == 0 {
+		return opts, ErrInvalidPracticeQuiz
+	}
+	opts.Domains = domains
+	return opts, nil
+}
+
+// drawPracticeQuestionIDs draws the quiz from the questions matching the
+// options. Each chosen domain contributes in proportion to its matching
+// questions; families, enemy pairs and exposure apply as for exams, except
+// that a quiz of missed questions is not steered away from recent ones.
+func (s *Service) drawPracticeQuestionIDs(ctx context.Context, attempt *models.Attempt, exam *models.Exam, opts models.PracticeQuizOptions) ([]int, error) {
+	ctx, span := tracing.Start(ctx, "service.drawPracticeQuestionIDs", tracing.AttemptID(attempt.ID))
+	defer span.End()
+
+	eligible, err := s.repo.FindPracticeQuestionIDs(ctx, repository.PracticeFilter{
+		UserID:       attempt.UserID,
+		Domains:      opts.Domains,
+		QuestionType: opts.QuestionType,
+		Difficulty:   opts.Difficulty,
+		Source:       opts.Source,
+		MinResponses: itemMinResponses,
+		EasyRate:     practiceEasyRate,
+		HardRate:     practiceHardRate,
+		HardDomain:   hardDomainName,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	weights := make([]int, len(opts.Domains))
+	allowed := make([]int, 0)
+	for i, domain := range opts.Domains {
+		weights[i] = len(eligible[domain])
+		allowed = append(allowed, eligible[domain]...)
+	}
+	if len(allowed) < attempt.MaxScore {
+		return nil, fmt.Errorf("%w: %d of %d", ErrNotEnoughQuestions, len(allowed), attempt.MaxScore)
+	}
+
+	sel := repository.NewSelection()
+	sel.Restrict(allowed)
+	if opts.Source != "missed" {
+		if err := s.applyExposure(ctx, attempt, sel); err != nil {
+			return nil, err
+		}
+	}
+
+	counts := largestRemainder(weights, attempt.MaxScore, 0)
+	draws := make([]repository.DomainDraw, 0, len(opts.Domains))
+	for i, domain := range opts.Domains {
+		draws = append(draws, repository.DomainDraw{Domain: domain, Count: counts[i], Seed: attempt.Seed + int64(i)})
+	}
+
+	selected, err := s.repo.DrawQuestions(ctx, sel, draws)
+	if err != nil {
+		return nil, err
+	}
+
+	rng := rand.New(rand.NewSource(attempt.Seed))
+	rng.Shuffle(len(selected), func(i, j int) {
+		selected[i], selected[j] = selected[j], selected[i]
+	})
+	return selected, nil
+}
+
+// GetAttemptSettings reports the attempt's time limit and feedback mode,
+// and the group session it is taken in.
+func (s *Service) GetAttemptSettings(ctx context.Context, attemptID uuid.UUID) (*models.AttemptSettings, error) {
+	attempt, err := s.repo.GetAttempt(ctx, attemptID)
+	if err != nil {
+		return nil, err
+	}
+	if attempt == nil {
+		return nil, ErrAttemptNotFound
+	}
+
+	settings := &models.AttemptSettings{
+		AttemptID:        attempt.ID,
+		QuestionCount:    attempt.MaxScore,
+		Feedback:         attempt.Feedback,
+		TimeLimitSeconds: attempt.TimeLimitSeconds,
+		ServerTime:       time.Now().UTC(),
+	}
+	if deadline, ok := attemptDeadline(attempt); ok {
+		settings.EndsAt = &deadline
+	}
+	settings.GroupSessionID, err = s.repo.GetAttemptGroupSessionID(ctx, attemptID)
+	if err != nil {
+		return nil, err
+	}
+	return settings, nil
+}
+
+func attemptDeadline(attempt *models.Attempt) (time.Time, bool) {
+	if attempt.TimeLimitSeconds == nil {
+		return time.Time{}, false
+	}
+	return attempt.StartedAt.Add(time.Duration(*attempt.TimeLimitSeconds) * time.Second), true
+}
+
+// attemptExpired reports whether a timed attempt's deadline, grace included,
+// had passed at now.
+func attemptExpired(attempt *models.Attempt, now time.Time) bool {
+	deadline, ok := attemptDeadline(attempt)
+	return ok && now.After(deadline.Add(sectionSubmitGrace))
+}
+
+// AnswerQuestion locks in the answer to one question of an attempt with
+// immediate feedback and returns the verdict with the explanation. The
+// first answer stands: answering again returns the locked answer.
+func (s *Service) AnswerQuestion(ctx context.Context, attemptID uuid.UUID, questionID int, choiceIDs []int) (*models.AnswerFeedback, error) {
+	attempt, err := s.repo.GetAttempt(ctx, attemptID)
+	if err != nil {
+		return nil, err
+	}
+	if attempt == nil {
+		return nil, ErrAttemptNotFound
+	}
+	if attempt.Feedback != feedbackImmediate {
+		return nil, ErrFeedbackNotImmediate
+	}
+	if attempt.EndedAt != nil {
+		return nil, ErrAttemptAlreadyClosed
+	}
+	if attemptExpired(attempt, time.Now()) {
+		return nil, ErrTimeExpired
+	}
+
+	questionIDs, err := s.repo.GetAttemptQuestionIDs(ctx, attemptID)
+	if err != nil {
+		return nil, err
+	}
+	served := false
+	for _, id := range questionIDs {
+		if id == questionID {
+			served = true
+			break
+		}
+	}
+	if !served {
+		return nil, ErrQuestionNotInAttempt
+	}
+
+	questions, err := s.repo.GetQuestionsWithChoices(ctx, []int{questionID})
+	if err != nil {
+		return nil, err
+	}
+	if len(questions) == 0 {
+		return nil, ErrQuestionNotFound
+	}
+	orderChoicesForAttempt(attempt, questions)
+	question := questions[0]
+
+	locked, err := s.lockedAnswers(ctx, attemptID)
+	if err != nil {
+		return nil, err
+	}
+	lockedIDs, isLocked := locked[questionID]
+	if isLocked {
+		choiceIDs = lockedIDs
+	}
+	answers := normalizeAnswers(map[int]models.QuestionWithChoices{questionID: question}, models.ExamSubmission{
+		Answers: []models.AnswerSubmission{{QuestionID: questionID, ChoiceIDs: choiceIDs}},
+	})
+	selectedIDs := answers[questionID]
+	if len(selectedIDs) == 0 {
+		return nil, ErrEmptyAnswer
+	}
+
+	correctIDs, isCorrect := gradeSelection(question, selectedIDs)
+	if !isLocked {
+		// The selection history ends with what was locked even if client
+		// events were lost
+		selections, err := s.missingSelections(ctx, attemptID, map[int][]int{questionID: selectedIDs})
+		if err != nil {
+			return nil, err
+		}
+		graded := make([]models.GradedChoice, 0, len(selectedIDs))
+		for _, choiceID := range selectedIDs {
+			graded = append(graded, models.GradedChoice{QuestionID: questionID, ChoiceID: choiceID, IsCorrect: isCorrect})
+		}
+		stored, err := s.repo.LockAttemptAnswer(ctx, attemptID, questionID, graded, selections)
+		if err != nil {
+			return nil, err
+		}
+		if !stored {
+			// A concurrent request locked the question or submitted the
+			// attempt first; answer from its state
+			return s.AnswerQuestion(ctx, attemptID, questionID, choiceIDs)
+		}
+		s.audit(ctx, &attempt.UserID, "attempt.answer_locked", "attempt", attemptID.String(), nil, map[string]interface{}{
+			"question_id": questionID,
+			"choice_ids":  selectedIDs,
+			"is_correct":  isCorrect,
+		})
+	}
+
+	return &models.AnswerFeedback{
+		QuestionID:       questionID,
+		ChoiceIDs:        selectedIDs,
+		CorrectChoiceIDs: correctIDs,
+		IsCorrect:        isCorrect,
+		Explanation:      question.Explanation,
+		Choices:          question.Choices,
+	}, nil
+}
+
+// applyLockedAnswers replaces submitted answers with those locked in while
+// the attempt was running, which cannot be changed at submission.
+func (s *Service) applyLockedAnswers(ctx context.Context, attempt *models.Attempt, questionMap map[int]models.QuestionWithChoices, answersByQuestion map[int][]int) error {
+	if attempt.Feedback != feedbackImmediate {
+		return nil
+	}
+	locked, err := s.lockedAnswers(ctx, attempt.ID)
+	if err != nil {
+		return err
+	}
+	submission := models.ExamSubmission{Answers: make([]models.AnswerSubmission, 0, len(locked))}
+	for questionID, choiceIDs := range locked {
+		submission.Answers = append(submission.Answers, models.AnswerSubmission{QuestionID: questionID, ChoiceIDs: choiceIDs})
+	}
+	for questionID, choiceIDs := range normalizeAnswers(questionMap, submission) {
+		answersByQuestion[questionID] = choiceIDs
+	}
+	return nil
+}
+
+// lockedAnswers returns the choices already locked in per question.
+func (s *Service) lockedAnswers(ctx context.Context, attemptID uuid.UUID) (map[int][]int, error) {
+	answers, err := s.repo.GetAttemptAnswers(ctx, attemptID)
+	if err != nil {
+		return nil, err
+	}
+	locked := make(map[int][]int)
+	for _, answer := range answers {
+		if answer.ChoiceID != nil {
+			locked[answer.QuestionID] = append(locked[answer.QuestionID], *answer.ChoiceID)
+		}
+	}
+	return locked, nil
+}
@@ -0,0 +1,256 @@
+package service
+
+import (
+	"context"
+	"math"
+	"sort"
+
+	"capm-exam-system/internal/models"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// movingAverageWindow is the number of attempts averaged into each point
+	// of the score trend.
+	movingAverageWindow = 3
+	// recentDomainAttempts is how many of the latest attempts that touched a
+	// domain count towards its recent accuracy.
+	recentDomainAttempts = 3
+
+	readinessMocks = 5
+	// readinessDecay down-weights each older mock relative to the next one.
+	readinessDecay = 0.7
+	// readinessExamLength is the number of scored questions on the real exam.
+	readinessExamLength = 150
+
+	weakestTopicCount       = 3
+	weakestTopicMinAnswered = 5
+
+	mockExamAttemptType = "Mock Exam"
+)
+
+// GetUserProgress summarises the submitted attempts of a user: scores over
+// time, per-domain accuracy trends, an estimated probability of passing and
+// the weakest domains.
+func (s *Service) GetUserProgress(ctx context.Context, userID uuid.UUID) (*models.UserProgress, error) {
+	history, err := s.repo.GetAttemptsByUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	domainResults, err := s.repo.GetDomainResultsByUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return buildUserProgress(userID, history, domainResults, s.config.PassMark), nil
+}
+
+func buildUserProgress(userID uuid.UUID, history []models.AttemptHistory, domainResults []models.DomainResult, passMark float64) *models.UserProgress {
+	completed := make([]models.AttemptHistory, 0, len(history))
+	for _, record := range history {
+		if record.EndedAt != nil && record.Score != nil && record.MaxScore > 0 {
+			completed = append(completed, record)
+		}
+	}
+	sort.SliceStable(completed, func(i, j int) bool {
+		return completed[i].EndedAt.Before(*completed[j].EndedAt)
+	})
+
+	trends := domainTrends(domainResults)
+
+	return &models.UserProgress{
+		UserID:            userID,
+		CompletedAttempts: len(completed),
+		ScoreTrend:        scoreTrend(completed),
+		DomainTrends:      trends,
+		Readiness:         estimateReadiness(completed, passMark),
+		WeakestTopics:     weakestTopics(trends),
+	}
+}
+
+func scoreTrend(completed []models.AttemptHistory) []models.ScorePoint {
+	points := make([]models.ScorePoint, 0, len(completed))
+	for i, record := range completed {
+		point := models.ScorePoint{
+			AttemptID:   record.AttemptID,
+			AttemptType: record.AttemptType,
+			EndedAt:     *record.EndedAt,
+			Score:       *record.Score,
+			MaxScore:    record.MaxScore,
+			Percentage:  float64(*record.Score) / float64(record.MaxScore) * 100,
+		}
+
+		start := i - movingAverageWindow + 1
+		if start < 0 {
+			start = 0
+		}
+		sum := point.Percentage
+		for _, prev := range points[start:i] {
+			sum += prev.Percentage
+		}
+		point.MovingAverage = sum / float64(i-start+1)
+
+		points = append(points, point)
+	}
+	return points
+}
+
+func domainTrends(results []models.DomainResult) []models.DomainTrend {
+	byDomain := make(map[string]*models.DomainTrend)
+	order := make([]string, 0)
+	for _, result := range results {
+		if result.Answered == 0 {
+			continue
+		}
+		trend, ok := byDomain[result.Domain]
+		if !ok {
+			trend = &models.DomainTrend{Domain: result.Domain}
+			byDomain[result.Domain] = trend
+			order = append(order, result.Domain)
+		}
+		trend.Points = append(trend.Points, models.DomainPoint{
+			AttemptID: result.AttemptID,
+			EndedAt:   result.EndedAt,
+			Correct:   result.Correct,
+			Answered:  result.Answered,
+			Accuracy:  float64(result.Correct) / float64(result.Answered),
+		})
+	}
+
+	sort.Strings(order)
+	trends := make([]models.DomainTrend, 0, len(order))
+	for _, domain := range order {
+		trend := byDomain[domain]
+		trend.OverallAccuracy = pooledAccuracy(trend.Points)
+		recent := trend.Points
+		if len(recent) > recentDomainAttempts {
+			recent = recent[len(recent)-recentDomainAttempts:]
+		}
+		trend.RecentAccuracy = pooledAccuracy(recent)
+		trend.Slope = accuracySlope(trend.Points)
+		trends = append(trends, *trend)
+	}
+	return trends
+}
+
+func pooledAccuracy(points []models.DomainPoint) float64 {
+	answered, correct := 0, 0
+	for _, p := range points {
+		answered += p.Answered
+		correct += p.Correct
+	}
+	if answered == 0 {
+		return 0
+	}
+	return float64(correct) / float64(answered)
+}
+
+// accuracySlope is the least-squares change in accuracy per attempt.
+func accuracySlope(points []models.DomainPoint) float64 {
+	n := float64(len(points))
+	if n < 2 {
+		return 0
+	}
+
+	var sumX, sumY, sumXY, sumXX float64
+	for i, p := range points {
+		x := float64(i)
+		sumX += x
+		sumY += p.Accuracy
+		sumXY += x * p.Accuracy
+		sumXX += x * x
+	}
+	denominator := n*sumXX - sumX*sumX
+	if denominator == 0 {
+		return 0
+	}
+	return (n*sumXY - sumX*sumY) / denominator
+}
+
+// estimateReadiness weights the most recent full CAPM mocks, newest first,
+// and treats the score on a real exam as normally distributed around that
+// estimate. The spread combines the sampling error of a 150-question exam,
+// the uncertainty of the estimate itself and the variation between mocks.
+// passMark is the share of correct answers needed to pass.
+func estimateReadiness(completed []models.AttemptHistory, passMark float64) *models.Readiness {
+	mocks := make([]models.AttemptHistory, 0, readinessMocks)
+	for i := len(completed) - 1; i >= 0 && len(mocks) < readinessMocks; i-- {
+		if completed[i].AttemptType == mockExamAttemptType {
+			mocks = append(mocks, completed[i])
+		}
+	}
+	if len(mocks) == 0 {
+		return nil
+	}
+
+	var weightSum, weightedScore float64
+	totalQuestions := 0
+	weight := 1.0
+	for _, mock := range mocks {
+		weightSum += weight
+		weightedScore += weight * float64(*mock.Score) / float64(mock.MaxScore)
+		totalQuestions += mock.MaxScore
+		weight *= readinessDecay
+	}
+	estimate := weightedScore / weightSum
+
+	variance := estimate * (1 - estimate) * (1/float64(readinessExamLength) + 1/float64(totalQuestions))
+	if len(mocks) > 1 {
+		var spread float64
+		for _, mock := range mocks {
+			diff := float64(*mock.Score)/float64(mock.MaxScore) - estimate
+			spread += diff * diff
+		}
+		variance += spread / float64(len(mocks)-1) / float64(len(mocks))
+	}
+	stdErr := math.Sqrt(variance)
+
+	probability := 1.0
+	if estimate < passMark {
+		probability = 0
+	}
+	if stdErr > 0 {
+		z := (estimate - passMark) / stdErr
+		probability = 0.5 * (1 + math.Erf(z/math.Sqrt2))
+	}
+
+	return &models.Readiness{
+		PassProbability: probability,
+		EstimatedScore:  estimate * 100,
+		StandardError:   stdErr * 100,
+		PassMark:        passMark * 100,
+		MocksConsidered: len(mocks),
+	}
+}
+
+// weakestTopics ranks domains with enough answers by their recent accuracy.
+func weakestTopics(trends []models.DomainTrend) []models.DomainAccuracy {
+	candidates := make([]models.DomainAccuracy, 0, len(trends))
+	for _, trend := range trends {
+		answered, correct := 0, 0
+		for _, p := range trend.Points {
+			answered += p.Answered
+			correct += p.Correct
+		}
+		if answered < weakestTopicMinAnswered {
+			continue
+		}
+		candidates = append(candidates, models.DomainAccuracy{
+			Domain:         trend.Domain,
+			Answered:       answered,
+			Correct:        correct,
+			Accuracy:       trend.OverallAccuracy,
+			RecentAccuracy: trend.RecentAccuracy,
+		})
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].RecentAccuracy < candidates[j].RecentAccuracy
+	})
+	if len(candidates) > weakestTopicCount {
+		candidates = candidates[:weakestTopicCount]
+	}
+	return candidates
+}
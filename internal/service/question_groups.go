@@ -0,0 +1,96 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"capm-exam-system/internal/models"
+)
+
+// maxFamilyLength matches questions.family.
+const maxFamilyLength = 100
+
+// SetQuestionFamily moves a question into a template family, or out of any
+// family when family is empty. Draws take at most one question per family
+// while the domain has enough families to fill its quota.
+func (s *Service) SetQuestionFamily(ctx context.Context, questionID int, family string) (*models.QuestionFamily, error) {
+	family = strings.TrimSpace(family)
+	if len(family) > maxFamilyLength {
+		return nil, ErrInvalidFamily
+	}
+
+	questions, err := s.repo.GetQuestionsWithChoices(ctx, []int{questionID})
+	if err != nil {
+		return nil, err
+	}
+	if len(questions) == 0 {
+		return nil, ErrQuestionNotFound
+	}
+
+	if _, err := s.repo.SetQuestionFamily(ctx, questionID, family); err != nil {
+		return nil, err
+	}
+
+	s.audit(ctx, nil, "question.family_changed", "question", auditQuestionID(questionID),
+		map[string]interface{}{"family": questions[0].Family},
+		map[string]interface{}{"family": family})
+	return &models.QuestionFamily{QuestionID: questionID, Family: family}, nil
+}
+
+// CreateEnemyPair stops two questions from being served in the same attempt.
+func (s *Service) CreateEnemyPair(ctx context.Context, questionID, enemyID int, reason string) (*models.EnemyPair, error) {
+	if questionID == enemyID {
+		return nil, ErrInvalidEnemyPair
+	}
+
+	questions, err := s.repo.GetQuestionsWithChoices(ctx, []int{questionID, enemyID})
+	if err != nil {
+		return nil, err
+	}
+	if len(questions) != 2 {
+		return nil, ErrQuestionNotFound
+	}
+
+	pair, err := s.repo.CreateEnemyPair(ctx, questionID, enemyID, strings.TrimSpace(reason))
+	if err != nil {
+		return nil, err
+	}
+
+	s.audit(ctx, nil, "question.enemy_added", "enemy_pair", enemyPairID(pair.QuestionID, pair.EnemyID), nil, pair)
+	return pair, nil
+}
+
+func (s *Service) DeleteEnemyPair(ctx context.Context, questionID, enemyID int) error {
+	deleted, err := s.repo.DeleteEnemyPair(ctx, questionID, enemyID)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrEnemyPairNotFound
+	}
+
+	if questionID > enemyID {
+		questionID, enemyID = enemyID, questionID
+	}
+	s.audit(ctx, nil, "question.enemy_removed", "enemy_pair", enemyPairID(questionID, enemyID),
+		map[string]interface{}{"question_id": questionID, "enemy_id": enemyID}, nil)
+	return nil
+}
+
+// ListEnemyPairs lists every enemy pair, or those involving questionID when
+// it is non-zero.
+func (s *Service) ListEnemyPairs(ctx context.Context, questionID int) ([]models.EnemyPair, error) {
+	pairs, err := s.repo.ListEnemyPairs(ctx, questionID)
+	if err != nil {
+		return nil, err
+	}
+	if pairs == nil {
+		pairs = []models.EnemyPair{}
+	}
+	return pairs, nil
+}
+
+func enemyPairID(questionID, enemyID int) string {
+	return fmt.Sprintf("%d:%d", questionID, enemyID)
+}
@@ -0,0 +1,254 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"capm-exam-system/internal/models"
+
+	"github.com/google/uuid"
+)
+
+const (
+	reportCategoryWrongKey  = "wrong_key"
+	reportCategoryAmbiguous = "ambiguous"
+	reportCategoryTypo      = "typo"
+	reportCategoryOutdated  = "outdated"
+
+	reportStatusOpen     = "open"
+	reportStatusInReview = "in_review"
+	reportStatusResolved = "resolved"
+	reportStatusRejected = "rejected"
+
+	maxReportMessageLength = 2000
+)
+
+var reportCategories = map[string]struct{}{
+	reportCategoryWrongKey:  {},
+	reportCategoryAmbiguous: {},
+	reportCategoryTypo:      {},
+	reportCategoryOutdated:  {},
+}
+
+var reportStatuses = map[string]struct{}{
+	reportStatusOpen:     {},
+	reportStatusInReview: {},
+	reportStatusResolved: {},
+	reportStatusRejected: {},
+}
+
+// ReportQuestionIssue files a learner report against a question they were
+// served in one of their attempts.
+func (s *Service) ReportQuestionIssue(ctx context.Context, questionID int, userID, attemptID uuid.UUID, category, message string) (*models.QuestionReport, error) {
+	if _, ok := reportCategories[category]; !ok {
+		return nil, ErrInvalidReport
+	}
+	message = strings.TrimSpace(message)
+	if len(message) > maxReportMessageLength {
+		message = message[:maxReportMessageLength]
+	}
+
+	attempt, err := s.repo.GetAttempt(ctx, attemptID)
+	if err != nil {
+		return nil, err
+	}
+	if attempt == nil {
+		return nil, ErrAttemptNotFound
+	}
+	if attempt.UserID != userID {
+		return nil, ErrAttemptForbidden
+	}
+
+	exam, err := s.repo.GetExamByID(ctx, attempt.ExamID)
+	if err != nil {
+		return nil, err
+	}
+	if exam == nil {
+		return nil, ErrExamNotFound
+	}
+
+	questionIDs, err := s.pickQuestionIDs(ctx, attempt, exam)
+	if err != nil {
+		return nil, err
+	}
+	served := false
+	for _, id := range questionIDs {
+		if id == questionID {
+			served = true
+			break
+		}
+	}
+	if !served {
+		return nil, ErrQuestionNotInAttempt
+	}
+
+	report, err := s.repo.CreateQuestionReport(ctx, questionID, &attemptID, userID, category, message)
+	if err != nil {
+		return nil, err
+	}
+
+	s.audit(ctx, &userID, "question_report.created", "question_report", report.ID.String(), nil, report)
+	return report, nil
+}
+
+func (s *Service) ListQuestionReports(ctx context.Context, status, category string, questionID int) ([]models.QuestionReport, error) {
+	reports, err := s.repo.ListQuestionReports(ctx, status, category, questionID)
+	if err != nil {
+		return nil, err
+	}
+	if reports == nil {
+		reports = []models.QuestionReport{}
+	}
+	return reports, nil
+}
+
+func (s *Service) GetQuestionReport(ctx context.Context, reportID uuid.UUID) (*models.QuestionReport, error) {
+	report, err := s.repo.GetQuestionReport(ctx, reportID)
+	if err != nil {
+		return nil, err
+	}
+	if report == nil {
+		return nil, ErrReportNotFound
+	}
+
+	comments, err := s.repo.GetQuestionReportComments(ctx, reportID)
+	if err != nil {
+		return nil, err
+	}
+	report.Comments = comments
+	if report.Comments == nil {
+		report.Comments = []models.QuestionReportComment{}
+	}
+	return report, nil
+}
+
+func (s *Service) AddQuestionReportComment(ctx context.Context, reportID uuid.UUID, author, body string) (*models.QuestionReportComment, error) {
+	author = strings.TrimSpace(author)
+	body = strings.TrimSpace(body)
+	if author == "" || body == "" {
+		return nil, ErrInvalidReport
+	}
+
+	report, err := s.repo.GetQuestionReport(ctx, reportID)
+	if err != nil {
+		return nil, err
+	}
+	if report == nil {
+		return nil, ErrReportNotFound
+	}
+
+	comment, err := s.repo.CreateQuestionReportComment(ctx, reportID, author, body)
+	if err != nil {
+		return nil, err
+	}
+
+	s.audit(ctx, &report.UserID, "question_report.commented", "question_report", reportID.String(), nil, comment)
+	return comment, nil
+}
+
+// UpdateQuestionReport applies a triage change. Resolving a wrong-key report
+// with corrected choices replaces the question's answer key; with Rescore set
+// a regrade job is queued for every submitted attempt that answered it.
+func (s *Service) UpdateQuestionReport(ctx context.Context, reportID uuid.UUID, update models.QuestionReportUpdate) (*models.QuestionReport, error) {
+	report, err := s.repo.GetQuestionReport(ctx, reportID)
+	if err != nil {
+		return nil, err
+	}
+	if report == nil {
+		return nil, ErrReportNotFound
+	}
+	before := *report
+
+	if update.Status != nil {
+		if _, ok := reportStatuses[*update.Status]; !ok {
+			return nil, ErrInvalidReport
+		}
+		report.Status = *update.Status
+	}
+	if update.Resolution != nil {
+		report.Resolution = strings.TrimSpace(*update.Resolution)
+	}
+
+	if len(update.CorrectedChoiceIDs) > 0 || update.Rescore {
+		if report.Category != reportCategoryWrongKey || report.Status != reportStatusResolved || len(update.CorrectedChoiceIDs) == 0 {
+			return nil, ErrInvalidKeyCorrection
+		}
+	}
+
+	switch report.Status {
+	case reportStatusResolved, reportStatusRejected:
+		if report.ResolvedAt == nil {
+			now := time.Now().UTC()
+			report.ResolvedAt = &now
+		}
+	default:
+		report.ResolvedAt = nil
+	}
+
+	if len(update.CorrectedChoiceIDs) > 0 {
+		if err := s.correctAnswerKey(ctx, report.QuestionID, update.CorrectedChoiceIDs); err != nil {
+			return nil, err
+		}
+		report.CorrectedChoiceIDs = update.CorrectedChoiceIDs
+
+		if update.Rescore {
+			questionID := report.QuestionID
+			job, err := s.QueueRegrade(ctx, &questionID, fmt.Sprintf("wrong-key report %s", report.ID))
+			if err != nil {
+				return nil, err
+			}
+			report.RegradeJobID = &job.ID
+		}
+	}
+
+	if err := s.repo.UpdateQuestionReport(ctx, report); err != nil {
+		return nil, err
+	}
+	s.audit(ctx, &report.UserID, "question_report.updated", "question_report", reportID.String(), before, report)
+
+	return s.GetQuestionReport(ctx, reportID)
+}
+
+// correctAnswerKey validates and stores a new answer key.
+func (s *Service) correctAnswerKey(ctx context.Context, questionID int, choiceIDs []int) error {
+	questions, err := s.repo.GetQuestionsWithChoices(ctx, []int{questionID})
+	if err != nil {
+		return err
+	}
+	if len(questions) == 0 {
+		return ErrInvalidKeyCorrection
+	}
+	question := questions[0]
+
+	belongs := make(map[int]struct{}, len(question.Choices))
+	for _, choice := range question.Choices {
+		belongs[choice.ID] = struct{}{}
+	}
+	unique := make(map[int]struct{}, len(choiceIDs))
+	for _, id := range choiceIDs {
+		if _, ok := belongs[id]; !ok {
+			return ErrInvalidKeyCorrection
+		}
+		unique[id] = struct{}{}
+	}
+	if !question.IsMultiSelect && len(unique) != 1 {
+		return ErrInvalidKeyCorrection
+	}
+
+	if err := s.repo.SetCorrectChoices(ctx, questionID, choiceIDs); err != nil {
+		return err
+	}
+
+	previous := make([]int, 0, 1)
+	for _, choice := range question.Choices {
+		if choice.IsCorrect {
+			previous = append(previous, choice.ID)
+		}
+	}
+	s.audit(ctx, nil, "question.answer_key_changed", "question", auditQuestionID(questionID),
+		map[string]interface{}{"correct_choice_ids": previous},
+		map[string]interface{}{"correct_choice_ids": choiceIDs})
+	return nil
+}
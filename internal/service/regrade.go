@@ -0,0 +1,283 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"capm-exam-system/internal/models"
+	"capm-exam-system/internal/tracing"
+
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+const (
+	regradeStatusCompleted = "completed"
+	regradeStatusFailed    = "failed"
+
+	// regradeStaleAfter is how long a job may stay running before another
+	// worker assumes its owner died and runs it again. Each attempt is
+	// corrected and rescored against its stored score in one transaction, so
+	// a rerun is safe.
+	regradeStaleAfter   = 15 * time.Minute
+	regradeJobListLimit = 50
+
+	notificationScoreAdjusted = "score_adjusted"
+)
+
+// QueueRegrade schedules a regrade of every attempt that answered the
+// question, or of every answered question when questionID is nil.
+func (s *Service) QueueRegrade(ctx context.Context, questionID *int, reason string) (*models.RegradeJob, error) {
+	if questionID != nil {
+		questions, err := s.repo.GetQuestionsWithChoices(ctx, []int{*questionID})
+		if err != nil {
+			return nil, err
+		}
+		if len(questions) == 0 {
+			return nil, ErrQuestionNotFound
+		}
+	}
+	job, err := s.repo.CreateRegradeJob(ctx, questionID, reason)
+	if err != nil {
+		return nil, err
+	}
+
+	s.audit(ctx, nil, "regrade_job.queued", "regrade_job", job.ID.String(), nil, job)
+	return job, nil
+}
+
+func (s *Service) GetRegradeJob(ctx context.Context, jobID uuid.UUID) (*models.RegradeJob, error) {
+	job, err := s.repo.GetRegradeJob(ctx, jobID)
+	if err != nil {
+		return nil, err
+	}
+	if job == nil {
+		return nil, ErrRegradeJobNotFound
+	}
+	return job, nil
+}
+
+func (s *Service) ListRegradeJobs(ctx context.Context) ([]models.RegradeJob, error) {
+	jobs, err := s.repo.ListRegradeJobs(ctx, regradeJobListLimit)
+	if err != nil {
+		return nil, err
+	}
+	if jobs == nil {
+		jobs = []models.RegradeJob{}
+	}
+	return jobs, nil
+}
+
+func (s *Service) GetScoreAdjustments(ctx context.Context, attemptID uuid.UUID) ([]models.ScoreAdjustment, error) {
+	adjustments, err := s.repo.GetScoreAdjustments(ctx, attemptID)
+	if err != nil {
+		return nil, err
+	}
+	if adjustments == nil {
+		adjustments = []models.ScoreAdjustment{}
+	}
+	return adjustments, nil
+}
+
+func (s *Service) GetUserNotifications(ctx context.Context, userID uuid.UUID, unreadOnly bool) ([]models.UserNotification, error) {
+	notifications, err := s.repo.GetUserNotifications(ctx, userID, unreadOnly)
+	if err != nil {
+		return nil, err
+	}
+	if notifications == nil {
+		notifications = []models.UserNotification{}
+	}
+	return notifications, nil
+}
+
+func (s *Service) MarkUserNotificationsRead(ctx context.Context, userID uuid.UUID) error {
+	if err := s.repo.MarkUserNotificationsRead(ctx, userID); err != nil {
+		return err
+	}
+
+	s.audit(ctx, &userID, "notifications.read", "user", userID.String(), nil, nil)
+	return nil
+}
+
+// RunRegrader processes queued regrade jobs until ctx is cancelled, checking
+// the queue every interval once it is empty. A job interrupted by the
+// cancellation goes back in the queue for the next worker.
+func (s *Service) RunRegrader(ctx context.Context, interval time.Duration) {
+	ctx = WithActor(ctx, auditActorRegrader)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := s.repo.RequeueStaleRegradeJobs(ctx, regradeStaleAfter); err != nil && ctx.Err() == nil {
+			slog.ErrorContext(ctx, "failed to requeue stale regrade jobs", "error", err)
+		}
+
+		for ctx.Err() == nil {
+			processed, err := s.ProcessNextRegradeJob(ctx)
+			if err != nil {
+				if ctx.Err() == nil {
+					slog.ErrorContext(ctx, "regrade failed", "error", err)
+				}
+				break
+			}
+			if !processed {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// ProcessNextRegradeJob claims and runs one queued job. It reports false when
+// the queue was empty.
+func (s *Service) ProcessNextRegradeJob(ctx context.Context) (bool, error) {
+	job, err := s.repo.ClaimRegradeJob(ctx)
+	if err != nil {
+		return false, err
+	}
+	if job == nil {
+		return false, nil
+	}
+
+	runErr := s.runRegradeJob(ctx, job)
+	if runErr != nil && ctx.Err() != nil {
+		// Shutting down: regrading is idempotent, so let the next run redo it.
+		if err := s.repo.ReleaseRegradeJob(context.WithoutCancel(ctx), job.ID); err != nil {
+			return true, err
+		}
+		slog.InfoContext(ctx, "regrade job released for a later run", "job_id", job.ID)
+		return true, nil
+	}
+
+	job.Status = regradeStatusCompleted
+	if runErr != nil {
+		job.Status = regradeStatusFailed
+		job.Error = runErr.Error()
+	}
+
+	// Record the outcome even if shutdown begins meanwhile.
+	if err := s.repo.FinishRegradeJob(context.WithoutCancel(ctx), job); err != nil {
+		return true, err
+	}
+	s.audit(ctx, nil, "regrade_job.finished", "regrade_job", job.ID.String(), nil, job)
+	if runErr != nil {
+		return true, fmt.Errorf("regrade job %s failed: %v", job.ID, runErr)
+	}
+	return true, nil
+}
+
+func (s *Service) runRegradeJob(ctx context.Context, job *models.RegradeJob) error {
+	ctx, span := tracing.Start(ctx, "service.runRegradeJob", attribute.String("capm.regrade_job.id", job.ID.String()))
+	defer span.End()
+
+	var questionIDs []int
+	if job.QuestionID != nil {
+		questionIDs = []int{*job.QuestionID}
+	} else {
+		ids, err := s.repo.GetAnsweredQuestionIDs(ctx)
+		if err != nil {
+			return err
+		}
+		questionIDs = ids
+	}
+
+	questions, err := s.repo.GetQuestionsWithChoices(ctx, questionIDs)
+	if err != nil {
+		return err
+	}
+
+	corrections := make(map[uuid.UUID][]models.AnswerCorrection)
+	for _, question := range questions {
+		if err := s.regradeQuestion(ctx, question, corrections); err != nil {
+			return err
+		}
+	}
+	job.AttemptsChecked = len(corrections)
+
+	// Every checked attempt is rescored, not only those corrected now: an
+	// earlier run may have stopped after correcting them
+	for attemptID, fixes := range corrections {
+		adjusted, err := s.rescoreAttempt(ctx, job, attemptID, fixes)
+		if err != nil {
+			return err
+		}
+		if adjusted {
+			job.AttemptsChanged++
+		}
+	}
+
+	return nil
+}
+
+// regradeQuestion compares the stored correctness of every answer to the
+// question, submitted or not, with its current key. Each attempt that
+// answered it gets an entry in corrections, holding the answer rows that no
+// longer match.
+func (s *Service) regradeQuestion(ctx context.Context, question models.QuestionWithChoices, corrections map[uuid.UUID][]models.AnswerCorrection) error {
+	answers, err := s.repo.GetAnswersByQuestion(ctx, question.ID)
+	if err != nil {
+		return err
+	}
+
+	byAttempt := make(map[uuid.UUID][]models.AttemptAnswer)
+	for _, answer := range answers {
+		byAttempt[answer.AttemptID] = append(byAttempt[answer.AttemptID], answer)
+	}
+
+	for attemptID, rows := range byAttempt {
+		selected := make([]int, 0, len(rows))
+		for _, row := range rows {
+			if row.ChoiceID != nil {
+				selected = append(selected, *row.ChoiceID)
+			}
+		}
+		_, isCorrect := gradeSelection(question, selected)
+
+		fixes := corrections[attemptID]
+		for _, row := range rows {
+			if row.IsCorrect != nil && *row.IsCorrect == isCorrect {
+				continue
+			}
+			fixes = append(fixes, models.AnswerCorrection{AnswerID: row.ID, IsCorrect: isCorrect})
+		}
+		corrections[attemptID] = fixes
+	}
+	return nil
+}
+
+// rescoreAttempt applies the attempt's corrections and recounts its score in
+// one transaction and, when the score moved, audits the adjustment; the
+// learner is told through a notification written with it.
+func (s *Service) rescoreAttempt(ctx context.Context, job *models.RegradeJob, attemptID uuid.UUID, corrections []models.AnswerCorrection) (bool, error) {
+	ctx, span := tracing.Start(ctx, "service.rescoreAttempt", tracing.AttemptID(attemptID))
+	defer span.End()
+
+	adjustment, err := s.repo.RegradeAttempt(ctx, models.AttemptRegrade{
+		JobID:            job.ID,
+		AttemptID:        attemptID,
+		Corrections:      corrections,
+		NotificationKind: notificationScoreAdjusted,
+		Message: func(startedAt time.Time, oldScore, newScore int) string {
+			return fmt.Sprintf("Your score for the attempt started %s was adjusted from %d to %d after an answer key correction.",
+				startedAt.Format("2 Jan 2006"), oldScore, newScore)
+		},
+	})
+	if err != nil {
+		return false, err
+	}
+	if adjustment == nil {
+		return false, nil
+	}
+
+	s.audit(ctx, &adjustment.UserID, "attempt.rescored", "attempt", attemptID.String(),
+		map[string]interface{}{"score": adjustment.OldScore},
+		map[string]interface{}{"score": adjustment.NewScore, "regrade_job_id": job.ID})
+	return true, nil
+}
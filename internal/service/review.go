@@ -0,0 +1,167 @@
+package service
+
+import (
+	"context"
+	"math/rand"
+	"time"
+
+	"capm-exam-system/internal/models"
+
+	"github.com/google/uuid"
+)
+
+const (
+	retakeExamName    = "Missed Question Retake"
+	bookmarksExamName = "Bookmarked Questions"
+	missedExamName    = "Recent Misses Review"
+
+	// Bookmark and recent-miss sessions take at most this many questions,
+	// the most recent first.
+	maxReviewQuestions = 100
+	defaultReviewDays  = 30
+	maxReviewDays      = 365
+)
+
+// ListBookmarks returns the user's bookmarked questions, newest first.
+func (s *Service) ListBookmarks(ctx context.Context, userID uuid.UUID) ([]models.Bookmark, error) {
+	bookmarks, err := s.repo.ListBookmarks(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if bookmarks == nil {
+		bookmarks = []models.Bookmark{}
+	}
+	return bookmarks, nil
+}
+
+// AddBookmark saves a question for the user; bookmarking it twice is a no-op.
+func (s *Service) AddBookmark(ctx context.Context, userID uuid.UUID, questionID int) error {
+	user, err := s.repo.GetUserByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return ErrUserNotFound
+	}
+	questions, err := s.repo.GetQuestionsWithChoices(ctx, []int{questionID})
+	if err != nil {
+		return err
+	}
+	if len(questions) == 0 {
+		return ErrQuestionNotFound
+	}
+
+	added, err := s.repo.AddBookmark(ctx, userID, questionID)
+	if err != nil {
+		return err
+	}
+	if added {
+		s.audit(ctx, &userID, "bookmark.added", "question", auditQuestionID(questionID), nil, nil)
+	}
+	return nil
+}
+
+func (s *Service) RemoveBookmark(ctx context.Context, userID uuid.UUID, questionID int) error {
+	removed, err := s.repo.RemoveBookmark(ctx, userID, questionID)
+	if err != nil {
+		return err
+	}
+	if !removed {
+		return ErrBookmarkNotFound
+	}
+	s.audit(ctx, &userID, "bookmark.removed", "question", auditQuestionID(questionID), nil, nil)
+	return nil
+}
+
+// StartMissedRetake starts an attempt over the questions the user got wrong
+// or left unanswered in one of their submitted attempts.
+func (s *Service) StartMissedRetake(ctx context.Context, userID, attemptID uuid.UUID, tutor bool) (*models.Attempt, error) {
+	source, err := s.repo.GetAttempt(ctx, attemptID)
+	if err != nil {
+		return nil, err
+	}
+	if source == nil {
+		return nil, ErrAttemptNotFound
+	}
+	if source.UserID != userID {
+		return nil, ErrAttemptForbidden
+	}
+	if source.EndedAt == nil {
+		return nil, ErrAttemptNotSubmitted
+	}
+
+	questionIDs, err := s.repo.GetAttemptMissedQuestionIDs(ctx, attemptID)
+	if err != nil {
+		return nil, err
+	}
+	return s.startReview(ctx, userID, retakeExamName, "Retake of the missed questions of an attempt", questionIDs, tutor)
+}
+
+// StartBookmarkReview starts an attempt over the user's bookmarks.
+func (s *Service) StartBookmarkReview(ctx context.Context, userID uuid.UUID, tutor bool) (*models.Attempt, error) {
+	bookmarks, err := s.repo.ListBookmarks(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	questionIDs := make([]int, 0, len(bookmarks))
+	for _, bookmark := range bookmarks {
+		questionIDs = append(questionIDs, bookmark.QuestionID)
+	}
+	return s.startReview(ctx, userID, bookmarksExamName, "Practice of bookmarked questions", questionIDs, tutor)
+}
+
+// StartMissedReview starts an attempt over every question the user got wrong
+// or left unanswered in attempts submitted in the last days; zero days means
+// defaultReviewDays.
+func (s *Service) StartMissedReview(ctx context.Context, userID uuid.UUID, days int, tutor bool) (*models.Attempt, error) {
+	if days == 0 {
+		days = defaultReviewDays
+	}
+	if days < 1 || days > maxReviewDays {
+		return nil, ErrInvalidReviewWindow
+	}
+
+	questionIDs, err := s.repo.FindMissedQuestionIDs(ctx, userID, time.Now().AddDate(0, 0, -days))
+	if err != nil {
+		return nil, err
+	}
+	return s.startReview(ctx, userID, missedExamName, "Review of recently missed questions", questionIDs, tutor)
+}
+
+// startReview starts an attempt over a fixed question set in a shuffled
+// order. A retake keeps every missed question; other sets are cut to their
+// first maxReviewQuestions.
+func (s *Service) startReview(ctx context.Context, userID uuid.UUID, examName, description string, questionIDs []int, tutor bool) (*models.Attempt, error) {
+	if len(questionIDs) == 0 {
+		return nil, ErrNothingToReview
+	}
+	if examName != retakeExamName && len(questionIDs) > maxReviewQuestions {
+		questionIDs = questionIDs[:maxReviewQuestions]
+	}
+
+	exam, err := s.repo.GetExamByName(ctx, examName)
+	if err != nil {
+		return nil, err
+	}
+	if exam == nil {
+		exam, err = s.repo.CreateExam(ctx, examName, description)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	spec := attemptSpec{
+		draw: func(ctx context.Context, attempt *models.Attempt, exam *models.Exam) ([]int, error) {
+			selected := append([]int(nil), questionIDs...)
+			rng := rand.New(rand.NewSource(attempt.Seed))
+			rng.Shuffle(len(selected), func(i, j int) {
+				selected[i], selected[j] = selected[j], selected[i]
+			})
+			return selected, nil
+		},
+	}
+	if tutor {
+		spec.feedback = feedbackImmediate
+	}
+	return s.createAttempt(ctx, userID, exam, len(questionIDs), spec)
+}
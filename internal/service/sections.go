@@ -0,0 +1,429 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"capm-exam-system/internal/metrics"
+	"capm-exam-system/internal/models"
+	"capm-exam-system/internal/repository"
+	"capm-exam-system/internal/tracing"
+
+	"github.com/google/uuid"
+)
+
+const (
+	sectionStatusNone      = "none"
+	sectionStatusPending   = "pending"
+	sectionStatusOpen      = "open"
+	sectionStatusBreak     = "break"
+	sectionStatusCompleted = "completed"
+
+	// sectionSubmitGrace absorbs network latency for clients that auto-submit
+	// exactly when the section clock runs out.
+	sectionSubmitGrace = 30 * time.Second
+)
+
+// hasSections reports whether the blueprint splits an attempt of the given
+// size into sections. Blueprints whose sections do not cover the whole
+// attempt are treated as flat.
+func hasSections(blueprint attemptBlueprint, questionCount int) bool {
+	if len(blueprint.sections) == 0 {
+		return false
+	}
+	total := 0
+	for _, section := range blueprint.sections {
+		total += section.questionCount
+	}
+	return total == questionCount
+}
+
+func isSectioned(attempt *models.Attempt, blueprint attemptBlueprint) bool {
+	return attempt.SectionStatus != sectionStatusNone && hasSections(blueprint, attempt.MaxScore)
+}
+
+// sectionBounds returns the [start, end) slice of the drawn question list
+// that belongs to the section at index.
+func sectionBounds(blueprint attemptBlueprint, index int) (int, int) {
+	start := 0
+	for i := 0; i < index; i++ {
+		start += blueprint.sections[i].questionCount
+	}
+	return start, start + blueprint.sections[index].questionCount
+}
+
+func (s *Service) GetSectionState(ctx context.Context, attemptID uuid.UUID) (*models.SectionState, error) {
+	attempt, blueprint, err := s.loadSectionedAttempt(ctx, attemptID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	if err := s.syncSections(ctx, attempt, blueprint, now); err != nil {
+		return nil, err
+	}
+
+	return buildSectionState(attempt, blueprint, now), nil
+}
+
+// OpenSection starts the clock on the current section. It is used both to
+// begin the first section and to resume after a break; resuming early simply
+// skips the rest of the break.
+func (s *Service) OpenSection(ctx context.Context, attemptID uuid.UUID) (*models.SectionState, error) {
+	attempt, blueprint, err := s.loadSectionedAttempt(ctx, attemptID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	if err := s.syncSections(ctx, attempt, blueprint, now); err != nil {
+		return nil, err
+	}
+
+	fromSection, fromStatus := attempt.CurrentSection, attempt.SectionStatus
+	switch attempt.SectionStatus {
+	case sectionStatusPending:
+		// First section keeps its index.
+	case sectionStatusBreak:
+		attempt.CurrentSection++
+	case sectionStatusOpen:
+		return buildSectionState(attempt, blueprint, now), nil
+	default:
+		return nil, ErrAttemptAlreadyClosed
+	}
+
+	attempt.SectionStatus = sectionStatusOpen
+	attempt.SectionStartedAt = &now
+	attempt.BreakStartedAt = nil
+	if err := s.saveSectionState(ctx, attempt, fromSection, fromStatus); err != nil {
+		return nil, err
+	}
+
+	state := buildSectionState(attempt, blueprint, now)
+	s.audit(ctx, &attempt.UserID, "attempt.section_opened", "attempt", attemptID.String(), nil, state)
+	return state, nil
+}
+
+// SubmitSection records the answers for the open section and closes it. The
+// attempt moves to a break, or is graded when the last section is submitted.
+func (s *Service) SubmitSection(ctx context.Context, attemptID uuid.UUID, index int, submission models.ExamSubmission) (*models.SectionState, error) {
+	attempt, blueprint, err := s.loadSectionedAttempt(ctx, attemptID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	if err := s.syncSections(ctx, attempt, blueprint, now); err != nil {
+		return nil, err
+	}
+
+	if attempt.SectionStatus == sectionStatusCompleted || index < attempt.CurrentSection ||
+		(index == attempt.CurrentSection && attempt.SectionStatus == sectionStatusBreak) {
+		return nil, ErrSectionClosed
+	}
+	if index != attempt.CurrentSection || attempt.SectionStatus != sectionStatusOpen {
+		return nil, ErrSectionNotOpen
+	}
+
+	answers, selections, err := s.gradeSectionAnswers(ctx, attempt, blueprint, submission)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.closeSection(ctx, attempt, blueprint, now, answers, selections); err != nil {
+		return nil, err
+	}
+
+	state := buildSectionState(attempt, blueprint, now)
+	s.audit(ctx, &attempt.UserID, "attempt.section_submitted", "attempt", attemptID.String(), nil, map[string]interface{}{
+		"section": index,
+		"answers": submission.Answers,
+		"state":   state,
+	})
+	return state, nil
+}
+
+func (s *Service) loadSectionedAttempt(ctx context.Context, attemptID uuid.UUID) (*models.Attempt, attemptBlueprint, error) {
+	attempt, err := s.repo.GetAttempt(ctx, attemptID)
+	if err != nil {
+		return nil, attemptBlueprint{}, err
+	}
+	if attempt == nil {
+		return nil, attemptBlueprint{}, ErrAttemptNotFound
+	}
+
+	exam, err := s.repo.GetExamByID(ctx, attempt.ExamID)
+	if err != nil {
+		return nil, attemptBlueprint{}, err
+	}
+	if exam == nil {
+		return nil, attemptBlueprint{}, ErrExamNotFound
+	}
+
+	blueprint := blueprintForExam(exam.Name, attempt.MaxScore)
+	if !isSectioned(attempt, blueprint) {
+		return nil, attemptBlueprint{}, ErrAttemptNotSectioned
+	}
+
+	return attempt, blueprint, nil
+}
+
+// syncSections applies any transitions that happened on the clock since the
+// attempt was last touched: an open section whose time ran out is closed with
+// the selections recorded before its deadline, and an overrun break starts
+// the next section's clock at the moment the break expired.
+func (s *Service) syncSections(ctx context.Context, attempt *models.Attempt, blueprint attemptBlueprint, now time.Time) error {
+	for {
+		switch attempt.SectionStatus {
+		case sectionStatusOpen:
+			deadline, ok := sectionDeadline(attempt, blueprint)
+			if !ok || !now.After(deadline.Add(sectionSubmitGrace)) {
+				return nil
+			}
+			recorded, err := s.repo.GetAnswerSelections(ctx, attempt.ID)
+			if err != nil {
+				return err
+			}
+			answers, selections, err := s.gradeSectionAnswers(ctx, attempt, blueprint, models.ExamSubmission{
+				Answers: recordedAnswers(recorded, deadline.Add(sectionSubmitGrace)),
+			})
+			if err != nil {
+				return err
+			}
+			err = s.closeSection(ctx, attempt, blueprint, deadline, answers, selections)
+			if errors.Is(err, ErrSectionClosed) || errors.Is(err, ErrAttemptAlreadyClosed) {
+				// Another request closed it first; carry on from its state
+				err = s.reloadAttempt(ctx, attempt)
+			}
+			if err != nil {
+				return err
+			}
+		case sectionStatusBreak:
+			if attempt.BreakStartedAt == nil || blueprint.breakLimit <= 0 {
+				return nil
+			}
+			breakEnd := attempt.BreakStartedAt.Add(blueprint.breakLimit)
+			if !now.After(breakEnd) {
+				return nil
+			}
+			fromSection := attempt.CurrentSection
+			attempt.CurrentSection++
+			attempt.SectionStatus = sectionStatusOpen
+			attempt.SectionStartedAt = &breakEnd
+			attempt.BreakStartedAt = nil
+			err := s.saveSectionState(ctx, attempt, fromSection, sectionStatusBreak)
+			if errors.Is(err, ErrAttemptAlreadyClosed) {
+				// Another request moved it on first; carry on from its state
+				err = s.reloadAttempt(ctx, attempt)
+			}
+			if err != nil {
+				return err
+			}
+		default:
+			return nil
+		}
+	}
+}
+
+// closeSection ends the current section at the given time, storing the
+// answers given in it in the same transaction. Closing the last section grades
+// the attempt from every answer stored. It fails with ErrSectionClosed when
+// another request closed the section first.
+func (s *Service) closeSection(ctx context.Context, attempt *models.Attempt, blueprint attemptBlueprint, closedAt time.Time, answers []models.GradedChoice, selections []models.AnswerSelection) error {
+	if attempt.CurrentSection >= len(blueprint.sections)-1 {
+		return s.finalizeSectionedAttempt(ctx, attempt, answers, selections, "")
+	}
+
+	_, closed, err := s.repo.CloseSection(ctx, models.SectionClose{
+		AttemptID:      attempt.ID,
+		Section:        attempt.CurrentSection,
+		Status:         attempt.SectionStatus,
+		Answers:        answers,
+		Selections:     selections,
+		BreakStartedAt: &closedAt,
+	})
+	if err != nil {
+		return err
+	}
+	if !closed {
+		return ErrSectionClosed
+	}
+
+	attempt.SectionStatus = sectionStatusBreak
+	attempt.BreakStartedAt = &closedAt
+	return nil
+}
+
+// finalizeSectionedAttempt stores the last answers, scores every stored
+// answer and closes the attempt in one transaction, forfeiting any section
+// not yet taken. It fails with ErrAttemptAlreadyClosed when another request
+// moved the attempt on first.
+func (s *Service) finalizeSectionedAttempt(ctx context.Context, attempt *models.Attempt, answers []models.GradedChoice, selections []models.AnswerSelection, idempotencyKey string) error {
+	ctx, span := tracing.Start(ctx, "service.finalizeSectionedAttempt", tracing.AttemptID(attempt.ID))
+	defer span.End()
+
+	start := time.Now()
+	score, closed, err := s.repo.CloseSection(ctx, models.SectionClose{
+		AttemptID:      attempt.ID,
+		Section:        attempt.CurrentSection,
+		Status:         attempt.SectionStatus,
+		Answers:        answers,
+		Selections:     selections,
+		Final:          true,
+		IdempotencyKey: idempotencyKey,
+	})
+	if err != nil {
+		return err
+	}
+	if !closed {
+		return ErrAttemptAlreadyClosed
+	}
+
+	now := time.Now()
+	attempt.Score = &score
+	attempt.EndedAt = &now
+	attempt.SectionStatus = sectionStatusCompleted
+	attempt.BreakStartedAt = nil
+
+	// The attempt is already graded; a failed lookup only costs the sample.
+	if exam, err := s.repo.GetExamByID(ctx, attempt.ExamID); err == nil && exam != nil {
+		examType := repository.AttemptType(exam.Name, attempt.MaxScore)
+		span.SetAttributes(tracing.ExamType(examType))
+		metrics.GradingDuration.WithLabelValues(examType).Observe(metrics.Since(start))
+		metrics.AttemptsSubmitted.WithLabelValues(examType).Inc()
+	}
+	return nil
+}
+
+// gradeSectionAnswers grades the submitted answers that belong to the current
+// section and returns them with the selections not yet in the history;
+// answers for any other question are ignored.
+func (s *Service) gradeSectionAnswers(ctx context.Context, attempt *models.Attempt, blueprint attemptBlueprint, submission models.ExamSubmission) ([]models.GradedChoice, []models.AnswerSelection, error) {
+	questionIDs, err := s.currentSectionQuestionIDs(ctx, attempt, blueprint)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	questions, err := s.repo.GetQuestionsWithChoices(ctx, questionIDs)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	questionMap := make(map[int]models.QuestionWithChoices, len(questions))
+	for _, q := range questions {
+		questionMap[q.ID] = q
+	}
+
+	answersByQuestion := normalizeAnswers(questionMap, submission)
+	selections, err := s.missingSelections(ctx, attempt.ID, answersByQuestion)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	answers := make([]models.GradedChoice, 0, len(answersByQuestion))
+	for qID, selectedIDs := range answersByQuestion {
+		_, isCorrect := gradeSelection(questionMap[qID], selectedIDs)
+		for _, choiceID := range selectedIDs {
+			answers = append(answers, models.GradedChoice{QuestionID: qID, ChoiceID: choiceID, IsCorrect: isCorrect})
+		}
+	}
+
+	return answers, selections, nil
+}
+
+// reloadAttempt replaces attempt with its stored state.
+func (s *Service) reloadAttempt(ctx context.Context, attempt *models.Attempt) error {
+	stored, err := s.repo.GetAttempt(ctx, attempt.ID)
+	if err != nil {
+		return err
+	}
+	if stored == nil {
+		return ErrAttemptNotFound
+	}
+	*attempt = *stored
+	return nil
+}
+
+func (s *Service) currentSectionQuestionIDs(ctx context.Context, attempt *models.Attempt, blueprint attemptBlueprint) ([]int, error) {
+	exam, err := s.repo.GetExamByID(ctx, attempt.ExamID)
+	if err != nil {
+		return nil, err
+	}
+	if exam == nil {
+		return nil, ErrExamNotFound
+	}
+
+	questionIDs, err := s.pickQuestionIDs(ctx, attempt, exam)
+	if err != nil {
+		return nil, err
+	}
+
+	start, end := sectionBounds(blueprint, attempt.CurrentSection)
+	return questionIDs[start:end], nil
+}
+
+// saveSectionState stores the section state of attempt, which was loaded in
+// fromSection with fromStatus. It fails with ErrAttemptAlreadyClosed when
+// another request moved the attempt on first.
+func (s *Service) saveSectionState(ctx context.Context, attempt *models.Attempt, fromSection int, fromStatus string) error {
+	updated, err := s.repo.UpdateAttemptSection(ctx, attempt, fromSection, fromStatus)
+	if err != nil {
+		return err
+	}
+	if !updated {
+		return ErrAttemptAlreadyClosed
+	}
+	return nil
+}
+
+func sectionDeadline(attempt *models.Attempt, blueprint attemptBlueprint) (time.Time, bool) {
+	limit := blueprint.sections[attempt.CurrentSection].timeLimit
+	if limit <= 0 || attempt.SectionStartedAt == nil {
+		return time.Time{}, false
+	}
+	return attempt.SectionStartedAt.Add(limit), true
+}
+
+func buildSectionState(attempt *models.Attempt, blueprint attemptBlueprint, now time.Time) *models.SectionState {
+	state := &models.SectionState{
+		AttemptID:      attempt.ID,
+		Status:         attempt.SectionStatus,
+		CurrentSection: attempt.CurrentSection,
+		Sections:       make([]models.SectionInfo, 0, len(blueprint.sections)),
+		ServerTime:     now,
+	}
+
+	for i, spec := range blueprint.sections {
+		status := "upcoming"
+		switch {
+		case attempt.SectionStatus == sectionStatusCompleted || i < attempt.CurrentSection:
+			status = "closed"
+		case i == attempt.CurrentSection && attempt.SectionStatus == sectionStatusOpen:
+			status = "open"
+		case i == attempt.CurrentSection && attempt.SectionStatus == sectionStatusBreak:
+			status = "closed"
+		}
+
+		state.Sections = append(state.Sections, models.SectionInfo{
+			Index:            i,
+			QuestionCount:    spec.questionCount,
+			TimeLimitSeconds: int(spec.timeLimit.Seconds()),
+			Status:           status,
+		})
+	}
+
+	switch attempt.SectionStatus {
+	case sectionStatusOpen:
+		if deadline, ok := sectionDeadline(attempt, blueprint); ok {
+			state.SectionEndsAt = &deadline
+		}
+	case sectionStatusBreak:
+		if attempt.BreakStartedAt != nil && blueprint.breakLimit > 0 {
+			breakEnd := attempt.BreakStartedAt.Add(blueprint.breakLimit)
+			state.BreakEndsAt = &breakEnd
+		}
+	}
+
+	return state
+}
@@ -4,23 +4,70 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"log/slog"
 	"math/rand"
 	"time"
 
+	"capm-exam-system/internal/config"
+	"capm-exam-system/internal/metrics"
 	"capm-exam-system/internal/models"
 	"capm-exam-system/internal/repository"
+	"capm-exam-system/internal/tracing"
 
 	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 type Service struct {
-	repo *repository.Repository
+	repo   *repository.Repository
+	config config.ExamConfig
 }
 
 var (
-	ErrAttemptNotFound      = errors.New("attempt not found")
-	ErrAttemptAlreadyClosed = errors.New("attempt already completed")
-	ErrAttemptForbidden     = errors.New("user cannot modify this attempt")
+	ErrAttemptNotFound        = errors.New("attempt not found")
+	ErrAttemptAlreadyClosed   = errors.New("attempt already completed")
+	ErrAttemptForbidden       = errors.New("user cannot modify this attempt")
+	ErrExamNotFound           = errors.New("exam not found")
+	ErrAttemptNotSectioned    = errors.New("attempt is not divided into sections")
+	ErrSectionClosed          = errors.New("section is already closed")
+	ErrSectionNotOpen         = errors.New("section is not open")
+	ErrEventBatchTooLarge     = errors.New("too many events in one batch")
+	ErrAttemptNotSubmitted    = errors.New("exam not yet submitted")
+	ErrCohortNotFound         = errors.New("cohort not found")
+	ErrCohortForbidden        = errors.New("user cannot access this cohort")
+	ErrInvalidCohort          = errors.New("cohort name is required")
+	ErrInviteCodeInvalid      = errors.New("invite code not recognised")
+	ErrInviteCodeExhausted    = errors.New("no unused invite code found")
+	ErrInvalidAssignment      = errors.New("unknown assignment kind")
+	ErrReportNotFound         = errors.New("question report not found")
+	ErrInvalidReport          = errors.New("invalid question report")
+	ErrInvalidKeyCorrection   = errors.New("invalid answer key correction")
+	ErrQuestionNotInAttempt   = errors.New("question was not served in this attempt")
+	ErrQuestionNotFound       = errors.New("question not found")
+	ErrRegradeJobNotFound     = errors.New("regrade job not found")
+	ErrInvalidFamily          = errors.New("invalid question family")
+	ErrInvalidEnemyPair       = errors.New("a question cannot be its own enemy")
+	ErrEnemyPairNotFound      = errors.New("enemy pair not found")
+	ErrInvalidExamLength      = errors.New("unsupported custom exam")
+	ErrInvalidPracticeQuiz    = errors.New("invalid practice quiz options")
+	ErrNotEnoughQuestions     = errors.New("not enough questions match the options")
+	ErrFeedbackNotImmediate   = errors.New("attempt does not give immediate feedback")
+	ErrTimeExpired            = errors.New("attempt time limit has passed")
+	ErrEmptyAnswer            = errors.New("answer selects no choice of the question")
+	ErrUserNotFound           = errors.New("user not found")
+	ErrBookmarkNotFound       = errors.New("bookmark not found")
+	ErrInvalidReviewWindow    = errors.New("invalid review window")
+	ErrNothingToReview        = errors.New("no questions to review")
+	ErrChallengeNotFound      = errors.New("challenge not found")
+	ErrNoQuestionSet          = errors.New("attempt has no stored question set")
+	ErrGroupSessionNotFound   = errors.New("group session not found")
+	ErrGroupSessionForbidden  = errors.New("user cannot access this group session")
+	ErrInvalidGroupSession    = errors.New("invalid group session")
+	ErrJoinCodeInvalid        = errors.New("join code not recognised")
+	ErrGroupSessionStarted    = errors.New("group session already started")
+	ErrGroupSessionNotRunning = errors.New("group session is not running")
+	ErrGroupSessionEnded      = errors.New("group session has ended")
+	ErrInvalidExtension       = errors.New("invalid group session extension")
 )
 
 const (
@@ -31,8 +78,8 @@ const (
 	shortQuizHardTarget = 3
 )
 
-func New(repo *repository.Repository) *Service {
-	return &Service{repo: repo}
+func New(repo *repository.Repository, cfg config.ExamConfig) *Service {
+	return &Service{repo: repo, config: cfg}
 }
 
 func (s *Service) GetOrCreateUser(ctx context.Context, email, name string) (*models.User, error) {
@@ -48,20 +95,24 @@ func (s *Service) GetOrCreateUser(ctx context.Context, email, name string) (*mod
 		if err != nil {
 			return nil, err
 		}
+		s.audit(ctx, &user.ID, "user.created", "user", user.ID.String(), nil, user)
 	}
 
 	return user, nil
 }
 
-func (s *Service) StartExam(ctx context.Context, userID uuid.UUID) (*models.Attempt, error) {
-	return s.StartExamWithQuestionCount(ctx, userID, 150)
+// StartExam starts a full CAPM mock. Like every start method it takes tutor:
+// a tutor attempt is neither sectioned nor timed, and each answer is locked
+// in and revealed through AnswerQuestion as the candidate goes.
+func (s *Service) StartExam(ctx context.Context, userID uuid.UUID, tutor bool) (*models.Attempt, error) {
+	return s.StartExamWithQuestionCount(ctx, userID, 150, tutor)
 }
 
-func (s *Service) StartShortQuiz(ctx context.Context, userID uuid.UUID) (*models.Attempt, error) {
-	return s.StartExamWithQuestionCount(ctx, userID, 15)
+func (s *Service) StartShortQuiz(ctx context.Context, userID uuid.UUID, tutor bool) (*models.Attempt, error) {
+	return s.StartExamWithQuestionCount(ctx, userID, 15, tutor)
 }
 
-func (s *Service) StartPMPExam(ctx context.Context, userID uuid.UUID) (*models.Attempt, error) {
+func (s *Service) StartPMPExam(ctx context.Context, userID uuid.UUID, tutor bool) (*models.Attempt, error) {
 	exam, err := s.repo.GetExamByName(ctx, pmpExamName)
 	if err != nil {
 		return nil, err
@@ -74,10 +125,47 @@ func (s *Service) StartPMPExam(ctx context.Context, userID uuid.UUID) (*models.A
 		}
 	}
 
-	return s.createAttemptForExam(ctx, userID, exam, 150)
+	return s.createAttemptForExam(ctx, userID, exam, 150, tutor)
 }
 
-func (s *Service) StartExamWithQuestionCount(ctx context.Context, userID uuid.UUID, questionCount int) (*models.Attempt, error) {
+// customExamLengths are the lengths a learner can pick for a custom mock.
+var customExamLengths = []int{30, 60, 90}
+
+// StartCustomExam starts a CAPM ("capm") or PMP ("pmp") mock of one of the
+// custom lengths, with the full exam's domain proportions.
+func (s *Service) StartCustomExam(ctx context.Context, userID uuid.UUID, examKind string, questionCount int, tutor bool) (*models.Attempt, error) {
+	allowed := false
+	for _, length := range customExamLengths {
+		if questionCount == length {
+			allowed = true
+			break
+		}
+	}
+	if !allowed {
+		return nil, ErrInvalidExamLength
+	}
+
+	switch examKind {
+	case "", "capm":
+		return s.StartExamWithQuestionCount(ctx, userID, questionCount, tutor)
+	case "pmp":
+		exam, err := s.repo.GetExamByName(ctx, pmpExamName)
+		if err != nil {
+			return nil, err
+		}
+		if exam == nil {
+			exam, err = s.repo.CreateExam(ctx, pmpExamName, "150-question PMP scenario exam")
+			if err != nil {
+				return nil, err
+			}
+		}
+		return s.createAttemptForExam(ctx, userID, exam, questionCount, tutor)
+	default:
+		return nil, ErrInvalidExamLength
+	}
+}
+
+func (s *Service) StartExamWithQuestionCount(ctx context.Context, userID uuid.UUID, questionCount int, tutor bool) (*models.Attempt, error) {
 	// Get or create default exam
 	exam, err := s.repo.GetExamByName(ctx, defaultExamName)
 	if err != nil {
@@ -91,33 +179,36 @@ func (s *Service) StartExamWithQuestionCount(ctx context.Context, userID uuid.UU
 		}
 	}
 
-	return s.createAttemptForExam(ctx, userID, exam, questionCount)
+	return s.createAttemptForExam(ctx, userID, exam, questionCount, tutor)
 }
 
-func (s *Service) StartHardDrill(ctx context.Context, userID uuid.UUID) (*models.Attempt, error) {
+func (s *Service) StartHardDrill(ctx context.Context, userID uuid.UUID, tutor bool) (*models.Attempt, error) {
 	exam, err := s.repo.GetExamByName(ctx, hardExamName)
 	if err != nil {
 		return nil, err
 	}
 
 	if exam == nil {
-		exam, err = s.repo.CreateExam(ctx, hardExamName, "20-question advanced CAPM scenario drill")
+		exam, err = s.repo.CreateExam(ctx, hardExamName, "Advanced CAPM scenario drill")
 		if err != nil {
 			return nil, err
 		}
 	}
 
-	return s.createAttemptForExam(ctx, userID, exam, 20)
+	return s.createAttemptForExam(ctx, userID, exam, s.config.HardDrillLength, tutor)
 }
 
 func (s *Service) GetExamQuestions(ctx context.Context, attemptID uuid.UUID) ([]models.QuestionWithChoices, error) {
+	ctx, span := tracing.Start(ctx, "service.GetExamQuestions", tracing.AttemptID(attemptID))
+	defer span.End()
+
 	// Get attempt to verify it exists and get seed
 	attempt, err := s.repo.GetAttempt(ctx, attemptID)
 	if err != nil {
 		return nil, err
 	}
 	if attempt == nil {
-		return nil, fmt.Errorf("attempt not found")
+		return nil, ErrAttemptNotFound
 	}
 
 	exam, err := s.repo.GetExamByID(ctx, attempt.ExamID)
@@ -125,44 +216,67 @@ func (s *Service) GetExamQuestions(ctx context.Context, attemptID uuid.UUID) ([]
 		return nil, err
 	}
 	if exam == nil {
-		return nil, fmt.Errorf("exam not found")
+		return nil, ErrExamNotFound
 	}
+	span.SetAttributes(tracing.ExamType(repository.AttemptType(exam.Name, attempt.MaxScore)))
 
 	questionIDs, err := s.pickQuestionIDs(ctx, attempt, exam)
 	if err != nil {
 		return nil, err
 	}
 
+	// Sectioned exams only ever serve the section that is currently open
+	if blueprint := blueprintForExam(exam.Name, attempt.MaxScore); isSectioned(attempt, blueprint) {
+		if err := s.syncSections(ctx, attempt, blueprint, time.Now().UTC()); err != nil {
+			return nil, err
+		}
+		switch attempt.SectionStatus {
+		case sectionStatusOpen:
+			start, end := sectionBounds(blueprint, attempt.CurrentSection)
+			questionIDs = questionIDs[start:end]
+		case sectionStatusCompleted:
+			return nil, ErrAttemptAlreadyClosed
+		default:
+			return nil, ErrSectionNotOpen
+		}
+	}
+
 	// Get questions with choices
 	questions, err := s.repo.GetQuestionsWithChoices(ctx, questionIDs)
 	if err != nil {
 		return nil, err
 	}
+	orderChoicesForAttempt(attempt, questions)
 
-	// Remove explanation and correct answers from the response
-	for i := range questions {
-		questions[i].Explanation = "" // Hide explanation until submission
-		for j := range questions[i].Choices {
-			questions[i].Choices[j].IsCorrect = false // Hide correct answers
-		}
-	}
-
+	hideAnswers(questions)
 	return questions, nil
 }
 
 func (s *Service) SubmitExam(ctx context.Context, attemptID uuid.UUID, submission models.ExamSubmission) (*models.ExamResult, error) {
+	ctx, span := tracing.Start(ctx, "service.SubmitExam", tracing.AttemptID(attemptID))
+	defer span.End()
+
 	// Get attempt
 	attempt, err := s.repo.GetAttempt(ctx, attemptID)
 	if err != nil {
 		return nil, err
 	}
 	if attempt == nil {
-		return nil, fmt.Errorf("attempt not found")
+		return nil, ErrAttemptNotFound
 	}
 
-	// Check if already submitted
+	// A retry of a submit that already went through gets the original result
 	if attempt.EndedAt != nil {
-		return nil, fmt.Errorf("exam already submitted")
+		return s.replaySubmission(ctx, attempt, submission.IdempotencyKey)
+	}
+
+	// Past the deadline only what was recorded in time counts
+	if deadline, ok := attemptDeadline(attempt); ok && attemptExpired(attempt, time.Now()) {
+		selections, err := s.repo.GetAnswerSelections(ctx, attemptID)
+		if err != nil {
+			return nil, err
+		}
+		submission.Answers = recordedAnswers(selections, deadline.Add(sectionSubmitGrace))
 	}
 
 	exam, err := s.repo.GetExamByID(ctx, attempt.ExamID)
@@ -170,7 +284,23 @@ func (s *Service) SubmitExam(ctx context.Context, attemptID uuid.UUID, submissio
 		return nil, err
 	}
 	if exam == nil {
-		return nil, fmt.Errorf("exam not found")
+		return nil, ErrExamNotFound
+	}
+
+	examType := repository.AttemptType(exam.Name, attempt.MaxScore)
+	span.SetAttributes(tracing.ExamType(examType))
+	gradingStart := time.Now()
+
+	if blueprint := blueprintForExam(exam.Name, attempt.MaxScore); isSectioned(attempt, blueprint) {
+		result, err := s.submitSectionedExam(ctx, attempt, blueprint, submission)
+		if errors.Is(err, ErrAttemptAlreadyClosed) {
+			return s.replayClosedAttempt(ctx, attemptID, submission.IdempotencyKey)
+		}
+		if err != nil {
+			return nil, err
+		}
+		s.auditSubmission(ctx, attempt, result)
+		return result, nil
 	}
 
 	questionIDs, err := s.pickQuestionIDs(ctx, attempt, exam)
@@ -182,6 +312,7 @@ func (s *Service) SubmitExam(ctx context.Context, attemptID uuid.UUID, submissio
 	if err != nil {
 		return nil, err
 	}
+	orderChoicesForAttempt(attempt, questions)
 
 	// Create a map for quick lookup
 	questionMap := make(map[int]models.QuestionWithChoices)
@@ -190,84 +321,34 @@ func (s *Service) SubmitExam(ctx context.Context, attemptID uuid.UUID, submissio
 	}
 
 	// Normalize submitted answers by question
-	answersByQuestion := make(map[int][]int)
-	for _, answer := range submission.Answers {
-		question, exists := questionMap[answer.QuestionID]
-		if !exists {
-			continue
-		}
-
-		if len(answer.ChoiceIDs) == 0 {
-			continue
-		}
-
-		selectedSet := make(map[int]struct{})
-		for _, choiceID := range answer.ChoiceIDs {
-			if choiceID <= 0 {
-				continue
-			}
-			selectedSet[choiceID] = struct{}{}
-		}
-
-		if len(selectedSet) == 0 {
-			continue
-		}
-
-		orderedSelection := make([]int, 0, len(selectedSet))
-		for _, choice := range question.Choices {
-			if _, ok := selectedSet[choice.ID]; ok {
-				orderedSelection = append(orderedSelection, choice.ID)
-			}
-		}
-
-		if len(orderedSelection) == 0 {
-			continue
-		}
-
-		answersByQuestion[answer.QuestionID] = orderedSelection
+	answersByQuestion := normalizeAnswers(questionMap, submission)
+	if err := s.applyLockedAnswers(ctx, attempt, questionMap, answersByQuestion); err != nil {
+		return nil, err
+	}
+	selections, err := s.missingSelections(ctx, attemptID, answersByQuestion)
+	if err != nil {
+		return nil, err
 	}
 
 	score := 0
 	results := make([]models.QuestionResult, 0, len(questionIDs))
+	answers := make([]models.GradedChoice, 0, len(answersByQuestion))
 
 	for _, qID := range questionIDs {
 		question := questionMap[qID]
 
-		correctIDs := make([]int, 0)
-		correctSet := make(map[int]struct{})
-		for _, choice := range question.Choices {
-			if choice.IsCorrect {
-				correctIDs = append(correctIDs, choice.ID)
-				correctSet[choice.ID] = struct{}{}
-			}
-		}
-
 		selectedIDs, answered := answersByQuestion[qID]
 		if !answered {
 			selectedIDs = nil
 		}
 
-		isCorrect := false
-		if len(selectedIDs) > 0 && len(selectedIDs) == len(correctIDs) {
-			isCorrect = true
-			for _, sel := range selectedIDs {
-				if _, ok := correctSet[sel]; !ok {
-					isCorrect = false
-					break
-				}
-			}
-		}
-
+		correctIDs, isCorrect := gradeSelection(question, selectedIDs)
 		if isCorrect {
 			score++
 		}
 
-		if len(selectedIDs) > 0 {
-			for _, choiceID := range selectedIDs {
-				if err := s.repo.CreateAttemptAnswer(ctx, attemptID, question.ID, choiceID, isCorrect); err != nil {
-					return nil, err
-				}
-			}
+		for _, choiceID := range selectedIDs {
+			answers = append(answers, models.GradedChoice{QuestionID: question.ID, ChoiceID: choiceID, IsCorrect: isCorrect})
 		}
 
 		result := models.QuestionResult{
@@ -279,11 +360,23 @@ func (s *Service) SubmitExam(ctx context.Context, attemptID uuid.UUID, submissio
 		results = append(results, result)
 	}
 
-	// Update attempt with score
-	err = s.repo.UpdateAttemptScore(ctx, attemptID, score)
+	// Answers, selections, timings and the score are written atomically
+	submitted, err := s.repo.SubmitAttempt(ctx, models.AttemptSubmission{
+		AttemptID:      attemptID,
+		Score:          score,
+		Answers:        answers,
+		Selections:     selections,
+		IdempotencyKey: submission.IdempotencyKey,
+	})
 	if err != nil {
 		return nil, err
 	}
+	if !submitted {
+		// A concurrent submit closed the attempt first
+		return s.replayClosedAttempt(ctx, attemptID, submission.IdempotencyKey)
+	}
+	metrics.GradingDuration.WithLabelValues(examType).Observe(metrics.Since(gradingStart))
+	metrics.AttemptsSubmitted.WithLabelValues(examType).Inc()
 
 	now := time.Now()
 	attempt.Score = &score
@@ -295,27 +388,104 @@ func (s *Service) SubmitExam(ctx context.Context, attemptID uuid.UUID, submissio
 		ExamID:    attempt.ExamID,
 		Score:     score,
 		MaxScore:  attempt.MaxScore,
+		PassMark:  s.config.PassMark * 100,
+		Passed:    s.passed(score, attempt.MaxScore),
 		StartedAt: attempt.StartedAt,
 		EndedAt:   attempt.EndedAt,
 		Results:   results,
 	}
 
+	s.auditSubmission(ctx, attempt, examResult)
+
+	if err := s.attachPacing(ctx, examResult, blueprintForExam(exam.Name, attempt.MaxScore)); err != nil {
+		return nil, err
+	}
+
 	return examResult, nil
 }
 
+// replaySubmission answers a submit of a closed attempt. With the same
+// idempotency key as the submit that closed it, the request is a client retry
+// and gets the stored result; anything else is a conflict.
+func (s *Service) replaySubmission(ctx context.Context, attempt *models.Attempt, idempotencyKey string) (*models.ExamResult, error) {
+	if idempotencyKey == "" || attempt.SubmitKey == nil || *attempt.SubmitKey != idempotencyKey {
+		return nil, ErrAttemptAlreadyClosed
+	}
+	return s.GetExamResult(ctx, attempt.ID)
+}
+
+// replayClosedAttempt reloads an attempt that was closed while a submit was
+// grading it and replays the submission.
+func (s *Service) replayClosedAttempt(ctx context.Context, attemptID uuid.UUID, idempotencyKey string) (*models.ExamResult, error) {
+	attempt, err := s.repo.GetAttempt(ctx, attemptID)
+	if err != nil {
+		return nil, err
+	}
+	if attempt == nil {
+		return nil, ErrAttemptNotFound
+	}
+	return s.replaySubmission(ctx, attempt, idempotencyKey)
+}
+
+// auditSubmission records the graded outcome of a submit, including the
+// answers, so a disputed result can be reconstructed.
+func (s *Service) auditSubmission(ctx context.Context, attempt *models.Attempt, result *models.ExamResult) {
+	answers := make(map[int][]int, len(result.Results))
+	for _, r := range result.Results {
+		if len(r.UserChoiceIDs) > 0 {
+			answers[r.Question.ID] = r.UserChoiceIDs
+		}
+	}
+	s.audit(ctx, &attempt.UserID, "attempt.submitted", "attempt", attempt.ID.String(), nil, map[string]interface{}{
+		"score":     result.Score,
+		"max_score": result.MaxScore,
+		"ended_at":  result.EndedAt,
+		"answers":   answers,
+	})
+}
+
+// submitSectionedExam ends a sectioned attempt early: answers for the open
+// section are recorded, every remaining section is forfeited and the attempt
+// is graded from what was stored.
+func (s *Service) submitSectionedExam(ctx context.Context, attempt *models.Attempt, blueprint attemptBlueprint, submission models.ExamSubmission) (*models.ExamResult, error) {
+	if err := s.syncSections(ctx, attempt, blueprint, time.Now().UTC()); err != nil {
+		return nil, err
+	}
+
+	if attempt.SectionStatus != sectionStatusCompleted {
+		var answers []models.GradedChoice
+		var selections []models.AnswerSelection
+		if attempt.SectionStatus == sectionStatusOpen {
+			var err error
+			answers, selections, err = s.gradeSectionAnswers(ctx, attempt, blueprint, submission)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if err := s.finalizeSectionedAttempt(ctx, attempt, answers, selections, submission.IdempotencyKey); err != nil {
+			return nil, err
+		}
+	}
+
+	return s.GetExamResult(ctx, attempt.ID)
+}
+
 func (s *Service) GetExamResult(ctx context.Context, attemptID uuid.UUID) (*models.ExamResult, error) {
+	ctx, span := tracing.Start(ctx, "service.GetExamResult", tracing.AttemptID(attemptID))
+	defer span.End()
+
 	// Get attempt
 	attempt, err := s.repo.GetAttempt(ctx, attemptID)
 	if err != nil {
 		return nil, err
 	}
 	if attempt == nil {
-		return nil, fmt.Errorf("attempt not found")
+		return nil, ErrAttemptNotFound
 	}
 
 	// Check if exam was submitted
 	if attempt.EndedAt == nil || attempt.Score == nil {
-		return nil, fmt.Errorf("exam not yet submitted")
+		return nil, ErrAttemptNotSubmitted
 	}
 
 	exam, err := s.repo.GetExamByID(ctx, attempt.ExamID)
@@ -323,8 +493,9 @@ func (s *Service) GetExamResult(ctx context.Context, attemptID uuid.UUID) (*mode
 		return nil, err
 	}
 	if exam == nil {
-		return nil, fmt.Errorf("exam not found")
+		return nil, ErrExamNotFound
 	}
+	span.SetAttributes(tracing.ExamType(repository.AttemptType(exam.Name, attempt.MaxScore)))
 
 	questionIDs, err := s.pickQuestionIDs(ctx, attempt, exam)
 	if err != nil {
@@ -335,6 +506,7 @@ func (s *Service) GetExamResult(ctx context.Context, attemptID uuid.UUID) (*mode
 	if err != nil {
 		return nil, err
 	}
+	orderChoicesForAttempt(attempt, questions)
 
 	// Get user answers
 	answers, err := s.repo.GetAttemptAnswers(ctx, attemptID)
@@ -416,71 +588,145 @@ func (s *Service) GetExamResult(ctx context.Context, attemptID uuid.UUID) (*mode
 		ExamID:    attempt.ExamID,
 		Score:     *attempt.Score,
 		MaxScore:  attempt.MaxScore,
+		PassMark:  s.config.PassMark * 100,
+		Passed:    s.passed(*attempt.Score, attempt.MaxScore),
 		StartedAt: attempt.StartedAt,
 		EndedAt:   attempt.EndedAt,
 		Results:   results,
 	}
 
+	if err := s.attachPacing(ctx, examResult, blueprintForExam(exam.Name, attempt.MaxScore)); err != nil {
+		return nil, err
+	}
+
 	return examResult, nil
 }
 
 func (s *Service) GetUserAttemptHistory(ctx context.Context, userID uuid.UUID) ([]models.AttemptHistory, error) {
-	return s.repo.GetAttemptsByUser(ctx, userID)
+	history, err := s.repo.GetAttemptsByUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range history {
+		if history[i].Score != nil && history[i].MaxScore > 0 {
+			passed := s.passed(*history[i].Score, history[i].MaxScore)
+			history[i].Passed = &passed
+		}
+	}
+	return history, nil
+}
+
+// passed reports whether a score reaches the configured pass mark.
+func (s *Service) passed(score, maxScore int) bool {
+	return float64(score) >= s.config.PassMark*float64(maxScore)
+}
+
+// drillDomains are the domains served by the drill pages. Their answers are
+// checked one question at a time through CheckDrillAnswer.
+var drillDomains = map[string]struct{}{
+	"Earned Value Drill":                      {},
+	"PERT Drill":                              {},
+	"Stakeholder Salience Drill":              {},
+	"Project Operations Classification Drill": {},
+	"Team Motivation Drill":                   {},
 }
 
 func (s *Service) GetEarnedValueDrill(ctx context.Context, count int) ([]models.QuestionWithChoices, error) {
-	if count <= 0 {
-		count = 10
-	}
-	if count > 50 {
-		count = 50
+	count = s.config.Drills.EarnedValue.Count(count)
+	questions, err := s.repo.GetEarnedValueDrillQuestions(ctx, count)
+	if err != nil {
+		return nil, err
 	}
-
-	return s.repo.GetEarnedValueDrillQuestions(ctx, count)
+	hideAnswers(questions)
+	return questions, nil
 }
 
 func (s *Service) GetPertDrill(ctx context.Context, count int) ([]models.QuestionWithChoices, error) {
-	if count <= 0 {
-		count = 10
-	}
-	if count > 50 {
-		count = 50
+	count = s.config.Drills.Pert.Count(count)
+	questions, err := s.repo.GetPertDrillQuestions(ctx, count)
+	if err != nil {
+		return nil, err
 	}
-
-	return s.repo.GetPertDrillQuestions(ctx, count)
+	hideAnswers(questions)
+	return questions, nil
 }
 
 func (s *Service) GetStakeholderSalienceDrill(ctx context.Context, count int) ([]models.QuestionWithChoices, error) {
-	if count <= 0 {
-		count = 10
-	}
-	if count > 50 {
-		count = 50
+	count = s.config.Drills.StakeholderSalience.Count(count)
+	questions, err := s.repo.GetStakeholderSalienceDrillQuestions(ctx, count)
+	if err != nil {
+		return nil, err
 	}
-
-	return s.repo.GetStakeholderSalienceDrillQuestions(ctx, count)
+	hideAnswers(questions)
+	return questions, nil
 }
 
 func (s *Service) GetProjectOperationsDrill(ctx context.Context, count int) ([]models.QuestionWithChoices, error) {
-	if count <= 0 {
-		count = 15
-	}
-	if count > 20 {
-		count = 20
+	count = s.config.Drills.ProjectOperations.Count(count)
+	questions, err := s.repo.GetProjectOperationsDrillQuestions(ctx, count)
+	if err != nil {
+		return nil, err
 	}
-
-	return s.repo.GetProjectOperationsDrillQuestions(ctx, count)
+	hideAnswers(questions)
+	return questions, nil
 }
 
 func (s *Service) GetTeamMotivationDrill(ctx context.Context, count int) ([]models.QuestionWithChoices, error) {
-	if count <= 0 {
-		count = 20
+	count = s.config.Drills.TeamMotivation.Count(count)
+	questions, err := s.repo.GetTeamMotivationDrillQuestions(ctx, count)
+	if err != nil {
+		return nil, err
 	}
-	if count > 20 {
-		count = 20
+	hideAnswers(questions)
+	return questions, nil
+}
+
+// CheckDrillAnswer grades one answer to a drill question and reveals the key,
+// the explanation and each choice's rationale. Drills keep no attempt, so
+// nothing is stored.
+func (s *Service) CheckDrillAnswer(ctx context.Context, questionID int, choiceIDs []int) (*models.AnswerFeedback, error) {
+	questions, err := s.repo.GetQuestionsWithChoices(ctx, []int{questionID})
+	if err != nil {
+		return nil, err
 	}
+	if len(questions) == 0 {
+		return nil, ErrQuestionNotFound
+	}
+	// Exam questions are reported as missing rather than graded here
+	question := questions[0]
+	if _, ok := drillDomains[question.Domain]; !ok {
+		return nil, ErrQuestionNotFound
+	}
+
+	answers := normalizeAnswers(map[int]models.QuestionWithChoices{questionID: question}, models.ExamSubmission{
+		Answers: []models.AnswerSubmission{{QuestionID: questionID, ChoiceIDs: choiceIDs}},
+	})
+	selectedIDs := answers[questionID]
+	if len(selectedIDs) == 0 {
+		return nil, ErrEmptyAnswer
+	}
+
+	correctIDs, isCorrect := gradeSelection(question, selectedIDs)
+	return &models.AnswerFeedback{
+		QuestionID:       questionID,
+		ChoiceIDs:        selectedIDs,
+		CorrectChoiceIDs: correctIDs,
+		IsCorrect:        isCorrect,
+		Explanation:      question.Explanation,
+		Choices:          question.Choices,
+	}, nil
+}
 
-	return s.repo.GetTeamMotivationDrillQuestions(ctx, count)
+// hideAnswers removes the key, explanations and rationales from questions
+// served before they are answered.
+func hideAnswers(questions []models.QuestionWithChoices) {
+	for i := range questions {
+		questions[i].Explanation = ""
+		for j := range questions[i].Choices {
+			questions[i].Choices[j].IsCorrect = false
+			questions[i].Choices[j].Rationale = ""
+		}
+	}
 }
 
 func (s *Service) DeleteAttempt(ctx context.Context, userID, attemptID uuid.UUID) error {
@@ -500,83 +746,243 @@ func (s *Service) DeleteAttempt(ctx context.Context, userID, attemptID uuid.UUID
 		return ErrAttemptAlreadyClosed
 	}
 
-	return s.repo.DeleteAttempt(ctx, attemptID)
+	if err := s.repo.DeleteAttempt(ctx, attemptID); err != nil {
+		return err
+	}
+
+	s.audit(ctx, &userID, "attempt.deleted", "attempt", attemptID.String(), attempt, nil)
+	return nil
+}
+
+// attemptSpec is how an attempt runs beyond its exam and length.
+type attemptSpec struct {
+	// timeLimit of zero leaves the attempt untimed
+	timeLimit time.Duration
+	feedback  string
+	// draw picks the question set; nil draws from the exam's blueprint
+	draw func(ctx context.Context, attempt *models.Attempt, exam *models.Exam) ([]int, error)
+	// seed replays a frozen draw, choice order included; zero picks a new one
+	seed int64
+	// keepSections marks a draw that replays a blueprint draw in serving
+	// order, so the exam's sections still apply
+	keepSections bool
+	challengeID  *uuid.UUID
+}
+
+func (s *Service) createAttemptForExam(ctx context.Context, userID uuid.UUID, exam *models.Exam, questionCount int, tutor bool) (*models.Attempt, error) {
+	spec := attemptSpec{}
+	if tutor {
+		spec.feedback = feedbackImmediate
+	}
+	return s.createAttempt(ctx, userID, exam, questionCount, spec)
 }
 
-func (s *Service) createAttemptForExam(ctx context.Context, userID uuid.UUID, exam *models.Exam, questionCount int) (*models.Attempt, error) {
+func (s *Service) createAttempt(ctx context.Context, userID uuid.UUID, exam *models.Exam, questionCount int, spec attemptSpec) (*models.Attempt, error) {
 	if exam == nil {
 		return nil, fmt.Errorf("exam reference is nil")
 	}
+	if spec.feedback == "" {
+		spec.feedback = feedbackEnd
+	}
+	blueprintDraw := spec.draw == nil
+	if blueprintDraw {
+		spec.draw = s.drawQuestionIDs
+	}
+	var timeLimitSeconds *int
+	if spec.timeLimit > 0 {
+		seconds := int(spec.timeLimit.Seconds())
+		timeLimitSeconds = &seconds
+	}
 
-	seed := time.Now().UnixNano()
-	attempt, err := s.repo.CreateAttempt(ctx, userID, exam.ID, seed, questionCount)
+	examType := repository.AttemptType(exam.Name, questionCount)
+	ctx, span := tracing.Start(ctx, "service.createAttempt", tracing.ExamType(examType))
+	defer span.End()
+
+	// Tutor attempts are studied question by question, not under exam
+	// conditions, only blueprint draws follow the sections, and an overall
+	// time limit replaces the section timers
+	sectionStatus := sectionStatusNone
+	if (blueprintDraw || spec.keepSections) && spec.feedback != feedbackImmediate && spec.timeLimit == 0 &&
+		hasSections(blueprintForExam(exam.Name, questionCount), questionCount) {
+		sectionStatus = sectionStatusPending
+	}
+
+	seed := spec.seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	attempt, err := s.repo.CreateAttempt(ctx, userID, exam.ID, seed, questionCount, sectionStatus, timeLimitSeconds, spec.feedback, spec.challengeID)
 	if err != nil {
 		return nil, err
 	}
+	span.SetAttributes(tracing.AttemptID(attempt.ID))
 
+	questionIDs, err := spec.draw(ctx, attempt, exam)
+	if err == nil {
+		err = s.repo.CreateAttemptQuestions(ctx, attempt.ID, questionIDs)
+	}
+	if err != nil {
+		// Nobody can take an attempt without questions
+		if delErr := s.repo.DeleteAttempt(ctx, attempt.ID); delErr != nil {
+			slog.ErrorContext(ctx, "failed to remove attempt without questions", "attempt_id", attempt.ID, "error", delErr)
+		}
+		return nil, err
+	}
+
+	metrics.AttemptsStarted.WithLabelValues(examType).Inc()
+	s.audit(ctx, &userID, "attempt.started", "attempt", attempt.ID.String(), nil, map[string]interface{}{
+		"attempt":      attempt,
+		"exam_name":    exam.Name,
+		"question_ids": questionIDs,
+	})
 	return attempt, nil
 }
 
+// normalizeAnswers keeps, per question, the submitted choice IDs that belong to
+// that question, ordered as the choices are displayed.
+func normalizeAnswers(questionMap map[int]models.QuestionWithChoices, submission models.ExamSubmission) map[int][]int {
+	answersByQuestion := make(map[int][]int)
+	for _, answer := range submission.Answers {
+		question, exists := questionMap[answer.QuestionID]
+		if !exists {
+			continue
+		}
+
+		if len(answer.ChoiceIDs) == 0 {
+			continue
+		}
+
+		selectedSet := make(map[int]struct{})
+		for _, choiceID := range answer.ChoiceIDs {
+			if choiceID <= 0 {
+				continue
+			}
+			selectedSet[choiceID] = struct{}{}
+		}
+
+		if len(selectedSet) == 0 {
+			continue
+		}
+
+		orderedSelection := make([]int, 0, len(selectedSet))
+		for _, choice := range question.Choices {
+			if _, ok := selectedSet[choice.ID]; ok {
+				orderedSelection = append(orderedSelection, choice.ID)
+			}
+		}
+
+		if len(orderedSelection) == 0 {
+			continue
+		}
+
+		answersByQuestion[answer.QuestionID] = orderedSelection
+	}
+	return answersByQuestion
+}
+
+// gradeSelection returns the question's correct choice IDs and whether the
+// selection matches them exactly.
+func gradeSelection(question models.QuestionWithChoices, selectedIDs []int) ([]int, bool) {
+	correctIDs := make([]int, 0)
+	correctSet := make(map[int]struct{})
+	for _, choice := range question.Choices {
+		if choice.IsCorrect {
+			correctIDs = append(correctIDs, choice.ID)
+			correctSet[choice.ID] = struct{}{}
+		}
+	}
+
+	if len(selectedIDs) == 0 || len(selectedIDs) != len(correctIDs) {
+		return correctIDs, false
+	}
+	for _, sel := range selectedIDs {
+		if _, ok := correctSet[sel]; !ok {
+			return correctIDs, false
+		}
+	}
+	return correctIDs, true
+}
+
+// pickQuestionIDs returns the attempt's question set in serving order. Sets
+// are stored when the attempt starts; older attempts are replayed from their
+// seed with the draw they were made with.
 func (s *Service) pickQuestionIDs(ctx context.Context, attempt *models.Attempt, exam *models.Exam) ([]int, error) {
+	stored, err := s.repo.GetAttemptQuestionIDs(ctx, attempt.ID)
+	if err != nil {
+		return nil, err
+	}
+	if len(stored) > 0 {
+		return stored, nil
+	}
+
+	return s.legacyQuestionIDs(ctx, attempt, exam)
+}
+
+// drawQuestionIDs draws the question set of a new attempt, steering it away
+// from questions the learner saw recently and over-exposed ones.
+func (s *Service) drawQuestionIDs(ctx context.Context, attempt *models.Attempt, exam *models.Exam) ([]int, error) {
+	examType := repository.AttemptType(exam.Name, attempt.MaxScore)
+	ctx, span := tracing.Start(ctx, "service.drawQuestionIDs", tracing.AttemptID(attempt.ID), tracing.ExamType(examType))
+	defer span.End()
+	defer func(start time.Time) {
+		metrics.QuestionDrawDuration.WithLabelValues(examType).Observe(metrics.Since(start))
+	}(time.Now())
+
 	blueprint := blueprintForExam(exam.Name, attempt.MaxScore)
 
+	// One selection spans every domain so families, enemy pairs and
+	// exposure are respected across the whole attempt
+	sel := repository.NewSelection()
+	if err := s.applyExposure(ctx, attempt, sel); err != nil {
+		return nil, err
+	}
+	defer func() {
+		span.SetAttributes(
+			attribute.Int("capm.selection.family_repeats", sel.FamilyRepeats),
+			attribute.Int("capm.selection.enemy_conflicts", sel.EnemyConflicts),
+		)
+		if sel.FamilyRepeats == 0 && sel.EnemyConflicts == 0 {
+			return
+		}
+		// The draw still succeeds, but operators need to know the bank ran
+		// short of distinct questions
+		metrics.SelectionRelaxations.WithLabelValues(examType, "family").Add(float64(sel.FamilyRepeats))
+		metrics.SelectionRelaxations.WithLabelValues(examType, "enemy").Add(float64(sel.EnemyConflicts))
+		slog.WarnContext(ctx, "question draw relaxed selection constraints",
+			"attempt_id", attempt.ID, "exam_type", examType,
+			"family_repeats", sel.FamilyRepeats, "enemy_conflicts", sel.EnemyConflicts)
+	}()
+
 	if exam.Name == hardExamName {
-		return s.repo.GetRandomQuestionsByDomain(ctx, attempt.MaxScore, attempt.Seed, hardDomainName, nil)
+		return s.repo.DrawQuestions(ctx, sel, []repository.DomainDraw{
+			{Domain: hardDomainName, Count: attempt.MaxScore, Seed: attempt.Seed},
+		})
 	}
 
 	if sumQuota(blueprint) != attempt.MaxScore {
 		return nil, fmt.Errorf("allocator mismatch: expected %d, got quota %d", attempt.MaxScore, sumQuota(blueprint))
 	}
 
-	selected := make([]int, 0, attempt.MaxScore)
-	selectedSet := make(map[int]struct{}, attempt.MaxScore)
+	// Hard questions are drawn first; each domain gets the next seed
+	draws := make([]repository.DomainDraw, 0, len(blueprint.domainCounts)+1)
 	seed := attempt.Seed
-
-	// Hard question allocation first
 	if blueprint.hardCount > 0 {
-		hardIDs, err := s.repo.GetRandomQuestionsByDomain(ctx, blueprint.hardCount, seed, hardDomainName, nil)
-		if err != nil {
-			return nil, err
-		}
-		for _, id := range hardIDs {
-			if _, exists := selectedSet[id]; exists {
-				continue
-			}
-			selected = append(selected, id)
-			selectedSet[id] = struct{}{}
-		}
+		draws = append(draws, repository.DomainDraw{Domain: hardDomainName, Count: blueprint.hardCount, Seed: seed})
 		seed++
 	}
-
 	for _, dq := range blueprint.domainCounts {
-		if dq.count == 0 {
+		if dq.count == 0 || dq.domain == hardDomainName {
 			continue
 		}
-
-		// If this domain is Hard Question, skip because already allocated
-		if dq.domain == hardDomainName {
-			continue
-		}
-
-		exclude := make([]int, 0, len(selectedSet))
-		for id := range selectedSet {
-			exclude = append(exclude, id)
-		}
-
-		domainIDs, err := s.repo.GetRandomQuestionsByDomain(ctx, dq.count, seed, dq.domain, exclude)
-		if err != nil {
-			return nil, err
-		}
-		for _, id := range domainIDs {
-			if _, exists := selectedSet[id]; exists {
-				continue
-			}
-			selected = append(selected, id)
-			selectedSet[id] = struct{}{}
-		}
+		draws = append(draws, repository.DomainDraw{Domain: dq.domain, Count: dq.count, Seed: seed})
 		seed++
 	}
 
+	selected, err := s.repo.DrawQuestions(ctx, sel, draws)
+	if err != nil {
+		return nil, err
+	}
+
 	if len(selected) != attempt.MaxScore {
 		return nil, fmt.Errorf("selected %d unique questions, expected %d", len(selected), attempt.MaxScore)
 	}
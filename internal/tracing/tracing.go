@@ -0,0 +1,99 @@
+// Package tracing configures OpenTelemetry and provides the spans shared by
+// the HTTP, service and database layers.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	serviceName = "capm-exam-system"
+	tracerName  = "capm-exam-system"
+)
+
+// Attribute keys set on spans that concern one attempt.
+const (
+	AttemptIDKey = attribute.Key("capm.attempt.id")
+	ExamTypeKey  = attribute.Key("capm.exam.type")
+)
+
+// Setup installs the global tracer provider and propagators. The exporter is
+// chosen by OTEL_TRACES_EXPORTER: "otlp" sends to the collector configured by
+// the standard OTEL_EXPORTER_OTLP_* variables, "stdout" prints spans for local
+// runs, and "none" (the default) records nothing. The returned function
+// flushes pending spans and must be called before exit.
+func Setup(ctx context.Context) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	var exporter sdktrace.SpanExporter
+	var err error
+	switch kind := strings.ToLower(os.Getenv("OTEL_TRACES_EXPORTER")); kind {
+	case "", "none":
+		return func(context.Context) error { return nil }, nil
+	case "otlp":
+		exporter, err = otlptracehttp.New(ctx)
+	case "stdout", "console":
+		exporter, err = stdouttrace.New(stdouttrace.WithPrettyPrint())
+	default:
+		return nil, fmt.Errorf("unknown OTEL_TRACES_EXPORTER %q (want otlp, stdout or none)", kind)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create trace exporter: %v", err)
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(
+		semconv.SchemaURL,
+		semconv.ServiceName(serviceName),
+	))
+	if err != nil {
+		return nil, fmt.Errorf("failed to build trace resource: %v", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
+
+// Tracer returns the application's tracer from the global provider.
+func Tracer() trace.Tracer {
+	return otel.Tracer(tracerName)
+}
+
+// Start opens a child span of ctx.
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return Tracer().Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// AttemptID tags a span with the attempt it concerns.
+func AttemptID(id fmt.Stringer) attribute.KeyValue {
+	return AttemptIDKey.String(id.String())
+}
+
+// ExamType tags a span with the attempt's exam type (quiz, exam, hard, pmp).
+func ExamType(examType string) attribute.KeyValue {
+	return ExamTypeKey.String(examType)
+}
+
+// SetAttempt adds attempt attributes to the span on ctx once they are known.
+func SetAttempt(ctx context.Context, attrs ...attribute.KeyValue) {
+	trace.SpanFromContext(ctx).SetAttributes(attrs...)
+}
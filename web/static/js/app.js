@@ -72,6 +72,37 @@ function validateForm(formData) {
 }
 
 // API helper functions
+
+// Reads the message out of an API error response ({"error": {"message": ...}}),
+// falling back to the raw body for non-API responses.
+async function readErrorMessage(response) {
+    const text = await response.text();
+    try {
+        const body = JSON.parse(text);
+        if (body && body.error && body.error.message) {
+            return body.error.message;
+        }
+    } catch (error) {
+        // Not a JSON error envelope
+    }
+    return text || `HTTP ${response.status}`;
+}
+
+// Returns the Idempotency-Key for submitting an attempt. The key survives
+// reloads so a retried submit after a dropped response gets the original
+// result instead of an "already submitted" error.
+function submitIdempotencyKey(attemptId) {
+    const storageKey = `submit_key_${attemptId}`;
+    let key = sessionStorage.getItem(storageKey);
+    if (!key) {
+        key = window.crypto && crypto.randomUUID
+            ? crypto.randomUUID()
+            : `${Date.now()}-${Math.random().toString(36).slice(2)}`;
+        sessionStorage.setItem(storageKey, key);
+    }
+    return key;
+}
+
 async function apiRequest(url, options = {}) {
     const defaultOptions = {
         headers: {
@@ -85,7 +116,7 @@ async function apiRequest(url, options = {}) {
         const response = await fetch(url, config);
 
         if (!response.ok) {
-            const errorText = await response.text();
+            const errorText = await readErrorMessage(response);
             throw new Error(`HTTP ${response.status}: ${errorText}`);
         }
 
@@ -253,32 +284,101 @@ function selectChoiceByIndex(index) {
 }
 
 // Analytics and tracking
+// ExamAnalytics records which question is on screen, for how long, and every
+// change of selection, and ships the events to the server in batches so
+// results can include pacing analytics.
 class ExamAnalytics {
-    constructor(attemptId) {
+    constructor(attemptId, { flushInterval = 10000 } = {}) {
         this.attemptId = attemptId;
         this.events = [];
         this.startTime = Date.now();
+        this.currentQuestionId = null;
+        this.viewStartedAt = null;
+        this.flushTimer = setInterval(() => this.flush(), flushInterval);
+
+        document.addEventListener('visibilitychange', () => {
+            if (document.visibilityState === 'hidden') {
+                this.pausedQuestionId = this.currentQuestionId;
+                this.leaveCurrentQuestion();
+                this.flush({ beacon: true });
+            } else if (this.pausedQuestionId !== null && this.pausedQuestionId !== undefined) {
+                this.trackQuestionView(this.pausedQuestionId);
+                this.pausedQuestionId = null;
+            }
+        });
+        window.addEventListener('pagehide', () => {
+            this.leaveCurrentQuestion();
+            this.flush({ beacon: true });
+        });
     }
 
-    trackEvent(event, data = {}) {
+    trackEvent(type, questionId, extra = {}) {
         this.events.push({
-            event,
-            data,
-            timestamp: Date.now(),
-            timeFromStart: Date.now() - this.startTime
+            type,
+            question_id: questionId,
+            occurred_at: new Date().toISOString(),
+            ...extra
         });
     }
 
-    trackQuestionView(questionId, questionIndex) {
-        this.trackEvent('question_view', { questionId, questionIndex });
+    trackQuestionView(questionId) {
+        if (this.currentQuestionId === questionId) return;
+
+        this.leaveCurrentQuestion();
+        this.currentQuestionId = questionId;
+        this.viewStartedAt = Date.now();
+        this.trackEvent('view', questionId);
+    }
+
+    leaveCurrentQuestion() {
+        if (this.currentQuestionId === null) return;
+
+        this.trackEvent('leave', this.currentQuestionId, { dwell_ms: Date.now() - this.viewStartedAt });
+        this.currentQuestionId = null;
+        this.viewStartedAt = null;
+    }
+
+    trackAnswerChange(questionId, choiceIds) {
+        this.trackEvent('answer', questionId, { choice_ids: choiceIds.map(id => parseInt(id, 10)) });
+    }
+
+    async flush({ beacon = false } = {}) {
+        if (this.events.length === 0) return;
+
+        const batch = this.events.splice(0, this.events.length);
+        const url = `/api/exams/${this.attemptId}/events`;
+        const body = JSON.stringify({ events: batch });
+
+        if (beacon && navigator.sendBeacon) {
+            navigator.sendBeacon(url, new Blob([body], { type: 'application/json' }));
+            return;
+        }
+
+        try {
+            const response = await fetch(url, {
+                method: 'POST',
+                headers: { 'Content-Type': 'application/json' },
+                body,
+                keepalive: true
+            });
+            if (response.status >= 500) {
+                this.events.unshift(...batch);
+            }
+        } catch (error) {
+            console.warn('Failed to send exam analytics:', error);
+            this.events.unshift(...batch);
+        }
     }
 
-    trackAnswerChange(questionId, oldChoiceId, newChoiceId) {
-        this.trackEvent('answer_change', { questionId, oldChoiceId, newChoiceId });
+    // finish closes the question on screen and sends everything still queued;
+    // call it before submitting so the server sees the final dwell times.
+    async finish() {
+        this.leaveCurrentQuestion();
+        await this.flush();
     }
 
-    trackNavigation(from, to, method) {
-        this.trackEvent('navigation', { from, to, method });
+    stop() {
+        clearInterval(this.flushTimer);
     }
 
     getAnalytics() {